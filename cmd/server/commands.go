@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bwg_transactional_system/internal/app"
+	"bwg_transactional_system/internal/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// runReconcile выполняет одну сверку, печатает отчёт в stdout и возвращает код выхода:
+// 0 если расхождений нет, 1 если они найдены, 2 если сверку не удалось выполнить
+func runReconcile(repo repository.Repository) int {
+	defer repo.Close()
+
+	report, err := app.NewApp(repo, nil).Reconcile(context.Background())
+	if err != nil {
+		return 2
+	}
+
+	bytes, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(bytes))
+	if !report.OK() {
+		return 1
+	}
+
+	return 0
+}
+
+// runMigrate выполняет команду migrate и возвращает код выхода:
+// up применяет все новые миграции, down [N] откатывает N последних (по умолчанию одну), status печатает их состояние
+func runMigrate(repo *repository.PostgresRepo, args []string) int {
+	defer repo.Close()
+
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		done, err := repo.Migrator().Up(ctx)
+		for _, m := range done {
+			log.Printf("Applied migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("Migration failed: %v", err)
+			return 1
+		}
+		if len(done) == 0 {
+			log.Print("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				log.Printf("Invalid number of migrations to roll back: %s", args[1])
+				return 2
+			}
+			steps = n
+		}
+
+		done, err := repo.Migrator().Down(ctx, steps)
+		for _, m := range done {
+			log.Printf("Rolled back migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("Rollback failed: %v", err)
+			return 1
+		}
+	case "status":
+		statuses, err := repo.Migrator().Status(ctx)
+		if err != nil {
+			log.Printf("Can't read migrations status: %v", err)
+			return 1
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Printf("Unknown migrate command %q, expected up, down [N] or status", command)
+		return 2
+	}
+
+	return 0
+}
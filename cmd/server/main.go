@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
@@ -33,11 +34,40 @@ func main() {
 		DBName:   os.Getenv("DB_NAME"),
 		SSLMode:  os.Getenv("SSL_MODE"),
 	}
-	postgresRepo, err := repository.NewPostgresRepo(dbCfg)
-	if err != nil {
-		log.Fatalf("Can't connect to db: %v", err)
+	if feeWallet := os.Getenv("FEE_WALLET_ID"); feeWallet != "" {
+		if dbCfg.FeeWalletID, err = strconv.Atoi(feeWallet); err != nil {
+			log.Fatalf("Invalid FEE_WALLET_ID: %v", err)
+		}
+	}
+	// управление миграциями схемы бд: ./transaction-app migrate [up | down [N] | status]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		dbCfg.SkipMigrations = true
+		postgresRepo, err := repository.NewPostgresRepo(dbCfg)
+		if err != nil {
+			log.Fatalf("Can't connect to db: %v", err)
+		}
+		os.Exit(runMigrate(postgresRepo, os.Args[2:]))
+	}
+
+	// STORAGE=memory запускает сервис без PostgreSQL, данные хранятся в памяти и теряются при остановке
+	var repo repository.Repository
+	var postgresRepo *repository.PostgresRepo
+	if os.Getenv("STORAGE") == "memory" {
+		repo = repository.NewMemoryRepo(dbCfg.FeeWalletID)
+		log.Print("Using in-memory storage")
+	} else {
+		postgresRepo, err = repository.NewPostgresRepo(dbCfg)
+		if err != nil {
+			log.Fatalf("Can't connect to db: %v", err)
+		}
+		repo = postgresRepo
+		log.Print("Connected to db")
+	}
+
+	// сверка балансов по требованию: ./transaction-app reconcile
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		os.Exit(runReconcile(repo))
 	}
-	log.Print("Connected to db")
 
 	// создаём подключение к брокеру сообщений
 	brokerCfg := &broker.Config{
@@ -54,20 +84,120 @@ func main() {
 
 	// создаём приложение
 	ctx := context.Background()
-	if err := postgresRepo.TruncateBalances(ctx); err != nil { // только для тестов
-		log.Fatalf("Can't truncate balances: %v", err)
-	}
-	if err := postgresRepo.TruncateTransactions(ctx); err != nil { // только для тестов
-		log.Fatalf("Can't truncate transactions: %v", err)
+	if postgresRepo != nil {
+		// очистка бд перед нагрузочным тестом, только для тестового стенда: без явного TRUNCATE_ON_START данные не трогаются
+		if os.Getenv("TRUNCATE_ON_START") == "true" {
+			log.Print("TRUNCATE_ON_START is set, truncating balances and transactions")
+			if err := postgresRepo.TruncateBalances(ctx); err != nil {
+				log.Fatalf("Can't truncate balances: %v", err)
+			}
+			if err := postgresRepo.TruncateTransactions(ctx); err != nil {
+				log.Fatalf("Can't truncate transactions: %v", err)
+			}
+		}
+	} else if err := helpers.FillTestData(repo); err != nil { // в памяти нет ни тикеров, ни кошельков
+		log.Fatalf("Can't fill test data: %v", err)
 	}
 
-	transactionalApp := app.NewApp(postgresRepo, rabbit)
+	transactionalApp := app.NewApp(repo, rabbit)
 	// запускаем 10 обработчиков сообщений
 	for i := 0; i < 10; i++ {
 		transactionalApp.RunConsumer(ctx)
 	}
 	log.Print("App started, consume messages")
 
+	// запускаем фоновую сверку балансов с журналом транзакций
+	reconcileInterval := time.Hour
+	if interval := os.Getenv("RECONCILE_INTERVAL"); interval != "" {
+		if reconcileInterval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid RECONCILE_INTERVAL: %v", err)
+		}
+	}
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	if reconcileInterval > 0 {
+		transactionalApp.RunReconciliation(jobsCtx, reconcileInterval)
+	}
+
+	// запускаем создание снимков балансов для запросов баланса на момент в прошлом
+	snapshotInterval := 24 * time.Hour
+	if interval := os.Getenv("BALANCE_SNAPSHOT_INTERVAL"); interval != "" {
+		if snapshotInterval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid BALANCE_SNAPSHOT_INTERVAL: %v", err)
+		}
+	}
+	if snapshotInterval > 0 {
+		transactionalApp.RunBalanceSnapshots(jobsCtx, snapshotInterval)
+	}
+
+	// запускаем выполнение запланированных платежей, результаты отправляются в очередь SCHEDULED_REPLY_QUEUE
+	schedulerInterval := 10 * time.Second
+	if interval := os.Getenv("SCHEDULER_INTERVAL"); interval != "" {
+		if schedulerInterval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid SCHEDULER_INTERVAL: %v", err)
+		}
+	}
+	scheduledReplyQueue := os.Getenv("SCHEDULED_REPLY_QUEUE")
+	if scheduledReplyQueue != "" {
+		if err := rabbit.DeclareQueue(scheduledReplyQueue); err != nil {
+			log.Fatalf("Can't declare scheduled payments reply queue: %v", err)
+		}
+	}
+	if schedulerInterval > 0 {
+		transactionalApp.RunScheduledPayments(jobsCtx, schedulerInterval, scheduledReplyQueue)
+	}
+
+	// запускаем публикацию событий о транзакциях из outbox в обменник событий
+	outboxInterval := time.Second
+	if interval := os.Getenv("OUTBOX_INTERVAL"); interval != "" {
+		if outboxInterval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid OUTBOX_INTERVAL: %v", err)
+		}
+	}
+	if outboxInterval > 0 {
+		transactionalApp.RunOutboxRelay(jobsCtx, outboxInterval)
+	}
+
+	// запускаем перенос зачислений комиссий на баланс кошелька комиссий
+	feeCreditsInterval := time.Second
+	if interval := os.Getenv("FEE_CREDITS_INTERVAL"); interval != "" {
+		if feeCreditsInterval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid FEE_CREDITS_INTERVAL: %v", err)
+		}
+	}
+	if feeCreditsInterval > 0 {
+		transactionalApp.RunFeeCredits(jobsCtx, feeCreditsInterval)
+	}
+
+	// запускаем отправку уведомлений о транзакциях на зарегистрированные адреса
+	webhookInterval := 5 * time.Second
+	if interval := os.Getenv("WEBHOOK_INTERVAL"); interval != "" {
+		if webhookInterval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid WEBHOOK_INTERVAL: %v", err)
+		}
+	}
+	if webhookInterval > 0 {
+		transactionalApp.RunWebhookDeliveries(jobsCtx, webhookInterval)
+	}
+
+	// запускаем архивацию завершённых транзакций и обслуживание разделов таблицы transactions
+	archiveInterval, archiveAge := time.Hour, 90*24*time.Hour
+	if interval := os.Getenv("ARCHIVE_INTERVAL"); interval != "" {
+		if archiveInterval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid ARCHIVE_INTERVAL: %v", err)
+		}
+	}
+	if age := os.Getenv("ARCHIVE_AFTER"); age != "" {
+		if archiveAge, err = time.ParseDuration(age); err != nil {
+			log.Fatalf("Invalid ARCHIVE_AFTER: %v", err)
+		}
+	}
+	if archiveAge < app.MinArchiveAge {
+		log.Fatalf("ARCHIVE_AFTER must be at least %s", app.MinArchiveAge)
+	}
+	if archiveInterval > 0 {
+		transactionalApp.RunTransactionArchiver(jobsCtx, archiveInterval, archiveAge)
+	}
+
 	serverAddr := ":" + os.Getenv("SERVER_PORT")
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
@@ -97,6 +227,7 @@ func main() {
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownRelease()
 
+	stopJobs()
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("HTTP shutdown error: %v", err)
 	}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"net/http"
 )
@@ -20,8 +21,50 @@ func NewApp(repo repository.Repository, broker broker.Broker) *App {
 	return &App{Repo: repo, Broker: broker}
 }
 
+// operation обработчик сообщения с конкретным routingKey, ответ на сообщение он отправляет сам
+type operation func(context.Context, *amqp.Delivery)
+
 func (a *App) RunConsumer(ctx context.Context) {
-	a.Broker.RunConsumer(ctx, a.invoiceOperation, a.withdrawOperation, a.getBalanceOperation)
+	a.Broker.RunConsumer(ctx, a.withAudit(map[broker.Operation]operation{
+		broker.OpInvoice:    a.invoiceOperation,
+		broker.OpWithdraw:   a.withdrawOperation,
+		broker.OpGetBalance: a.getBalanceOperation,
+		broker.OpTransfer:   a.transferOperation,
+		broker.OpCapture:    a.captureOperation,
+		broker.OpRelease:    a.releaseOperation,
+		broker.OpHistory:    a.historyOperation,
+		broker.OpExchange:   a.exchangeOperation,
+		broker.OpSetRate:    a.setExchangeRateOperation,
+		broker.OpGetRates:   a.getExchangeRatesOperation,
+		broker.OpGetWallet:  a.getWalletOperation,
+		broker.OpSetStatus:  a.setWalletStatusOperation,
+		broker.OpSetLimit:   a.setWithdrawalLimitOperation,
+		broker.OpGetLimits:  a.getWithdrawalLimitsOperation,
+		broker.OpDelLimit:   a.deleteWithdrawalLimitOperation,
+		broker.OpSetFee:     a.setFeeOperation,
+		broker.OpGetFees:    a.getFeesOperation,
+		broker.OpDelFee:     a.deleteFeeOperation,
+		broker.OpReconcile:  a.reconcileOperation,
+		broker.OpAddTicker:  a.createTickerOperation,
+		broker.OpUpdTicker:  a.updateTickerOperation,
+		broker.OpTickerStat: a.setTickerStatusOperation,
+		broker.OpGetTickers: a.getTickersOperation,
+		broker.OpAddCust:    a.createCustomerOperation,
+		broker.OpGetCust:    a.getCustomerOperation,
+		broker.OpAttachWal:  a.attachWalletOperation,
+		broker.OpCustBal:    a.getCustomerBalanceOperation,
+		broker.OpReverse:    a.reverseOperation,
+		broker.OpSchedule:   a.schedulePaymentOperation,
+		broker.OpGetSched:   a.getScheduledPaymentsOperation,
+		broker.OpCancelSch:  a.cancelScheduledPaymentOperation,
+		broker.OpBatch:      a.batchOperation,
+		broker.OpAddHook:    a.createWebhookOperation,
+		broker.OpGetHooks:   a.getWebhooksOperation,
+		broker.OpDelHook:    a.deleteWebhookOperation,
+		broker.OpHookLog:    a.getWebhookDeliveriesOperation,
+		broker.OpAuditLog:   a.getAuditLogOperation,
+		broker.OpUnknown:    a.unknownOperation,
+	}))
 }
 
 func (a *App) Close() error {
@@ -43,12 +86,33 @@ func (a *App) Close() error {
 func (a *App) sendBadRequest(ctx context.Context, op broker.Operation, err error, d *amqp.Delivery) {
 	a.Broker.SendResponse(ctx, broker.NewBadRequestResponse(d, err), d)
 	accountMetrics(op, http.StatusBadRequest)
+	auditResult(ctx, http.StatusBadRequest, "", nil)
 }
 
 // sendSuccess отправляет сообщение с успешным результатом операции
 func (a *App) sendSuccess(ctx context.Context, op broker.Operation, d *amqp.Delivery) {
 	a.Broker.SendResponse(ctx, broker.NewSuccessResponse(d), d)
 	accountMetrics(op, http.StatusOK)
+	auditResult(ctx, http.StatusOK, "", nil)
+}
+
+// sendSuccessWithBody отправляет сообщение с успешным результатом операции и телом ответа
+func (a *App) sendSuccessWithBody(ctx context.Context, op broker.Operation, resp any, d *amqp.Delivery) {
+	bytes, _ := json.Marshal(resp)
+	a.Broker.SendResponse(ctx, broker.NewSuccessResponseWithBody(d, bytes), d)
+	accountMetrics(op, http.StatusOK)
+	auditResult(ctx, http.StatusOK, "", resp)
+}
+
+// idempotencyKey возвращает ключ идемпотентности запроса. Если он не указан в теле запроса,
+// то используется MessageId сообщения, который сохраняется при повторной доставке. CorrelationId ключом не является:
+// RPC-клиенты переиспользуют его для разных запросов.
+func idempotencyKey(key string, d *amqp.Delivery) string {
+	if key != "" {
+		return key
+	}
+
+	return d.MessageId
 }
 
 func (a *App) invoiceOperation(ctx context.Context, d *amqp.Delivery) {
@@ -63,8 +127,9 @@ func (a *App) invoiceOperation(ctx context.Context, d *amqp.Delivery) {
 	}
 
 	// отправляем запрос в базу данных
-	err := a.Repo.Invoice(ctx, &req)
-	a.processResult(ctx, broker.OpInvoice, err, d)
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.Invoice(ctx, &req)
+	a.processResponse(ctx, broker.OpInvoice, resp, err, d)
 }
 
 func (a *App) withdrawOperation(ctx context.Context, d *amqp.Delivery) {
@@ -79,8 +144,152 @@ func (a *App) withdrawOperation(ctx context.Context, d *amqp.Delivery) {
 	}
 
 	// отправляем запрос в базу данных
-	err := a.Repo.WithDraw(ctx, &req)
-	a.processResult(ctx, broker.OpWithdraw, err, d)
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.WithDraw(ctx, &req)
+	a.processResponse(ctx, broker.OpWithdraw, resp, err, d)
+}
+
+func (a *App) captureOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.HoldRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpCapture, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpCapture, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.Capture(ctx, &req)
+	a.processResponse(ctx, broker.OpCapture, resp, err, d)
+}
+
+func (a *App) releaseOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.HoldRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpRelease, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpRelease, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.Release(ctx, &req)
+	a.processResponse(ctx, broker.OpRelease, resp, err, d)
+}
+
+func (a *App) transferOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.TransferRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpTransfer, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpTransfer, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.Transfer(ctx, &req)
+	a.processResponse(ctx, broker.OpTransfer, resp, err, d)
+}
+
+func (a *App) reverseOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.ReverseRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpReverse, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpReverse, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.Reverse(ctx, &req)
+	a.processResponse(ctx, broker.OpReverse, resp, err, d)
+}
+
+func (a *App) batchOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.BatchRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpBatch, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpBatch, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.Batch(ctx, &req)
+	a.processResponse(ctx, broker.OpBatch, resp, err, d)
+}
+
+func (a *App) schedulePaymentOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.CreateScheduledPaymentRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpSchedule, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpSchedule, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.CreateScheduledPayment(ctx, &req)
+	a.processResponse(ctx, broker.OpSchedule, resp, err, d)
+}
+
+func (a *App) getScheduledPaymentsOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.ScheduledPaymentsRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetSched, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetSched, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListScheduledPayments(ctx, &req)
+	a.processResponse(ctx, broker.OpGetSched, resp, err, d)
+}
+
+func (a *App) cancelScheduledPaymentOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.CancelScheduledPaymentRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpCancelSch, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpCancelSch, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.CancelScheduledPayment(ctx, &req)
+	a.processResponse(ctx, broker.OpCancelSch, resp, err, d)
+}
+
+// processResponse отправляет ответ на операцию, создающую транзакции: resp в случае успеха или ошибку
+func (a *App) processResponse(ctx context.Context, op broker.Operation, resp any, err error, d *amqp.Delivery) {
+	if err != nil {
+		a.processResult(ctx, op, err, d)
+		return
+	}
+
+	a.sendSuccessWithBody(ctx, op, resp, d)
 }
 
 func (a *App) processResult(ctx context.Context, op broker.Operation, err error, d *amqp.Delivery) {
@@ -88,10 +297,13 @@ func (a *App) processResult(ctx context.Context, op broker.Operation, err error,
 		var e repository.LogicErrors
 		switch {
 		case errors.As(err, &e):
-			a.sendBadRequest(ctx, op, e, d)
+			a.Broker.SendResponse(ctx, broker.NewLogicErrorResponse(d, e.Code, e), d)
+			accountMetrics(op, http.StatusBadRequest)
+			auditResult(ctx, http.StatusBadRequest, e.Code, nil)
 		default:
 			a.Broker.SendResponse(ctx, broker.NewErrorResponse(d, http.StatusInternalServerError, err), d)
 			accountMetrics(op, http.StatusInternalServerError)
+			auditResult(ctx, http.StatusInternalServerError, "", nil)
 		}
 	} else {
 		a.sendSuccess(ctx, op, d)
@@ -104,6 +316,10 @@ func (a *App) getBalanceOperation(ctx context.Context, d *amqp.Delivery) {
 		a.sendBadRequest(ctx, broker.OpGetBalance, err, d)
 		return
 	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetBalance, err, d)
+		return
+	}
 
 	// отправляем запрос в базу данных
 	resp, err := a.Repo.GetBalance(ctx, &req)
@@ -120,4 +336,391 @@ func (a *App) getBalanceOperation(ctx context.Context, d *amqp.Delivery) {
 	})
 	a.Broker.SendResponse(ctx, body, d)
 	accountMetrics(broker.OpGetBalance, http.StatusOK)
+	auditResult(ctx, http.StatusOK, "", nil)
+}
+
+func (a *App) historyOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.HistoryRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpHistory, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpHistory, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListTransactions(ctx, &req)
+	a.processResponse(ctx, broker.OpHistory, resp, err, d)
+}
+
+func (a *App) exchangeOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.ExchangeRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpExchange, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpExchange, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	req.IdempotencyKey = idempotencyKey(req.IdempotencyKey, d)
+	resp, err := a.Repo.Exchange(ctx, &req)
+	a.processResponse(ctx, broker.OpExchange, resp, err, d)
+}
+
+func (a *App) setExchangeRateOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.ExchangeRate{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetRate, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetRate, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	err := a.Repo.SetExchangeRate(ctx, &req)
+	a.processResult(ctx, broker.OpSetRate, err, d)
+}
+
+func (a *App) getExchangeRatesOperation(ctx context.Context, d *amqp.Delivery) {
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.GetExchangeRates(ctx)
+	a.processResponse(ctx, broker.OpGetRates, resp, err, d)
+}
+
+func (a *App) getWalletOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.GetWalletRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetWallet, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetWallet, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.GetWallet(ctx, &req)
+	a.processResponse(ctx, broker.OpGetWallet, resp, err, d)
+}
+
+func (a *App) setWalletStatusOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.SetWalletStatusRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetStatus, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetStatus, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.SetWalletStatus(ctx, &req)
+	a.processResponse(ctx, broker.OpSetStatus, resp, err, d)
+}
+
+func (a *App) setWithdrawalLimitOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.WithdrawalLimit{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetLimit, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetLimit, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	err := a.Repo.SetWithdrawalLimit(ctx, &req)
+	a.processResult(ctx, broker.OpSetLimit, err, d)
+}
+
+func (a *App) getWithdrawalLimitsOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.WithdrawalLimitsRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetLimits, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetLimits, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListWithdrawalLimits(ctx, &req)
+	a.processResponse(ctx, broker.OpGetLimits, resp, err, d)
+}
+
+func (a *App) deleteWithdrawalLimitOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.DeleteWithdrawalLimitRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpDelLimit, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpDelLimit, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	err := a.Repo.DeleteWithdrawalLimit(ctx, &req)
+	a.processResult(ctx, broker.OpDelLimit, err, d)
+}
+
+func (a *App) setFeeOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.FeeRule{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetFee, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpSetFee, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	err := a.Repo.SetFee(ctx, &req)
+	a.processResult(ctx, broker.OpSetFee, err, d)
+}
+
+func (a *App) getFeesOperation(ctx context.Context, d *amqp.Delivery) {
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListFees(ctx)
+	a.processResponse(ctx, broker.OpGetFees, resp, err, d)
+}
+
+func (a *App) deleteFeeOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.DeleteFeeRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpDelFee, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpDelFee, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	err := a.Repo.DeleteFee(ctx, &req)
+	a.processResult(ctx, broker.OpDelFee, err, d)
+}
+
+func (a *App) reconcileOperation(ctx context.Context, d *amqp.Delivery) {
+	// сверка по запросу, в дополнение к фоновой
+	resp, err := a.Reconcile(ctx)
+	a.processResponse(ctx, broker.OpReconcile, resp, err, d)
+}
+
+func (a *App) createTickerOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.CreateTickerRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpAddTicker, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpAddTicker, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.CreateTicker(ctx, &req)
+	a.processResponse(ctx, broker.OpAddTicker, resp, err, d)
+}
+
+func (a *App) updateTickerOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.UpdateTickerRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpUpdTicker, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpUpdTicker, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.UpdateTicker(ctx, &req)
+	a.processResponse(ctx, broker.OpUpdTicker, resp, err, d)
+}
+
+func (a *App) setTickerStatusOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.SetTickerStatusRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpTickerStat, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpTickerStat, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.SetTickerStatus(ctx, &req)
+	a.processResponse(ctx, broker.OpTickerStat, resp, err, d)
+}
+
+func (a *App) getTickersOperation(ctx context.Context, d *amqp.Delivery) {
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListTickers(ctx)
+	a.processResponse(ctx, broker.OpGetTickers, resp, err, d)
+}
+
+func (a *App) createCustomerOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.CreateCustomerRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpAddCust, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpAddCust, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.CreateCustomer(ctx, &req)
+	a.processResponse(ctx, broker.OpAddCust, resp, err, d)
+}
+
+func (a *App) getCustomerOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.GetCustomerRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetCust, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetCust, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.GetCustomer(ctx, &req)
+	a.processResponse(ctx, broker.OpGetCust, resp, err, d)
+}
+
+func (a *App) attachWalletOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.AttachWalletRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpAttachWal, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpAttachWal, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.AttachWallet(ctx, &req)
+	a.processResponse(ctx, broker.OpAttachWal, resp, err, d)
+}
+
+func (a *App) getCustomerBalanceOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.GetCustomerRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpCustBal, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpCustBal, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.GetCustomerBalance(ctx, &req)
+	a.processResponse(ctx, broker.OpCustBal, resp, err, d)
+}
+
+func (a *App) createWebhookOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.CreateWebhookRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpAddHook, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpAddHook, err, d)
+		return
+	}
+	if err := checkWebhookHost(ctx, req.URL); err != nil {
+		a.sendBadRequest(ctx, broker.OpAddHook, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.CreateWebhook(ctx, &req)
+	a.processResponse(ctx, broker.OpAddHook, resp, err, d)
+}
+
+func (a *App) getWebhooksOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.WebhooksRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetHooks, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpGetHooks, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListWebhooks(ctx, &req)
+	a.processResponse(ctx, broker.OpGetHooks, resp, err, d)
+}
+
+func (a *App) deleteWebhookOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.DeleteWebhookRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpDelHook, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpDelHook, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	err := a.Repo.DeleteWebhook(ctx, &req)
+	a.processResult(ctx, broker.OpDelHook, err, d)
+}
+
+func (a *App) getWebhookDeliveriesOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.WebhookDeliveriesRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpHookLog, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpHookLog, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListWebhookDeliveries(ctx, &req)
+	a.processResponse(ctx, broker.OpHookLog, resp, err, d)
+}
+
+func (a *App) getAuditLogOperation(ctx context.Context, d *amqp.Delivery) {
+	req := models.AuditLogRequest{}
+	if err := json.Unmarshal(d.Body, &req); err != nil {
+		a.sendBadRequest(ctx, broker.OpAuditLog, err, d)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		a.sendBadRequest(ctx, broker.OpAuditLog, err, d)
+		return
+	}
+
+	// отправляем запрос в базу данных
+	resp, err := a.Repo.ListAuditLog(ctx, &req)
+	a.processResponse(ctx, broker.OpAuditLog, resp, err, d)
+}
+
+// unknownOperation отвечает ошибкой на сообщение с неизвестным routingKey, чтобы оно тоже попало в журнал аудита
+func (a *App) unknownOperation(ctx context.Context, d *amqp.Delivery) {
+	a.sendBadRequest(ctx, broker.OpUnknown, fmt.Errorf("no such operation: %s", d.RoutingKey), d)
 }
@@ -0,0 +1,492 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/broker"
+	"bwg_transactional_system/internal/models"
+	"bwg_transactional_system/internal/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker сохраняет ответы обработчиков вместо отправки в RabbitMQ
+type fakeBroker struct {
+	mu        sync.Mutex
+	handlers  map[broker.Operation]broker.Handler
+	responses [][]byte
+}
+
+func (b *fakeBroker) SendResponse(ctx context.Context, bytes []byte, d *amqp.Delivery) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.responses = append(b.responses, bytes)
+}
+
+func (b *fakeBroker) DeclareQueue(name string) error {
+	return nil
+}
+
+func (b *fakeBroker) PublishEvent(ctx context.Context, routingKey, messageID string, body []byte) error {
+	return nil
+}
+
+func (b *fakeBroker) RunConsumer(ctx context.Context, handlers map[broker.Operation]broker.Handler) {
+	b.handlers = handlers
+}
+
+func (b *fakeBroker) Close() error {
+	return nil
+}
+
+// testResponse ответ обработчика, общий для SuccessResponse и ErrorResponse
+type testResponse struct {
+	Code      int    `json:"code"`
+	Reason    string `json:"reason"`
+	ErrorCode string `json:"error_code"`
+	Body      string `json:"body"`
+}
+
+// Кошельки сценария: кошелёк комиссий и два кошелька клиентов
+const (
+	walletFee = iota
+	walletA
+	walletB
+)
+
+// appTest App с фейковым брокером поверх репозитория repo. Тикеры сценария называются USD и EUR
+// с суффиксом suffix, чтобы сценарии в общей бд не влияли друг на друга.
+type appTest struct {
+	t        *testing.T
+	broker   *fakeBroker
+	suffix   string
+	wallets  [3]int
+	ids      map[string]int
+	messages int
+}
+
+func newAppTest(t *testing.T, repo repository.Repository, feeWalletID int, suffix string) *appTest {
+	t.Helper()
+	e := &appTest{t: t, broker: &fakeBroker{}, suffix: suffix, ids: make(map[string]int)}
+	NewApp(repo, e.broker).RunConsumer(context.Background())
+
+	e.wallets[walletFee] = feeWalletID
+	for _, role := range []int{walletA, walletB} {
+		wallet, err := repo.CreateWallet(context.Background())
+		if err != nil {
+			t.Fatalf("CreateWallet: %v", err)
+		}
+		e.wallets[role] = wallet.WalletID
+	}
+
+	// общие настройки сценариев: комиссия 1% за зачисление USD и 0.50 USD за списание, курс USD/EUR 0.9
+	// и 100 USD на кошельке A, из которых 1 USD ушёл в комиссию
+	e.mustCall(broker.OpAddTicker, models.CreateTickerRequest{Name: e.ticker("USD"), Scale: 2})
+	e.mustCall(broker.OpAddTicker, models.CreateTickerRequest{Name: e.ticker("EUR"), Scale: 2})
+	e.mustCall(broker.OpSetFee, models.FeeRule{Operation: models.OperationInvoice, Ticker: e.ticker("USD"), Rate: money("0.01")})
+	e.mustCall(broker.OpSetFee, models.FeeRule{Operation: models.OperationWithdraw, Ticker: e.ticker("USD"), Fixed: money("0.50")})
+	e.mustCall(broker.OpSetRate, models.ExchangeRate{FromTicker: e.ticker("USD"), ToTicker: e.ticker("EUR"), Rate: money("0.9")})
+	e.ids["setup"] = responseID(e.mustCall(broker.OpInvoice, e.invoice(walletA, "USD", "100")))
+
+	return e
+}
+
+// ticker возвращает название тикера сценария
+func (e *appTest) ticker(name string) string {
+	return name + e.suffix
+}
+
+// call передаёт обработчику операции op сообщение с телом req и возвращает ответ
+func (e *appTest) call(op broker.Operation, req any) testResponse {
+	e.t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		e.t.Fatalf("marshal %s request: %v", op, err)
+	}
+	// MessageId используется как ключ идемпотентности, если он не указан в запросе
+	e.messages++
+	d := &amqp.Delivery{RoutingKey: string(op), Body: body, MessageId: fmt.Sprintf("%s-%d", e.suffix, e.messages)}
+	if err := e.broker.handlers[op](context.Background(), d); err != nil {
+		e.t.Fatalf("%s: %v", op, err)
+	}
+
+	var resp testResponse
+	if err := json.Unmarshal(e.broker.responses[len(e.broker.responses)-1], &resp); err != nil {
+		e.t.Fatalf("unmarshal %s response: %v", op, err)
+	}
+
+	return resp
+}
+
+// mustCall как call, но завершает тест, если операция не выполнена
+func (e *appTest) mustCall(op broker.Operation, req any) testResponse {
+	e.t.Helper()
+	resp := e.call(op, req)
+	if resp.Code != http.StatusOK {
+		e.t.Fatalf("%s: code %d (%s) %s", op, resp.Code, resp.ErrorCode, resp.Reason)
+	}
+
+	return resp
+}
+
+func (e *appTest) invoice(wallet int, ticker, amount string) models.InvoiceRequest {
+	return models.InvoiceRequest{WalletID: e.wallets[wallet], Ticker: e.ticker(ticker), Amount: money(amount)}
+}
+
+func (e *appTest) withdraw(wallet int, ticker, amount string) models.WithdrawRequest {
+	return models.WithdrawRequest{WalletID: e.wallets[wallet], Ticker: e.ticker(ticker), Amount: money(amount)}
+}
+
+func (e *appTest) transfer(from, to int, ticker, amount string) models.TransferRequest {
+	return models.TransferRequest{
+		FromWalletID: e.wallets[from], ToWalletID: e.wallets[to], Ticker: e.ticker(ticker), Amount: money(amount),
+	}
+}
+
+// balance возвращает актуальный и замороженный баланс кошелька по тикерам сценария
+func (e *appTest) balance(wallet int) (map[string]string, map[string]string) {
+	e.t.Helper()
+	resp := e.mustCall(broker.OpGetBalance, models.GetBalanceRequest{WalletID: e.wallets[wallet]})
+	var balance models.GetBalanceResponse
+	if err := json.Unmarshal([]byte(resp.Body), &balance); err != nil {
+		e.t.Fatalf("unmarshal balance: %v", err)
+	}
+
+	actual, frozen := make(map[string]string), make(map[string]string)
+	for _, name := range []string{"USD", "EUR"} {
+		if amount, ok := balance.ActualBalance[e.ticker(name)]; ok && !amount.IsZero() {
+			actual[name] = amount.StringFixed(2)
+		}
+		if amount, ok := balance.FrozenBalance[e.ticker(name)]; ok && !amount.IsZero() {
+			frozen[name] = amount.StringFixed(2)
+		}
+	}
+
+	return actual, frozen
+}
+
+func money(s string) models.Money {
+	return models.MustParseMoney(s)
+}
+
+// responseID возвращает id основной транзакции из тела ответа
+func responseID(resp testResponse) int {
+	var ids struct {
+		TransactionID      int `json:"transaction_id"`
+		DebitTransactionID int `json:"debit_transaction_id"`
+	}
+	_ = json.Unmarshal([]byte(resp.Body), &ids)
+	if ids.TransactionID != 0 {
+		return ids.TransactionID
+	}
+
+	return ids.DebitTransactionID
+}
+
+// appStep одно сообщение сценария. errorCode пустой для успешного ответа, code нужен только для ошибок
+// без кода бизнес-логики (400 при проверке запроса). Если указан save, то id транзакции из ответа
+// сохраняется под этим именем и доступен следующим шагам через e.ids.
+type appStep struct {
+	op        broker.Operation
+	req       func(e *appTest) any
+	code      int
+	errorCode string
+	body      string
+	save      string
+}
+
+type appCase struct {
+	name  string
+	steps []appStep
+	// actual и frozen ожидаемые ненулевые балансы кошельков после сценария
+	actual map[int]map[string]string
+	frozen map[int]map[string]string
+}
+
+var appCases = []appCase{
+	{
+		name: "invoice",
+		steps: []appStep{
+			{op: broker.OpInvoice, req: func(e *appTest) any { return e.invoice(walletB, "USD", "50") }, body: `"fee":"0.50"`},
+			{op: broker.OpInvoice, req: func(e *appTest) any { return e.invoice(walletB, "USD", "0") }, code: http.StatusBadRequest},
+			{op: broker.OpInvoice, req: func(e *appTest) any { return e.invoice(walletB, "USD", "1.005") },
+				errorCode: repository.ErrCodeAmountPrecision},
+			{op: broker.OpInvoice, req: func(e *appTest) any { return e.invoice(walletB, "GBP", "1") },
+				errorCode: repository.ErrCodeTickerNotFound},
+			{op: broker.OpInvoice, req: func(e *appTest) any {
+				req := e.invoice(walletB, "USD", "1")
+				req.WalletID = 1 << 30
+				return req
+			}, errorCode: repository.ErrCodeWalletNotFound},
+			{op: broker.OpInvoice, req: func(e *appTest) any { return e.invoice(walletB, "USD", "10000000000000000000000") },
+				code: http.StatusBadRequest},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.50"}, walletA: {"USD": "99.00"}, walletB: {"USD": "49.50"}},
+	},
+	{
+		name: "withdraw and capture",
+		steps: []appStep{
+			{op: broker.OpWithdraw, req: func(e *appTest) any { return e.withdraw(walletA, "USD", "20") }, body: `"fee":"0.50"`, save: "hold"},
+			{op: broker.OpCapture, req: func(e *appTest) any { return models.HoldRequest{TransactionID: e.ids["hold"]} }},
+			{op: broker.OpCapture, req: func(e *appTest) any { return models.HoldRequest{TransactionID: e.ids["hold"]} },
+				errorCode: repository.ErrCodeTransactionNotHeld},
+			{op: broker.OpRelease, req: func(e *appTest) any { return models.HoldRequest{TransactionID: e.ids["hold"]} },
+				errorCode: repository.ErrCodeTransactionNotHeld},
+			{op: broker.OpCapture, req: func(e *appTest) any { return models.HoldRequest{TransactionID: e.ids["setup"]} },
+				errorCode: repository.ErrCodeTransactionNotHeld},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.50"}, walletA: {"USD": "78.50"}},
+	},
+	{
+		name: "withdraw and release",
+		steps: []appStep{
+			{op: broker.OpWithdraw, req: func(e *appTest) any { return e.withdraw(walletA, "USD", "20") }, save: "hold"},
+			{op: broker.OpRelease, req: func(e *appTest) any { return models.HoldRequest{TransactionID: e.ids["hold"]} }},
+			{op: broker.OpCapture, req: func(e *appTest) any { return models.HoldRequest{TransactionID: e.ids["hold"]} },
+				errorCode: repository.ErrCodeTransactionNotHeld},
+			{op: broker.OpWithdraw, req: func(e *appTest) any { return e.withdraw(walletA, "USD", "98.60") },
+				errorCode: repository.ErrCodeNotEnoughCoins},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}, walletA: {"USD": "99.00"}},
+	},
+	{
+		name: "withdrawal limit",
+		steps: []appStep{
+			{op: broker.OpSetLimit, req: func(e *appTest) any {
+				return models.WithdrawalLimit{WalletID: e.wallets[walletA], Ticker: e.ticker("USD"), MaxSingle: ptr(money("10"))}
+			}},
+			{op: broker.OpWithdraw, req: func(e *appTest) any { return e.withdraw(walletA, "USD", "10.01") },
+				errorCode: repository.ErrCodeLimitExceeded},
+			{op: broker.OpWithdraw, req: func(e *appTest) any { return e.withdraw(walletA, "USD", "10") }},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}, walletA: {"USD": "88.50"}},
+		frozen: map[int]map[string]string{walletA: {"USD": "10.50"}},
+	},
+	{
+		name: "transfer",
+		steps: []appStep{
+			{op: broker.OpTransfer, req: func(e *appTest) any { return e.transfer(walletA, walletB, "USD", "30") }},
+			{op: broker.OpTransfer, req: func(e *appTest) any { return e.transfer(walletB, walletA, "USD", "30.01") },
+				errorCode: repository.ErrCodeNotEnoughCoins},
+			{op: broker.OpTransfer, req: func(e *appTest) any { return e.transfer(walletA, walletA, "USD", "1") },
+				code: http.StatusBadRequest},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}, walletA: {"USD": "69.00"}, walletB: {"USD": "30.00"}},
+	},
+	{
+		name: "exchange",
+		steps: []appStep{
+			{op: broker.OpExchange, req: func(e *appTest) any {
+				return models.ExchangeRequest{WalletID: e.wallets[walletA], FromTicker: e.ticker("USD"), ToTicker: e.ticker("EUR"), Amount: money("10.05")}
+			}, body: `"credit_amount":"9.04"`},
+			{op: broker.OpExchange, req: func(e *appTest) any {
+				return models.ExchangeRequest{WalletID: e.wallets[walletA], FromTicker: e.ticker("EUR"), ToTicker: e.ticker("USD"), Amount: money("1")}
+			}, errorCode: repository.ErrCodeExchangeRateNotFound},
+			{op: broker.OpExchange, req: func(e *appTest) any {
+				return models.ExchangeRequest{WalletID: e.wallets[walletA], FromTicker: e.ticker("USD"), ToTicker: e.ticker("EUR"), Amount: money("0.01")}
+			}, body: `"credit_amount":"0.01"`},
+			{op: broker.OpExchange, req: func(e *appTest) any {
+				return models.ExchangeRequest{WalletID: e.wallets[walletA], FromTicker: e.ticker("USD"), ToTicker: e.ticker("EUR"), Amount: money("100")}
+			}, errorCode: repository.ErrCodeNotEnoughCoins},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}, walletA: {"USD": "88.94", "EUR": "9.05"}},
+	},
+	{
+		name: "batch rollback",
+		steps: []appStep{
+			{op: broker.OpBatch, req: func(e *appTest) any {
+				return models.BatchRequest{Legs: []models.BatchLeg{
+					{Operation: models.OperationInvoice, WalletID: e.wallets[walletB], Ticker: e.ticker("USD"), Amount: money("10")},
+					{Operation: models.OperationTransfer, WalletID: e.wallets[walletA], ToWalletID: e.wallets[walletB],
+						Ticker: e.ticker("USD"), Amount: money("500")},
+				}}
+			}, errorCode: repository.ErrCodeNotEnoughCoins},
+			{op: broker.OpBatch, req: func(e *appTest) any {
+				return models.BatchRequest{Legs: []models.BatchLeg{
+					{Operation: models.OperationTransfer, WalletID: e.wallets[walletA], ToWalletID: e.wallets[walletB],
+						Ticker: e.ticker("USD"), Amount: money("10")},
+					{Operation: models.OperationWithdraw, WalletID: e.wallets[walletB], Ticker: e.ticker("USD"), Amount: money("5")},
+				}}
+			}},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}, walletA: {"USD": "89.00"}, walletB: {"USD": "4.50"}},
+		frozen: map[int]map[string]string{walletB: {"USD": "5.50"}},
+	},
+	{
+		name: "batch exchange",
+		steps: []appStep{
+			{op: broker.OpBatch, req: func(e *appTest) any {
+				return models.BatchRequest{Legs: []models.BatchLeg{
+					{Operation: models.OperationExchange, WalletID: e.wallets[walletA], Ticker: e.ticker("USD"), ToTicker: e.ticker("EUR"),
+						Amount: money("10")},
+					{Operation: models.OperationExchange, WalletID: e.wallets[walletA], Ticker: e.ticker("USD"), ToTicker: e.ticker("EUR"),
+						Amount: money("90")},
+				}}
+			}, errorCode: repository.ErrCodeNotEnoughCoins},
+			{op: broker.OpBatch, req: func(e *appTest) any {
+				return models.BatchRequest{Legs: []models.BatchLeg{
+					{Operation: models.OperationExchange, WalletID: e.wallets[walletA], Ticker: e.ticker("USD"), ToTicker: e.ticker("EUR"),
+						Amount: money("10")},
+					{Operation: models.OperationTransfer, WalletID: e.wallets[walletA], ToWalletID: e.wallets[walletB],
+						Ticker: e.ticker("EUR"), Amount: money("4")},
+				}}
+			}, body: `"credit_amount":"9.00"`},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}, walletA: {"USD": "89.00", "EUR": "5.00"}, walletB: {"EUR": "4.00"}},
+	},
+	{
+		name: "reversal",
+		steps: []appStep{
+			{op: broker.OpReverse, req: func(e *appTest) any {
+				return models.ReverseRequest{TransactionID: e.ids["setup"], Amount: ptr(money("99.01"))}
+			}, errorCode: repository.ErrCodeReversalExceeds},
+			{op: broker.OpReverse, req: func(e *appTest) any {
+				return models.ReverseRequest{TransactionID: e.ids["setup"], Amount: ptr(money("9"))}
+			}, body: `"remaining":"90.00"`},
+			{op: broker.OpReverse, req: func(e *appTest) any { return models.ReverseRequest{TransactionID: e.ids["setup"]} },
+				body: `"amount":"90.00"`},
+			{op: broker.OpReverse, req: func(e *appTest) any { return models.ReverseRequest{TransactionID: e.ids["setup"]} },
+				errorCode: repository.ErrCodeAlreadyReversed},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}},
+	},
+	{
+		name: "disabled ticker",
+		steps: []appStep{
+			{op: broker.OpTickerStat, req: func(e *appTest) any { return models.SetTickerStatusRequest{Name: e.ticker("USD")} }},
+			{op: broker.OpInvoice, req: func(e *appTest) any { return e.invoice(walletA, "USD", "1") },
+				errorCode: repository.ErrCodeTickerDisabled},
+			{op: broker.OpWithdraw, req: func(e *appTest) any { return e.withdraw(walletA, "USD", "1") },
+				errorCode: repository.ErrCodeTickerDisabled},
+		},
+		actual: map[int]map[string]string{walletFee: {"USD": "1.00"}, walletA: {"USD": "99.00"}},
+	},
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	cases := []struct {
+		key  string
+		d    amqp.Delivery
+		want string
+	}{
+		{key: "request", d: amqp.Delivery{MessageId: "message", CorrelationId: "rpc"}, want: "request"},
+		{d: amqp.Delivery{MessageId: "message", CorrelationId: "rpc"}, want: "message"},
+		// CorrelationId переиспользуется RPC-клиентами, запрос без ключа и MessageId не идемпотентен
+		{d: amqp.Delivery{CorrelationId: "rpc"}, want: ""},
+	}
+	for _, tc := range cases {
+		if got := idempotencyKey(tc.key, &tc.d); got != tc.want {
+			t.Errorf("idempotencyKey(%q, %+v) = %q, want %q", tc.key, tc.d, got, tc.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// runAppCases выполняет сценарии appCases. newTest создаёт для сценария App поверх проверяемого репозитория.
+func runAppCases(t *testing.T, newTest func(t *testing.T) *appTest) {
+	for _, tc := range appCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTest(t)
+			for i, step := range tc.steps {
+				resp := e.call(step.op, step.req(e))
+				code := step.code
+				switch {
+				case code == 0 && step.errorCode != "":
+					code = http.StatusBadRequest
+				case code == 0:
+					code = http.StatusOK
+				}
+				if resp.Code != code || resp.ErrorCode != step.errorCode {
+					t.Fatalf("step %d %s: code %d (%q) %s; want %d (%q)",
+						i, step.op, resp.Code, resp.ErrorCode, resp.Reason, code, step.errorCode)
+				}
+				if !strings.Contains(resp.Body, step.body) {
+					t.Errorf("step %d %s: body %s doesn't contain %s", i, step.op, resp.Body, step.body)
+				}
+				if step.save != "" {
+					e.ids[step.save] = responseID(resp)
+				}
+			}
+
+			for _, wallet := range []int{walletFee, walletA, walletB} {
+				actual, frozen := e.balance(wallet)
+				if want := tc.actual[wallet]; fmt.Sprint(actual) != fmt.Sprint(orEmpty(want)) {
+					t.Errorf("actual balance of wallet %d = %v, want %v", wallet, actual, want)
+				}
+				if want := tc.frozen[wallet]; fmt.Sprint(frozen) != fmt.Sprint(orEmpty(want)) {
+					t.Errorf("frozen balance of wallet %d = %v, want %v", wallet, frozen, want)
+				}
+			}
+		})
+	}
+}
+
+func orEmpty(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+
+	return m
+}
+
+func TestAppOperationsMemory(t *testing.T) {
+	runAppCases(t, func(t *testing.T) *appTest {
+		repo := repository.NewMemoryRepo(1)
+		if _, err := repo.CreateWallet(context.Background()); err != nil {
+			t.Fatalf("CreateWallet: %v", err)
+		}
+
+		return newAppTest(t, repo, 1, "")
+	})
+}
+
+/*
+TestAppOperationsPostgres выполняет те же сценарии с PostgresRepo, так что лимиты, комиссии и коды ошибок
+MemoryRepo проверяются на совпадение с PostgresRepo. Запускается, если задан TEST_DB_HOST, остальные параметры
+подключения берутся из TEST_DB_PORT, TEST_DB_USER, TEST_DB_PASSWORD и TEST_DB_NAME. Сценарии не очищают бд,
+а используют новые кошельки и тикеры, поэтому их можно запускать на бд с данными.
+*/
+func TestAppOperationsPostgres(t *testing.T) {
+	cfg := &repository.Config{
+		Host:     os.Getenv("TEST_DB_HOST"),
+		Port:     os.Getenv("TEST_DB_PORT"),
+		Username: os.Getenv("TEST_DB_USER"),
+		Password: os.Getenv("TEST_DB_PASSWORD"),
+		DBName:   os.Getenv("TEST_DB_NAME"),
+		SSLMode:  "disable",
+	}
+	if cfg.Host == "" {
+		t.Skip("TEST_DB_HOST is not set")
+	}
+
+	// кошелёк комиссий создаётся до подключения, в котором он указан в настройках
+	setup, err := repository.NewPostgresRepo(cfg)
+	if err != nil {
+		t.Fatalf("NewPostgresRepo: %v", err)
+	}
+	feeWallet, err := setup.CreateWallet(context.Background())
+	_ = setup.Close()
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	cfg.FeeWalletID = feeWallet.WalletID
+	repo, err := repository.NewPostgresRepo(cfg)
+	if err != nil {
+		t.Fatalf("NewPostgresRepo: %v", err)
+	}
+	defer repo.Close()
+
+	runAppCases(t, func(t *testing.T) *appTest {
+		return newAppTest(t, repo, feeWallet.WalletID, fmt.Sprint(time.Now().UnixNano()%1e12))
+	})
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// archiveBatchSize количество транзакций, переносимых в архив одним запросом
+const archiveBatchSize = 1000
+
+// MinArchiveAge минимальный возраст архивируемых транзакций. Лимиты на списания считаются по действующим
+// транзакциям за текущий календарный месяц, поэтому в архив не должны попадать транзакции моложе месяца.
+const MinArchiveAge = 32 * 24 * time.Hour
+
+// RunTransactionArchiver запускает в фоне обслуживание таблицы transactions с периодом interval: создание разделов
+// следующих месяцев, перенос в архив завершённых транзакций, не менявшихся дольше age, и удаление опустевших разделов
+func (a *App) RunTransactionArchiver(ctx context.Context, interval, age time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// разделы должны быть готовы сразу после запуска, поэтому первый проход не ждёт interval
+			a.archiveTransactions(ctx, time.Now().Add(-age))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// archiveTransactions переносит в архив все транзакции, завершённые до before, пачками по archiveBatchSize
+func (a *App) archiveTransactions(ctx context.Context, before time.Time) {
+	total := 0
+	for ctx.Err() == nil {
+		archived, err := a.Repo.ArchiveTransactions(ctx, before, archiveBatchSize)
+		if err != nil {
+			log.Printf("Can't archive transactions: %v", err)
+			break
+		}
+		total += archived
+		if archived < archiveBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Archived %d transactions finished before %s", total, before.Format(time.RFC3339))
+	}
+
+	// разделы обслуживаются после архивации, чтобы сразу удалить опустевшие
+	dropped, err := a.Repo.MaintainTransactionPartitions(ctx, before)
+	if err != nil {
+		log.Printf("Can't maintain transaction partitions: %v", err)
+		return
+	}
+	if dropped > 0 {
+		log.Printf("Dropped %d empty transaction partitions", dropped)
+	}
+}
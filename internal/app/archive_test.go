@@ -0,0 +1,47 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/models"
+	"bwg_transactional_system/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArchiveTransactions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepo(0)
+	a := NewApp(repo, &fakeBroker{})
+	wallet, err := repo.CreateWallet(ctx)
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if _, err := repo.CreateTicker(ctx, &models.CreateTickerRequest{Name: "USD", Scale: 2}); err != nil {
+		t.Fatalf("CreateTicker: %v", err)
+	}
+
+	// транзакций больше, чем переносится одним запросом, архивация продолжается до последней пачки
+	ids := make([]int, 0, archiveBatchSize+1)
+	for i := 0; i <= archiveBatchSize; i++ {
+		resp, err := repo.Invoice(ctx, &models.InvoiceRequest{WalletID: wallet.WalletID, Ticker: "USD", Amount: money("1")})
+		if err != nil {
+			t.Fatalf("Invoice: %v", err)
+		}
+		ids = append(ids, resp.TransactionID)
+	}
+
+	// транзакции моложе порога не архивируются
+	a.archiveTransactions(ctx, time.Now().Add(-time.Hour))
+	if _, err := repo.Reverse(ctx, &models.ReverseRequest{TransactionID: ids[0]}); err != nil {
+		t.Fatalf("Reverse of an active transaction: %v", err)
+	}
+
+	a.archiveTransactions(ctx, time.Now().Add(time.Hour))
+	for _, id := range []int{ids[1], ids[len(ids)-1]} {
+		var e repository.LogicErrors
+		if _, err := repo.Reverse(ctx, &models.ReverseRequest{TransactionID: id}); !errors.As(err, &e) || e.Code != repository.ErrCodeNotReversible {
+			t.Fatalf("Reverse of archived transaction %d: %v, want %s", id, err, repository.ErrCodeNotReversible)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/broker"
+	"bwg_transactional_system/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type auditKey struct{}
+
+/*
+withAudit оборачивает обработчики так, что по каждому обработанному сообщению в журнал аудита
+записывается отправитель, запрос и результат. Запись делается до подтверждения сообщения брокеру: если её
+не удалось сохранить, обработчик возвращает ошибку и сообщение возвращается в очередь. При повторной доставке
+операции с ключом идемпотентности не выполняются заново, а повторяют сохранённый ответ, и запись добавляется снова.
+*/
+func (a *App) withAudit(handlers map[broker.Operation]operation) map[broker.Operation]broker.Handler {
+	audited := make(map[broker.Operation]broker.Handler, len(handlers))
+	for op, handler := range handlers {
+		handler := handler
+		audited[op] = func(ctx context.Context, d *amqp.Delivery) error {
+			sum := sha256.Sum256(d.Body)
+			record := &models.AuditRecord{
+				AppID:         d.AppId,
+				UserID:        d.UserId,
+				MessageID:     d.MessageId,
+				CorrelationID: d.CorrelationId,
+				RoutingKey:    d.RoutingKey,
+				Redelivered:   d.Redelivered,
+				BodySHA256:    hex.EncodeToString(sum[:]),
+			}
+			handler(context.WithValue(ctx, auditKey{}, record), d)
+
+			if err := a.Repo.RecordAudit(ctx, record); err != nil {
+				return fmt.Errorf("can't write audit record: %w", err)
+			}
+
+			return nil
+		}
+	}
+
+	return audited
+}
+
+// auditResult сохраняет в запись аудита текущего сообщения код ответа и id транзакции из ответа resp
+func auditResult(ctx context.Context, code int, errorCode string, resp any) {
+	record, ok := ctx.Value(auditKey{}).(*models.AuditRecord)
+	if !ok {
+		return
+	}
+	record.Code, record.ErrorCode = code, errorCode
+	record.TransactionID = responseTransactionID(resp)
+}
+
+// responseTransactionID возвращает id основной транзакции, созданной или изменённой операцией, или 0
+func responseTransactionID(resp any) int {
+	switch r := resp.(type) {
+	case *models.OperationResponse:
+		return r.TransactionID
+	case *models.TransferResponse:
+		return r.DebitTransactionID
+	case *models.ExchangeResponse:
+		return r.DebitTransactionID
+	case *models.ReverseResponse:
+		return r.TransactionID
+	case *models.BatchResponse:
+		// у пакета транзакций несколько, в запись попадает первая, остальные видны в ответе на пакет
+		for _, result := range r.Results {
+			if result.OperationResponse != nil {
+				return result.TransactionID
+			}
+			if result.TransferResponse != nil {
+				return result.DebitTransactionID
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/broker"
+	"bwg_transactional_system/internal/models"
+	"bwg_transactional_system/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"testing"
+)
+
+// failingAuditRepo репозиторий, в котором не удаётся записать первые failures записей аудита
+type failingAuditRepo struct {
+	repository.Repository
+	failures int
+}
+
+func (r *failingAuditRepo) RecordAudit(ctx context.Context, record *models.AuditRecord) error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("audit log is unavailable")
+	}
+
+	return r.Repository.RecordAudit(ctx, record)
+}
+
+func TestAuditFailureRequeuesMessage(t *testing.T) {
+	ctx := context.Background()
+	memory := repository.NewMemoryRepo(0)
+	repo := &failingAuditRepo{Repository: memory, failures: 1}
+	wallet, err := repo.CreateWallet(ctx)
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if _, err := repo.CreateTicker(ctx, &models.CreateTickerRequest{Name: "USD", Scale: 2}); err != nil {
+		t.Fatalf("CreateTicker: %v", err)
+	}
+
+	fb := &fakeBroker{}
+	NewApp(repo, fb).RunConsumer(ctx)
+	body, _ := json.Marshal(models.InvoiceRequest{WalletID: wallet.WalletID, Ticker: "USD", Amount: money("10")})
+	d := &amqp.Delivery{RoutingKey: string(broker.OpInvoice), Body: body, MessageId: "audit-1"}
+
+	// без записи аудита сообщение не подтверждается
+	if err := fb.handlers[broker.OpInvoice](ctx, d); err == nil {
+		t.Fatal("handler succeeded without audit record")
+	}
+
+	// повторная доставка не зачисляет средства второй раз, а запись аудита сохраняется
+	d.Redelivered = true
+	if err := fb.handlers[broker.OpInvoice](ctx, d); err != nil {
+		t.Fatalf("redelivered message: %v", err)
+	}
+
+	balance, err := repo.GetBalance(ctx, &models.GetBalanceRequest{WalletID: wallet.WalletID})
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if got := balance.ActualBalance["USD"].StringFixed(2); got != "10.00" {
+		t.Fatalf("balance = %s, want 10.00", got)
+	}
+
+	log, err := repo.ListAuditLog(ctx, &models.AuditLogRequest{})
+	if err != nil {
+		t.Fatalf("ListAuditLog: %v", err)
+	}
+	if len(log.Records) != 1 || !log.Records[0].Redelivered || log.Records[0].MessageID != "audit-1" {
+		t.Fatalf("audit log = %+v, want one record of the redelivered message", log.Records)
+	}
+}
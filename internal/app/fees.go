@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// feeCreditsBatchSize количество зачислений комиссий, переносимых на баланс кошелька комиссий одним запросом
+const feeCreditsBatchSize = 1000
+
+// RunFeeCredits запускает в фоне перенос накопленных зачислений комиссий на баланс кошелька комиссий с периодом interval.
+// Операции только добавляют зачисления, поэтому не конкурируют между собой за строку баланса кошелька комиссий.
+func (a *App) RunFeeCredits(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.applyFeeCredits(ctx)
+			}
+		}
+	}()
+}
+
+// applyFeeCredits переносит все накопившиеся зачисления комиссий пачками по feeCreditsBatchSize
+func (a *App) applyFeeCredits(ctx context.Context) {
+	for ctx.Err() == nil {
+		applied, err := a.Repo.ApplyFeeCredits(ctx, feeCreditsBatchSize)
+		if err != nil {
+			log.Printf("Can't apply fee credits: %v", err)
+			return
+		}
+		if applied < feeCreditsBatchSize {
+			return
+		}
+	}
+}
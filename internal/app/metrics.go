@@ -2,6 +2,7 @@ package app
 
 import (
 	"bwg_transactional_system/internal/broker"
+	"bwg_transactional_system/internal/models"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"strconv"
@@ -17,3 +18,54 @@ var respStatus = promauto.NewCounterVec(
 func accountMetrics(operation broker.Operation, status int) {
 	respStatus.WithLabelValues(string(operation), strconv.Itoa(status)).Inc()
 }
+
+var reconciliationRuns = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "reconciliation",
+		Name:      "runs_counter",
+	}, []string{"result"})
+
+var reconciliationMismatches = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "app",
+		Subsystem: "reconciliation",
+		Name:      "mismatches",
+		Help:      "Number of wallet balances that don't match the transaction log, by ticker",
+	}, []string{"ticker"})
+
+var reconciliationChecked = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "app",
+		Subsystem: "reconciliation",
+		Name:      "checked_balances",
+	})
+
+var reconciliationLastRun = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "app",
+		Subsystem: "reconciliation",
+		Name:      "last_run_timestamp_seconds",
+	})
+
+// accountReconciliation обновляет метрики по результату последней сверки
+func accountReconciliation(report *models.ReconciliationReport, err error) {
+	if err != nil {
+		reconciliationRuns.WithLabelValues("error").Inc()
+		return
+	}
+
+	result := "ok"
+	if !report.OK() {
+		result = "mismatch"
+	}
+	reconciliationRuns.WithLabelValues(result).Inc()
+
+	// сбрасываем значения прошлой сверки, чтобы исправленные тикеры не оставались в метрике
+	reconciliationMismatches.Reset()
+	for _, m := range report.Mismatches {
+		reconciliationMismatches.WithLabelValues(m.Ticker).Inc()
+	}
+	reconciliationChecked.Set(float64(report.Checked))
+	reconciliationLastRun.Set(float64(report.FinishedAt.Unix()))
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	// outboxBatchSize максимальное количество событий, которые публикуются за одну выборку
+	outboxBatchSize = 100
+	// outboxLease время, на которое выбранные события блокируются для публикации другими экземплярами сервиса
+	outboxLease = time.Minute
+)
+
+// RunOutboxRelay запускает в фоне публикацию событий о транзакциях из outbox в брокер с проверкой каждые interval.
+// Событие отмечается опубликованным только после подтверждения от брокера, поэтому каждое событие доставляется
+// хотя бы один раз, а повторы получатель отбрасывает по event_id.
+func (a *App) RunOutboxRelay(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.relayOutbox(ctx)
+			}
+		}
+	}()
+}
+
+// relayOutbox публикует все накопившиеся события пачками по outboxBatchSize
+func (a *App) relayOutbox(ctx context.Context) {
+	for ctx.Err() == nil {
+		events, err := a.Repo.ClaimOutboxEvents(ctx, outboxBatchSize, outboxLease)
+		if err != nil {
+			log.Printf("Can't claim outbox events: %v", err)
+			return
+		}
+
+		// публикация останавливается на первой ошибке, чтобы следующие события кошелька не обогнали неопубликованное,
+		// оставшиеся события выбираются снова после истечения блокировки
+		published := make([]int64, 0, len(events))
+		for _, event := range events {
+			body, err := json.Marshal(event)
+			if err == nil {
+				err = a.Broker.PublishEvent(ctx, event.Type, strconv.FormatInt(event.EventID, 10), body)
+			}
+			if err != nil {
+				log.Printf("Can't publish outbox event %d: %v", event.EventID, err)
+				break
+			}
+			published = append(published, event.EventID)
+		}
+
+		if err := a.Repo.MarkOutboxPublished(ctx, published); err != nil {
+			log.Printf("Can't mark outbox events as published: %v", err)
+			return
+		}
+		if len(published) < outboxBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Reconcile выполняет сверку балансов с журналом транзакций, обновляет метрики и пишет отчёт в лог
+func (a *App) Reconcile(ctx context.Context) (*models.ReconciliationReport, error) {
+	report, err := a.Repo.Reconcile(ctx)
+	accountReconciliation(report, err)
+	if err != nil {
+		log.Printf("Reconciliation failed: %v", err)
+		return nil, err
+	}
+
+	if report.OK() {
+		log.Printf("Reconciliation finished, checked %d balances, no mismatches", report.Checked)
+		return report, nil
+	}
+
+	// расхождения пишем одной строкой в JSON, чтобы их было удобно разбирать из логов
+	bytes, _ := json.Marshal(report)
+	log.Printf("Reconciliation found %d mismatches: %s", len(report.Mismatches), bytes)
+
+	return report, nil
+}
+
+// RunReconciliation запускает сверку в фоне каждые interval, пока не будет отменён ctx
+func (a *App) RunReconciliation(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = a.Reconcile(ctx)
+			}
+		}
+	}()
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/broker"
+	"bwg_transactional_system/internal/models"
+	"bwg_transactional_system/internal/repository"
+	"context"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"log"
+	"time"
+)
+
+const (
+	// scheduledBatchSize максимальное количество платежей, которые планировщик выбирает за одну проверку
+	scheduledBatchSize = 100
+	// scheduledLease время, на которое выбранный платёж блокируется для других экземпляров сервиса
+	scheduledLease = 5 * time.Minute
+)
+
+// RunScheduledPayments запускает в фоне выполнение запланированных платежей, время которых наступило,
+// с проверкой каждые interval. Результат каждого запуска отправляется в очередь replyQueue в том же виде,
+// что и ответ на запрос через брокера, CorrelationId ответа равен ключу идемпотентности запуска.
+// Если replyQueue пустая, то результаты только сохраняются в платеже.
+func (a *App) RunScheduledPayments(ctx context.Context, interval time.Duration, replyQueue string) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.runDueScheduledPayments(ctx, replyQueue)
+			}
+		}
+	}()
+}
+
+// runDueScheduledPayments выполняет все платежи, время которых наступило, пачками по scheduledBatchSize
+func (a *App) runDueScheduledPayments(ctx context.Context, replyQueue string) {
+	for ctx.Err() == nil {
+		payments, err := a.Repo.ClaimDueScheduledPayments(ctx, scheduledBatchSize, scheduledLease)
+		if err != nil {
+			log.Printf("Can't claim scheduled payments: %v", err)
+			return
+		}
+
+		for i := range payments {
+			a.runScheduledPayment(ctx, &payments[i], replyQueue)
+		}
+		if len(payments) < scheduledBatchSize {
+			return
+		}
+	}
+}
+
+/*
+runScheduledPayment выполняет один запуск платежа через те же методы репозитория, что и обработчики брокера.
+
+Ошибка бизнес-логики (например, нехватка средств) считается результатом запуска: она сохраняется в платеже,
+и платёж переходит к следующему запуску. При остальных ошибках запуск не завершается и повторяется
+после истечения блокировки с тем же ключом идемпотентности.
+*/
+func (a *App) runScheduledPayment(ctx context.Context, payment *models.ScheduledPayment, replyQueue string) {
+	key := payment.IdempotencyKey()
+	op := broker.Operation(payment.Operation)
+
+	var resp any
+	var transactionID int
+	var err error
+	switch payment.Operation {
+	case models.OperationInvoice:
+		var r *models.OperationResponse
+		if r, err = a.Repo.Invoice(ctx, &models.InvoiceRequest{
+			WalletID: payment.WalletID, Ticker: payment.Ticker, Amount: payment.Amount, IdempotencyKey: key,
+		}); err == nil {
+			resp, transactionID = r, r.TransactionID
+		}
+	case models.OperationWithdraw:
+		var r *models.OperationResponse
+		if r, err = a.scheduledWithdraw(ctx, &models.WithdrawRequest{
+			WalletID: payment.WalletID, Ticker: payment.Ticker, Amount: payment.Amount, IdempotencyKey: key,
+		}); err == nil {
+			resp, transactionID = r, r.TransactionID
+		}
+	case models.OperationTransfer:
+		var r *models.TransferResponse
+		if r, err = a.Repo.Transfer(ctx, &models.TransferRequest{
+			FromWalletID: payment.WalletID, ToWalletID: payment.ToWalletID, Ticker: payment.Ticker, Amount: payment.Amount,
+			IdempotencyKey: key,
+		}); err == nil {
+			resp, transactionID = r, r.DebitTransactionID
+		}
+	default:
+		err = models.ValidationScheduledOperationError
+	}
+
+	var logicErr repository.LogicErrors
+	if err != nil && !errors.As(err, &logicErr) {
+		log.Printf("Scheduled payment %d failed, will retry: %v", payment.ScheduledPaymentID, err)
+		return
+	}
+
+	result := &models.ScheduledRunResult{ScheduledPaymentID: payment.ScheduledPaymentID, Run: payment.Runs, TransactionID: transactionID}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if err := a.Repo.CompleteScheduledRun(ctx, result); err != nil {
+		log.Printf("Can't save result of scheduled payment %d: %v", payment.ScheduledPaymentID, err)
+		return
+	}
+
+	if replyQueue != "" {
+		a.processResponse(ctx, op, resp, err, &amqp.Delivery{RoutingKey: string(op), ReplyTo: replyQueue, CorrelationId: key})
+	}
+}
+
+/*
+scheduledWithdraw выполняет запланированное списание: замораживает сумму и сразу подтверждает списание,
+потому что подтверждать его через capture некому.
+
+Ключи идемпотентности различаются по операциям, поэтому заморозка и подтверждение используют ключ запуска,
+и повтор запуска после сбоя между ними подтверждает уже созданное замороженное списание. Если подтверждение
+отклонено, то заморозка отменяется, чтобы средства не остались замороженными.
+*/
+func (a *App) scheduledWithdraw(ctx context.Context, req *models.WithdrawRequest) (*models.OperationResponse, error) {
+	hold, err := a.Repo.WithDraw(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	holdReq := &models.HoldRequest{TransactionID: hold.TransactionID, IdempotencyKey: req.IdempotencyKey}
+	resp, err := a.Repo.Capture(ctx, holdReq)
+	var logicErr repository.LogicErrors
+	if errors.As(err, &logicErr) {
+		if _, releaseErr := a.Repo.Release(ctx, holdReq); releaseErr != nil && !errors.As(releaseErr, new(repository.LogicErrors)) {
+			return nil, releaseErr
+		}
+	}
+
+	return resp, err
+}
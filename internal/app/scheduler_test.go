@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/models"
+	"bwg_transactional_system/internal/repository"
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestScheduledPaymentRunsOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepo(0)
+	a := NewApp(repo, &fakeBroker{})
+	wallet, err := repo.CreateWallet(ctx)
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if _, err := repo.CreateTicker(ctx, &models.CreateTickerRequest{Name: "USD", Scale: 2}); err != nil {
+		t.Fatalf("CreateTicker: %v", err)
+	}
+	if _, err := repo.Invoice(ctx, &models.InvoiceRequest{WalletID: wallet.WalletID, Ticker: "USD", Amount: money("100")}); err != nil {
+		t.Fatalf("Invoice: %v", err)
+	}
+	payment, err := repo.CreateScheduledPayment(ctx, &models.CreateScheduledPaymentRequest{
+		Operation: models.OperationWithdraw, WalletID: wallet.WalletID, Ticker: "USD", Amount: money("10"),
+	})
+	if err != nil {
+		t.Fatalf("CreateScheduledPayment: %v", err)
+	}
+
+	// два экземпляра планировщика проверяют платежи одновременно, а потом ещё раз
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.runDueScheduledPayments(ctx, "")
+		}()
+	}
+	wg.Wait()
+	a.runDueScheduledPayments(ctx, "")
+
+	// экземпляр, который выбрал тот же запуск до сбоя, повторяет его с тем же ключом идемпотентности
+	a.runScheduledPayment(ctx, payment, "")
+
+	balance, err := repo.GetBalance(ctx, &models.GetBalanceRequest{WalletID: wallet.WalletID})
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if actual, frozen := balance.ActualBalance["USD"].StringFixed(2), balance.FrozenBalance["USD"].StringFixed(2); actual != "90.00" || frozen != "0.00" {
+		t.Fatalf("balance = %s/%s, want 90.00/0.00", actual, frozen)
+	}
+	history, err := repo.ListTransactions(ctx, &models.HistoryRequest{WalletID: wallet.WalletID})
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	if len(history.Transactions) != 2 {
+		t.Fatalf("transactions = %+v, want the invoice and one withdrawal", history.Transactions)
+	}
+
+	resp, err := repo.ListScheduledPayments(ctx, &models.ScheduledPaymentsRequest{WalletID: wallet.WalletID})
+	if err != nil {
+		t.Fatalf("ListScheduledPayments: %v", err)
+	}
+	got := resp.ScheduledPayments[0]
+	if got.Runs != 1 || got.Status != models.ScheduledPaymentCompleted || got.LastTransactionID != history.Transactions[0].ID || got.LastError != "" {
+		t.Fatalf("scheduled payment = %+v, want one successful run of transaction %d", got, history.Transactions[0].ID)
+	}
+}
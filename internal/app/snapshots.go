@@ -0,0 +1,33 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// snapshotLag запас времени после момента снимка, за который успевают завершиться транзакции бд, начатые до него
+const snapshotLag = 5 * time.Minute
+
+// RunBalanceSnapshots запускает в фоне создание снимков балансов на начало каждого периода interval
+// (для периода 24h - на полночь UTC). Снимки ускоряют получение балансов на момент в прошлом.
+func (a *App) RunBalanceSnapshots(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(min(interval, 10*time.Minute))
+		defer ticker.Stop()
+
+		for {
+			// снимок на уже существующий момент не пересоздаётся, поэтому проверять можно чаще чем раз в период
+			at := time.Now().Add(-snapshotLag).Truncate(interval)
+			if err := a.Repo.CreateBalanceSnapshot(ctx, at); err != nil {
+				log.Printf("Can't create balance snapshot at %s: %v", at.Format(time.RFC3339), err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// webhookBatchSize максимальное количество уведомлений, которые отправляются одновременно
+	webhookBatchSize = 20
+	// webhookTimeout время ожидания ответа адреса, после него попытка считается неудачной
+	webhookTimeout = 10 * time.Second
+	// webhookLease время, на которое выбранные доставки блокируются для других экземпляров сервиса
+	webhookLease = 2 * webhookTimeout
+)
+
+// Заголовки уведомления. Подпись считается как HMAC-SHA256 секретом адреса от строки "<timestamp>.<тело запроса>",
+// получатель проверяет её и отбрасывает повторы по id события.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookEventIDHeader   = "X-Webhook-Event-Id"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+var errWebhookAddressForbidden = errors.New("webhook address is not public")
+
+// webhookIPAllowed проверка адреса, к которому подключается клиент уведомлений. В тестах подменяется,
+// чтобы отправлять уведомления на локальный сервер.
+var webhookIPAllowed = models.WebhookIPAllowed
+
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		// прокси не используется: подключение шло бы к адресу прокси, и адрес получателя не проверялся бы
+		Proxy:               nil,
+		DialContext:         (&net.Dialer{Timeout: webhookTimeout, Control: controlWebhookDial}).DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+}
+
+// controlWebhookDial проверяет адрес непосредственно перед подключением, уже после разрешения имени.
+// Поэтому адрес внутренней сети не пройдёт ни через смену записи DNS после регистрации, ни через перенаправление.
+func controlWebhookDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhookIPAllowed(ip) {
+		return fmt.Errorf("%w: %s", errWebhookAddressForbidden, host)
+	}
+
+	return nil
+}
+
+// checkWebhookHost при регистрации адреса разрешает имя его хоста и отклоняет адреса внутренней сети,
+// чтобы ошибка была видна сразу, а не только в журнале доставок
+func checkWebhookHost(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return models.ValidationWebhookURLError
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("can't resolve webhook host %s: %v", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !webhookIPAllowed(addr.IP) {
+			return models.ValidationWebhookHostError
+		}
+	}
+
+	return nil
+}
+
+// WebhookSignature возвращает значение заголовка WebhookSignatureHeader для тела body, отправленного в timestamp
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RunWebhookDeliveries запускает в фоне отправку уведомлений о транзакциях, время попытки которых наступило,
+// с проверкой каждые interval
+func (a *App) RunWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.runDueWebhookDeliveries(ctx)
+			}
+		}
+	}()
+}
+
+// runDueWebhookDeliveries отправляет все уведомления, время попытки которых наступило, пачками по webhookBatchSize
+func (a *App) runDueWebhookDeliveries(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := a.Repo.ClaimWebhookDeliveries(ctx, webhookBatchSize, webhookLease)
+		if err != nil {
+			log.Printf("Can't claim webhook deliveries: %v", err)
+			return
+		}
+
+		wg := sync.WaitGroup{}
+		for i := range deliveries {
+			wg.Add(1)
+			go func(delivery *models.WebhookDelivery) {
+				defer wg.Done()
+				a.deliverWebhook(ctx, delivery)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliverWebhook выполняет одну попытку доставки и сохраняет её результат в журнал доставок
+func (a *App) deliverWebhook(ctx context.Context, delivery *models.WebhookDelivery) {
+	result := &models.WebhookAttemptResult{DeliveryID: delivery.DeliveryID, Attempt: delivery.Attempts + 1}
+	result.StatusCode, result.Error = postWebhook(ctx, delivery)
+	if !result.Succeeded() {
+		log.Printf("Webhook delivery %d to %s failed on attempt %d: status %d %s",
+			delivery.DeliveryID, delivery.URL, result.Attempt, result.StatusCode, result.Error)
+	}
+
+	if err := a.Repo.CompleteWebhookAttempt(ctx, result); err != nil {
+		log.Printf("Can't save result of webhook delivery %d: %v", delivery.DeliveryID, err)
+	}
+}
+
+// postWebhook отправляет подписанное уведомление и возвращает код ответа или текст ошибки запроса
+func postWebhook(ctx context.Context, delivery *models.WebhookDelivery) (int, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookEventIDHeader, strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	// тело ответа не нужно, но его чтение позволяет переиспользовать соединение
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return resp.StatusCode, ""
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"bwg_transactional_system/internal/models"
+	"bwg_transactional_system/internal/repository"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allowLoopbackWebhooks разрешает отправку уведомлений на httptest сервер на 127.0.0.1 до конца теста
+func allowLoopbackWebhooks(t *testing.T) {
+	t.Helper()
+	allowed := webhookIPAllowed
+	webhookIPAllowed = func(ip net.IP) bool { return ip.IsLoopback() || allowed(ip) }
+	t.Cleanup(func() { webhookIPAllowed = allowed })
+}
+
+func TestPostWebhookSignsRequest(t *testing.T) {
+	allowLoopbackWebhooks(t)
+
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, body = r.Header.Clone(), mustReadAll(t, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	delivery := &models.WebhookDelivery{
+		EventID:   42,
+		EventType: models.EventTransactionSucceeded,
+		Payload:   []byte(`{"event_id":42}`),
+		URL:       server.URL,
+		Secret:    "secret",
+	}
+	status, errText := postWebhook(context.Background(), delivery)
+	if status != http.StatusNoContent || errText != "" {
+		t.Fatalf("postWebhook = %d, %q; want 204 without error", status, errText)
+	}
+
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	if got := header.Get(WebhookEventHeader); got != delivery.EventType {
+		t.Errorf("%s = %q, want %q", WebhookEventHeader, got, delivery.EventType)
+	}
+	if got := header.Get(WebhookEventIDHeader); got != "42" {
+		t.Errorf("%s = %q, want 42", WebhookEventIDHeader, got)
+	}
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("%s: %v", WebhookTimestampHeader, err)
+	}
+	if got, want := header.Get(WebhookSignatureHeader), WebhookSignature("secret", timestamp, body); got != want {
+		t.Errorf("%s = %q, want %q", WebhookSignatureHeader, got, want)
+	}
+	if WebhookSignature("other", timestamp, body) == header.Get(WebhookSignatureHeader) {
+		t.Error("signature doesn't depend on secret")
+	}
+}
+
+func TestPostWebhookRejectsPrivateAddresses(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	status, errText := postWebhook(context.Background(), &models.WebhookDelivery{URL: server.URL, Payload: []byte("{}")})
+	if status != 0 || !strings.Contains(errText, errWebhookAddressForbidden.Error()) {
+		t.Errorf("postWebhook to loopback = %d, %q; want forbidden address error", status, errText)
+	}
+	if requested {
+		t.Error("request to loopback address was sent")
+	}
+}
+
+func TestCheckWebhookHost(t *testing.T) {
+	tests := []struct {
+		url string
+		err error
+	}{
+		{url: "http://127.0.0.1:8080/hook", err: models.ValidationWebhookHostError},
+		{url: "http://[::1]/hook", err: models.ValidationWebhookHostError},
+		{url: "http://169.254.169.254/latest/meta-data", err: models.ValidationWebhookHostError},
+		{url: "https://93.184.216.34/hook"},
+	}
+
+	for _, tt := range tests {
+		if err := checkWebhookHost(context.Background(), tt.url); !errors.Is(err, tt.err) {
+			t.Errorf("checkWebhookHost(%s) = %v, want %v", tt.url, err, tt.err)
+		}
+	}
+}
+
+func TestDeliverWebhookSchedulesRetry(t *testing.T) {
+	allowLoopbackWebhooks(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	repo := repository.NewMemoryRepo(0)
+	a := NewApp(repo, nil)
+	wallet, err := repo.CreateWallet(ctx)
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if _, err := repo.CreateTicker(ctx, &models.CreateTickerRequest{Name: "USD", Scale: 2}); err != nil {
+		t.Fatalf("CreateTicker: %v", err)
+	}
+	webhook, err := repo.CreateWebhook(ctx, &models.CreateWebhookRequest{URL: server.URL, Secret: "secret"})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	invoice := &models.InvoiceRequest{WalletID: wallet.WalletID, Ticker: "USD", Amount: models.MustParseMoney("10")}
+	if _, err := repo.Invoice(ctx, invoice); err != nil {
+		t.Fatalf("Invoice: %v", err)
+	}
+
+	before := time.Now()
+	a.runDueWebhookDeliveries(ctx)
+
+	resp, err := repo.ListWebhookDeliveries(ctx, &models.WebhookDeliveriesRequest{WebhookID: webhook.WebhookID})
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries: %v", err)
+	}
+	if len(resp.Deliveries) != 1 {
+		t.Fatalf("deliveries = %d, want 1", len(resp.Deliveries))
+	}
+	delivery := resp.Deliveries[0]
+	if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 ||
+		delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("delivery status %d, attempts %d, last code %d; want pending after 1 attempt with 500",
+			delivery.Status, delivery.Attempts, delivery.LastStatusCode)
+	}
+	if delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Before(before.Add(models.WebhookRetryBaseDelay)) ||
+		delivery.NextAttemptAt.After(time.Now().Add(models.WebhookRetryBaseDelay)) {
+		t.Errorf("next attempt at %v, want %v after the attempt", delivery.NextAttemptAt, models.WebhookRetryBaseDelay)
+	}
+}
+
+func mustReadAll(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	return body
+}
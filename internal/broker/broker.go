@@ -13,13 +13,64 @@ const (
 	OpInvoice    Operation = "invoice"
 	OpWithdraw   Operation = "withdraw"
 	OpGetBalance Operation = "balance"
+	OpTransfer   Operation = "transfer"
+	OpCapture    Operation = "capture"
+	OpRelease    Operation = "release"
+	OpHistory    Operation = "history"
+	OpExchange   Operation = "exchange"
+	OpSetRate    Operation = "set_exchange_rate"
+	OpGetRates   Operation = "exchange_rates"
+	OpGetWallet  Operation = "wallet"
+	OpSetStatus  Operation = "wallet_status"
+	OpSetLimit   Operation = "set_withdrawal_limit"
+	OpGetLimits  Operation = "withdrawal_limits"
+	OpDelLimit   Operation = "delete_withdrawal_limit"
+	OpSetFee     Operation = "set_fee"
+	OpGetFees    Operation = "fees"
+	OpDelFee     Operation = "delete_fee"
+	OpReconcile  Operation = "reconcile"
+	OpAddTicker  Operation = "create_ticker"
+	OpUpdTicker  Operation = "update_ticker"
+	OpTickerStat Operation = "ticker_status"
+	OpGetTickers Operation = "tickers"
+	OpAddCust    Operation = "create_customer"
+	OpGetCust    Operation = "customer"
+	OpAttachWal  Operation = "attach_wallet"
+	OpCustBal    Operation = "customer_balance"
+	OpReverse    Operation = "reverse"
+	OpSchedule   Operation = "schedule_payment"
+	OpGetSched   Operation = "scheduled_payments"
+	OpCancelSch  Operation = "cancel_scheduled_payment"
+	OpBatch      Operation = "batch"
+	OpAddHook    Operation = "create_webhook"
+	OpGetHooks   Operation = "webhooks"
+	OpDelHook    Operation = "delete_webhook"
+	OpHookLog    Operation = "webhook_deliveries"
+	OpAuditLog   Operation = "audit_log"
+	// OpUnknown обработчик сообщений, для routingKey которых нет своего обработчика.
+	// Очередь по нему не привязывается, поэтому в Operations его нет.
+	OpUnknown Operation = "unknown"
 )
 
-var Operations = []Operation{OpInvoice, OpWithdraw, OpGetBalance}
+var Operations = []Operation{
+	OpInvoice, OpWithdraw, OpGetBalance, OpTransfer, OpCapture, OpRelease, OpHistory,
+	OpExchange, OpSetRate, OpGetRates, OpGetWallet, OpSetStatus, OpSetLimit, OpGetLimits, OpDelLimit,
+	OpSetFee, OpGetFees, OpDelFee, OpReconcile, OpAddTicker, OpUpdTicker, OpTickerStat, OpGetTickers,
+	OpAddCust, OpGetCust, OpAttachWal, OpCustBal, OpReverse, OpSchedule, OpGetSched, OpCancelSch,
+	OpBatch, OpAddHook, OpGetHooks, OpDelHook, OpHookLog, OpAuditLog,
+}
+
+// Handler обработчик сообщения с конкретным routingKey. Если он вернул ошибку, сообщение не подтверждается
+// и возвращается в очередь для повторной обработки
+type Handler func(context.Context, *amqp.Delivery) error
 
 type Broker interface {
 	SendResponse(ctx context.Context, bytes []byte, d *amqp.Delivery)
-	RunConsumer(ctx context.Context, invoiceOp, withDrawOp, BalanceOp func(context.Context, *amqp.Delivery))
+	// DeclareQueue создаёт постоянную очередь name, если её ещё нет, чтобы ответы в неё не терялись без получателя
+	DeclareQueue(name string) error
+	// PublishEvent публикует событие в обменник событий и возвращает ошибку, если брокер не подтвердил его сохранение
+	PublishEvent(ctx context.Context, routingKey, messageID string, body []byte) error
+	RunConsumer(ctx context.Context, handlers map[Operation]Handler)
 	Close() error
 }
 
@@ -27,6 +78,8 @@ type ErrorResponse struct {
 	Operation string `json:"operation"`
 	Code      int    `json:"code"`
 	Reason    string `json:"reason"`
+	// ErrorCode код ошибки бизнес-логики, по которому клиент может отличить причину отказа
+	ErrorCode string `json:"error_code,omitempty"`
 }
 
 type SuccessResponse struct {
@@ -44,6 +97,16 @@ func NewBadRequestResponse(d *amqp.Delivery, err error) []byte {
 	return resp
 }
 
+func NewLogicErrorResponse(d *amqp.Delivery, errorCode string, err error) []byte {
+	resp, _ := json.Marshal(ErrorResponse{
+		Code:      http.StatusBadRequest,
+		Reason:    err.Error(),
+		Operation: d.RoutingKey,
+		ErrorCode: errorCode,
+	})
+	return resp
+}
+
 func NewErrorResponse(d *amqp.Delivery, httpStatus int, err error) []byte {
 	resp, _ := json.Marshal(ErrorResponse{
 		Code:      httpStatus,
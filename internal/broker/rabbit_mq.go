@@ -11,6 +11,9 @@ import (
 
 const ExchangeName = "queries"
 
+// EventsExchangeName обменник событий о транзакциях, routing key события равен его типу
+const EventsExchangeName = "events"
+
 type Config struct {
 	Port     string
 	Host     string
@@ -21,9 +24,11 @@ type Config struct {
 type RabbitMQ struct {
 	conn *amqp.Connection
 	ch   *amqp.Channel
-	msgs <-chan amqp.Delivery
-	wg   *sync.WaitGroup
-	stop chan struct{}
+	// events канал публикации событий в режиме подтверждений
+	events *amqp.Channel
+	msgs   <-chan amqp.Delivery
+	wg     *sync.WaitGroup
+	stop   chan struct{}
 }
 
 func NewRabbitMQ(cfg *Config) (*RabbitMQ, error) {
@@ -82,6 +87,27 @@ func NewRabbitMQ(cfg *Config) (*RabbitMQ, error) {
 			nil)
 	}
 
+	// события публикуются в отдельный канал, брокер подтверждает каждое сохранённое сообщение
+	events, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open an events channel: %v", err)
+	}
+	err = events.ExchangeDeclare(
+		EventsExchangeName, // name
+		"topic",            // type
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare an events exchange: %v", err)
+	}
+	if err := events.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to put events channel into confirm mode: %v", err)
+	}
+
 	msgs, err := ch.Consume(
 		q.Name, // queue
 		"",     // consumer
@@ -96,11 +122,12 @@ func NewRabbitMQ(cfg *Config) (*RabbitMQ, error) {
 	}
 
 	return &RabbitMQ{
-		conn: conn,
-		ch:   ch,
-		msgs: msgs,
-		wg:   &sync.WaitGroup{},
-		stop: make(chan struct{}),
+		conn:   conn,
+		ch:     ch,
+		events: events,
+		msgs:   msgs,
+		wg:     &sync.WaitGroup{},
+		stop:   make(chan struct{}),
 	}, nil
 }
 
@@ -122,8 +149,52 @@ func (b *RabbitMQ) SendResponse(ctx context.Context, bytes []byte, d *amqp.Deliv
 	log.Printf("Send response to: %s with body: %s", d.ReplyTo, bytes)
 }
 
+// PublishEvent публикует постоянное сообщение в обменник событий и ждёт подтверждения от брокера.
+// События публикуются последовательно через один канал, поэтому брокер сохраняет их порядок.
+func (b *RabbitMQ) PublishEvent(ctx context.Context, routingKey, messageID string, body []byte) error {
+	confirmation, err := b.events.PublishWithDeferredConfirmWithContext(ctx,
+		EventsExchangeName, // exchange
+		routingKey,         // routing key
+		false,              // mandatory
+		false,              // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    messageID,
+			Body:         body,
+		})
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("event %s was not confirmed by the broker", messageID)
+	}
+
+	return nil
+}
+
+func (b *RabbitMQ) DeclareQueue(name string) error {
+	if _, err := b.ch.QueueDeclare(
+		name,  // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %v", name, err)
+	}
+
+	return nil
+}
+
 // RunConsumer запускает ещё одного обработчика запросов, приходящих через брокера
-func (b *RabbitMQ) RunConsumer(ctx context.Context, invoiceOp, withDrawOp, BalanceOp func(context.Context, *amqp.Delivery)) {
+func (b *RabbitMQ) RunConsumer(ctx context.Context, handlers map[Operation]Handler) {
 	b.wg.Add(1)
 	go func() {
 		defer b.wg.Done()
@@ -131,17 +202,21 @@ func (b *RabbitMQ) RunConsumer(ctx context.Context, invoiceOp, withDrawOp, Balan
 			select {
 			case d := <-b.msgs:
 				log.Printf("Get message, routing key: %s, body: %s", d.RoutingKey, d.Body)
-				switch Operation(d.RoutingKey) {
-				case OpInvoice:
-					invoiceOp(ctx, &d)
-				case OpWithdraw:
-					withDrawOp(ctx, &d)
-				case OpGetBalance:
-					BalanceOp(ctx, &d)
-				default:
+				handler, ok := handlers[Operation(d.RoutingKey)]
+				if !ok {
+					handler, ok = handlers[OpUnknown]
+				}
+				if !ok {
 					b.SendResponse(ctx, NewBadRequestResponse(&d, fmt.Errorf("no such operation: %s", d.RoutingKey)), &d)
+					d.Ack(false)
+					continue
 				}
 
+				if err := handler(ctx, &d); err != nil {
+					log.Printf("Can't handle message %s (%s), returning it to the queue: %v", d.MessageId, d.RoutingKey, err)
+					d.Nack(false, true)
+					continue
+				}
 				d.Ack(false)
 			case <-b.stop:
 				return
@@ -162,6 +237,9 @@ func (b *RabbitMQ) Close() error {
 	}
 	b.wg.Wait()
 
+	if err := b.events.Close(); err != nil {
+		return err
+	}
 	if err := b.ch.Close(); err != nil {
 		return err
 	}
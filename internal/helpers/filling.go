@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"bwg_transactional_system/internal/models"
 	"bwg_transactional_system/internal/repository"
 	"context"
 	"fmt"
@@ -8,11 +9,17 @@ import (
 
 func FillTestData(repo repository.Repository) error {
 	ctx := context.Background()
-	tickers := []string{"USD", "RUB", "EUR", "USDT"}
+	// для каждой валюты указано количество знаков после запятой
+	tickers := []models.CreateTickerRequest{
+		{Name: "USD", DisplayName: "US Dollar", Scale: 2},
+		{Name: "RUB", DisplayName: "Russian Ruble", Scale: 2},
+		{Name: "EUR", DisplayName: "Euro", Scale: 2},
+		{Name: "USDT", DisplayName: "Tether", Scale: 6},
+	}
 	for _, t := range tickers {
-		err := repo.CreateTicker(ctx, t)
+		_, err := repo.CreateTicker(ctx, &t)
 		if err != nil {
-			return fmt.Errorf("can't create ticker %s: %v", t, err)
+			return fmt.Errorf("can't create ticker %s: %v", t.Name, err)
 		}
 	}
 	for i := 1; i < 10; i++ {
@@ -90,6 +90,7 @@ func (p *Producer) Invoice(ctx context.Context, id string, req models.InvoiceReq
 		amqp.Publishing{
 			ContentType:   "application/json",
 			CorrelationId: id,
+			MessageId:     id,
 			ReplyTo:       p.qName,
 			Body:          MustMarshal(req),
 		})
@@ -105,6 +106,55 @@ func (p *Producer) Withdraw(ctx context.Context, id string, req models.WithdrawR
 		amqp.Publishing{
 			ContentType:   "application/json",
 			CorrelationId: id,
+			MessageId:     id,
+			ReplyTo:       p.qName,
+			Body:          MustMarshal(req),
+		})
+	failOnError(err, "Failed to publish a message")
+}
+
+func (p *Producer) Transfer(ctx context.Context, id string, req models.TransferRequest) {
+	err := p.ch.PublishWithContext(ctx,
+		"queries",                 // exchange
+		string(broker.OpTransfer), // routing key
+		false,                     // mandatory
+		false,                     // immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: id,
+			MessageId:     id,
+			ReplyTo:       p.qName,
+			Body:          MustMarshal(req),
+		})
+	failOnError(err, "Failed to publish a message")
+}
+
+func (p *Producer) Capture(ctx context.Context, id string, req models.HoldRequest) {
+	err := p.ch.PublishWithContext(ctx,
+		"queries",                // exchange
+		string(broker.OpCapture), // routing key
+		false,                    // mandatory
+		false,                    // immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: id,
+			MessageId:     id,
+			ReplyTo:       p.qName,
+			Body:          MustMarshal(req),
+		})
+	failOnError(err, "Failed to publish a message")
+}
+
+func (p *Producer) Release(ctx context.Context, id string, req models.HoldRequest) {
+	err := p.ch.PublishWithContext(ctx,
+		"queries",                // exchange
+		string(broker.OpRelease), // routing key
+		false,                    // mandatory
+		false,                    // immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: id,
+			MessageId:     id,
 			ReplyTo:       p.qName,
 			Body:          MustMarshal(req),
 		})
@@ -120,6 +170,39 @@ func (p *Producer) GetBalance(ctx context.Context, id string, req models.GetBala
 		amqp.Publishing{
 			ContentType:   "application/json",
 			CorrelationId: id,
+			MessageId:     id,
+			ReplyTo:       p.qName,
+			Body:          MustMarshal(req),
+		})
+	failOnError(err, "Failed to publish a message")
+}
+
+func (p *Producer) History(ctx context.Context, id string, req models.HistoryRequest) {
+	err := p.ch.PublishWithContext(ctx,
+		"queries",                // exchange
+		string(broker.OpHistory), // routing key
+		false,                    // mandatory
+		false,                    // immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: id,
+			MessageId:     id,
+			ReplyTo:       p.qName,
+			Body:          MustMarshal(req),
+		})
+	failOnError(err, "Failed to publish a message")
+}
+
+func (p *Producer) Exchange(ctx context.Context, id string, req models.ExchangeRequest) {
+	err := p.ch.PublishWithContext(ctx,
+		"queries",                 // exchange
+		string(broker.OpExchange), // routing key
+		false,                     // mandatory
+		false,                     // immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: id,
+			MessageId:     id,
 			ReplyTo:       p.qName,
 			Body:          MustMarshal(req),
 		})
@@ -144,7 +227,7 @@ func RunTest1(cfg *broker.Config) {
 		producer.Invoice(ctx, id, models.InvoiceRequest{
 			WalletID: 1,
 			Ticker:   "USD",
-			Amount:   1,
+			Amount:   models.MoneyFromInt(1),
 		})
 	}
 
@@ -164,7 +247,7 @@ func RunTest1(cfg *broker.Config) {
 	producer.Withdraw(ctx, withDrawID, models.WithdrawRequest{
 		WalletID: 1,
 		Ticker:   "USD",
-		Amount:   50,
+		Amount:   models.MoneyFromInt(50),
 	})
 
 	log.Print("Wait for withdraw response")
@@ -202,7 +285,7 @@ func RunTest2(cfg *broker.Config) {
 		go producer.Invoice(ctx, id, models.InvoiceRequest{
 			WalletID: 1 + rand.Intn(8),
 			Ticker:   "USD",
-			Amount:   1,
+			Amount:   models.MoneyFromInt(1),
 		})
 	}
 
@@ -215,7 +298,7 @@ func RunTest2(cfg *broker.Config) {
 		go producer.Withdraw(ctx, id, models.WithdrawRequest{
 			WalletID: 1 + rand.Intn(8),
 			Ticker:   "USD",
-			Amount:   1,
+			Amount:   models.MoneyFromInt(1),
 		})
 	}
 
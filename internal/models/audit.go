@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ValidationAuditTransactionError = errors.New("transaction id must not be negative")
+
+// AuditRecord запись журнала аудита об одном обработанном сообщении: кто отправил запрос (AppID и UserID
+// из свойств сообщения AMQP), какой это был запрос и чем закончилась его обработка. Тело запроса не хранится,
+// вместо него сохраняется SHA-256, по которому можно проверить копию запроса у отправителя.
+type AuditRecord struct {
+	ID            int       `json:"id"`
+	AppID         string    `json:"app_id,omitempty"`
+	UserID        string    `json:"user_id,omitempty"`
+	MessageID     string    `json:"message_id,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	RoutingKey    string    `json:"routing_key"`
+	Redelivered   bool      `json:"redelivered,omitempty"`
+	BodySHA256    string    `json:"body_sha256"`
+	Code          int       `json:"code"`
+	ErrorCode     string    `json:"error_code,omitempty"`
+	TransactionID int       `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AuditLogRequest -> страница журнала аудита от новых записей к старым с фильтрами по отправителю,
+// операции, транзакции и времени обработки
+type AuditLogRequest struct {
+	AppID         string     `json:"app_id,omitempty"`
+	UserID        string     `json:"user_id,omitempty"`
+	RoutingKey    string     `json:"routing_key,omitempty"`
+	TransactionID int        `json:"transaction_id,omitempty"`
+	From          *time.Time `json:"from,omitempty"`
+	To            *time.Time `json:"to,omitempty"`
+	Cursor        string     `json:"cursor,omitempty"`
+	Limit         int        `json:"limit,omitempty"`
+}
+
+func (req *AuditLogRequest) Validate() error {
+	if req.TransactionID < 0 {
+		return ValidationAuditTransactionError
+	}
+	if req.Limit < 0 || req.Limit > MaxHistoryLimit {
+		return ValidationHistoryLimitError
+	}
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return ValidationTimeRangeError
+	}
+	if _, err := req.CursorID(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PageSize возвращает размер страницы с учётом значения по умолчанию
+func (req *AuditLogRequest) PageSize() int {
+	if req.Limit == 0 {
+		return DefaultHistoryLimit
+	}
+
+	return req.Limit
+}
+
+// CursorID возвращает id записи, после которой начинается страница, или 0 для первой страницы
+func (req *AuditLogRequest) CursorID() (int, error) {
+	history := HistoryRequest{Cursor: req.Cursor}
+	return history.CursorID()
+}
+
+// AuditLogResponse страница журнала аудита. NextCursor пустой, если это последняя страница.
+type AuditLogResponse struct {
+	Records    []AuditRecord `json:"records"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
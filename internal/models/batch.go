@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxBatchLegs максимальное количество операций в одном пакете, все они выполняются в одной транзакции в бд
+const MaxBatchLegs = 1000
+
+var (
+	ValidationBatchLegsError      = fmt.Errorf("batch must contain from 1 to %d legs", MaxBatchLegs)
+	ValidationBatchOperationError = errors.New("batch leg operation must be one of invoice, withdraw, transfer, exchange")
+	ValidationToTickerError       = errors.New("to_ticker is allowed only for exchange")
+)
+
+// BatchLeg одна операция пакета: зачисление или списание на кошелёк WalletID, перевод с WalletID на ToWalletID,
+// либо обмен Ticker на ToTicker в кошельке WalletID
+type BatchLeg struct {
+	Operation  string `json:"operation"`
+	WalletID   int    `json:"wallet_id"`
+	ToWalletID int    `json:"to_wallet_id,omitempty"`
+	Ticker     string `json:"ticker"`
+	ToTicker   string `json:"to_ticker,omitempty"`
+	Amount     Money  `json:"amount"`
+}
+
+func (leg *BatchLeg) Validate() error {
+	switch leg.Operation {
+	case OperationInvoice, OperationWithdraw:
+		if leg.ToWalletID != 0 {
+			return ValidationToWalletError
+		}
+	case OperationTransfer:
+		if leg.ToWalletID <= 0 {
+			return ValidationWalletIDError
+		}
+		if leg.ToWalletID == leg.WalletID {
+			return ValidationSameWalletError
+		}
+	case OperationExchange:
+		if leg.ToWalletID != 0 {
+			return ValidationToWalletError
+		}
+		if leg.ToTicker == leg.Ticker {
+			return ValidationSameTickerError
+		}
+	default:
+		return ValidationBatchOperationError
+	}
+	if leg.Operation != OperationExchange && leg.ToTicker != "" {
+		return ValidationToTickerError
+	}
+	if leg.WalletID <= 0 {
+		return ValidationWalletIDError
+	}
+	return validateAmount(leg.Amount)
+}
+
+func (leg *BatchLeg) InvoiceRequest() *InvoiceRequest {
+	return &InvoiceRequest{WalletID: leg.WalletID, Ticker: leg.Ticker, Amount: leg.Amount}
+}
+
+func (leg *BatchLeg) WithdrawRequest() *WithdrawRequest {
+	return &WithdrawRequest{WalletID: leg.WalletID, Ticker: leg.Ticker, Amount: leg.Amount}
+}
+
+func (leg *BatchLeg) TransferRequest() *TransferRequest {
+	return &TransferRequest{FromWalletID: leg.WalletID, ToWalletID: leg.ToWalletID, Ticker: leg.Ticker, Amount: leg.Amount}
+}
+
+func (leg *BatchLeg) ExchangeRequest() *ExchangeRequest {
+	return &ExchangeRequest{WalletID: leg.WalletID, FromTicker: leg.Ticker, ToTicker: leg.ToTicker, Amount: leg.Amount}
+}
+
+// BatchRequest -> пакет зачислений, списаний, переводов и обменов, которые выполняются по порядку по принципу «всё или ничего»:
+// если хотя бы одна операция не прошла, то не применяется ни одна. Ключ идемпотентности относится ко всему пакету.
+type BatchRequest struct {
+	Legs           []BatchLeg `json:"legs"`
+	IdempotencyKey string     `json:"idempotency_key,omitempty"`
+}
+
+func (req *BatchRequest) Validate() error {
+	if len(req.Legs) == 0 || len(req.Legs) > MaxBatchLegs {
+		return ValidationBatchLegsError
+	}
+	for i := range req.Legs {
+		if err := req.Legs[i].Validate(); err != nil {
+			return fmt.Errorf("leg %d: %w", i, err)
+		}
+	}
+
+	return validateIdempotencyKey(req.IdempotencyKey)
+}
+
+// BatchLegResult результат одной операции пакета в том же виде, что и ответ на отдельную операцию.
+// Для обмена id транзакций списания и зачисления лежат в TransferResponse, а курс и зачисленная сумма в ExchangeResult.
+type BatchLegResult struct {
+	Operation string `json:"operation"`
+	*OperationResponse
+	*TransferResponse
+	*ExchangeResult
+}
+
+// SetExchange заполняет результат операции обмена
+func (r *BatchLegResult) SetExchange(resp *ExchangeResponse) {
+	r.TransferResponse, r.ExchangeResult = &resp.TransferResponse, &resp.ExchangeResult
+}
+
+// BatchResponse тело успешного ответа на пакет, результаты идут в порядке операций в запросе
+type BatchResponse struct {
+	Results []BatchLegResult `json:"results"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxCustomerFieldLength максимальная длина имени и внешнего id клиента, ограничена размером колонок в бд
+const MaxCustomerFieldLength = 255
+
+var (
+	ValidationCustomerIDError    = errors.New("customer id must be positive")
+	ValidationCustomerFieldError = fmt.Errorf("customer name and external id must not be longer then %d characters", MaxCustomerFieldLength)
+)
+
+// Customer владелец кошельков. ExternalID - необязательный уникальный id клиента во внешней системе,
+// по которому её записи сопоставляются с клиентами транзакционной системы.
+type Customer struct {
+	CustomerID int       `json:"customer_id"`
+	ExternalID string    `json:"external_id,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	WalletIDs  []int     `json:"wallet_ids"`
+}
+
+// CreateCustomerRequest -> создание клиента без кошельков
+type CreateCustomerRequest struct {
+	ExternalID string `json:"external_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+func (req *CreateCustomerRequest) Validate() error {
+	if len(req.ExternalID) > MaxCustomerFieldLength || len(req.Name) > MaxCustomerFieldLength {
+		return ValidationCustomerFieldError
+	}
+
+	return nil
+}
+
+// GetCustomerRequest -> получение клиента со списком его кошельков или их общего баланса
+type GetCustomerRequest struct {
+	CustomerID int `json:"customer_id"`
+}
+
+func (req *GetCustomerRequest) Validate() error {
+	if req.CustomerID <= 0 {
+		return ValidationCustomerIDError
+	}
+
+	return nil
+}
+
+// AttachWalletRequest -> привязка кошелька к клиенту. Кошелёк может принадлежать только одному клиенту,
+// повторная привязка к тому же клиенту ничего не меняет.
+type AttachWalletRequest struct {
+	CustomerID int `json:"customer_id"`
+	WalletID   int `json:"wallet_id"`
+}
+
+func (req *AttachWalletRequest) Validate() error {
+	if req.CustomerID <= 0 {
+		return ValidationCustomerIDError
+	}
+	if req.WalletID <= 0 {
+		return ValidationWalletIDError
+	}
+
+	return nil
+}
+
+// CustomerBalanceResponse актуальный и замороженный баланс, сложенные по всем кошелькам клиента
+type CustomerBalanceResponse struct {
+	CustomerID    int              `json:"customer_id"`
+	WalletIDs     []int            `json:"wallet_ids"`
+	ActualBalance map[string]Money `json:"actual_balance,omitempty"`
+	FrozenBalance map[string]Money `json:"frozen_balance,omitempty"`
+}
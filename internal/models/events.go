@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Типы событий о транзакциях, тип события используется как routing key в обменнике событий
+const (
+	// EventTransactionCreated создана запись о транзакции в любом статусе
+	EventTransactionCreated = "transaction.created"
+	// EventTransactionSucceeded транзакция перешла в статус Success или создана сразу в нём
+	EventTransactionSucceeded = "transaction.succeeded"
+	// EventTransactionFailed транзакция перешла в статус Error или создана сразу в нём
+	EventTransactionFailed = "transaction.failed"
+)
+
+// TransactionEvent событие об изменении транзакции, которое сохраняется в outbox в той же транзакции в бд,
+// что и само изменение, и затем публикуется в брокер.
+// EventID растёт вместе с порядком публикации, Sequence номер события кошелька WalletID без пропусков,
+// по нему получатель может восстановить порядок и отбросить повторы. Номер назначается при публикации в брокер,
+// поэтому в теле уведомлений на адреса webhook его нет.
+type TransactionEvent struct {
+	EventID             int64             `json:"event_id"`
+	Type                string            `json:"type"`
+	WalletID            int               `json:"wallet_id"`
+	Sequence            int64             `json:"sequence,omitempty"`
+	TransactionID       int               `json:"transaction_id"`
+	Ticker              string            `json:"ticker"`
+	Amount              Money             `json:"amount"`
+	Status              TransactionStatus `json:"status"`
+	Operation           string            `json:"operation,omitempty"`
+	LinkedTransactionID int               `json:"linked_transaction_id,omitempty"`
+	OccurredAt          time.Time         `json:"occurred_at"`
+}
+
+// TransactionEventTypes возвращает события о транзакции в статусе status: при создании created = true
+// это создание транзакции и, если статус финальный, его событие, при изменении только событие финального статуса
+func TransactionEventTypes(status TransactionStatus, created bool) []string {
+	types := make([]string, 0, 2)
+	if created {
+		types = append(types, EventTransactionCreated)
+	}
+	switch status {
+	case TransactionStatusSuccess:
+		types = append(types, EventTransactionSucceeded)
+	case TransactionStatusError:
+		types = append(types, EventTransactionFailed)
+	}
+
+	return types
+}
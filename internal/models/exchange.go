@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ValidationSameTickerError = errors.New("source and destination tickers are the same")
+	ValidationRateError       = errors.New("exchange rate must be positive")
+)
+
+// ExchangeRequest -> обмен средств одной валюты на другую в рамках одного кошелька по курсу из таблицы exchange_rates.
+// Amount - количество списываемых средств в валюте FromTicker.
+type ExchangeRequest struct {
+	WalletID       int    `json:"wallet_id"`
+	FromTicker     string `json:"from_ticker"`
+	ToTicker       string `json:"to_ticker"`
+	Amount         Money  `json:"amount"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (req *ExchangeRequest) Validate() error {
+	if err := validateAmount(req.Amount); err != nil {
+		return err
+	}
+	if req.FromTicker == req.ToTicker {
+		return ValidationSameTickerError
+	}
+
+	return validateIdempotencyKey(req.IdempotencyKey)
+}
+
+// ExchangeResponse тело успешного ответа на обмен: id связанных транзакций списания и зачисления,
+// применённый курс и зачисленная сумма, округлённая до точности тикера ToTicker
+type ExchangeResponse struct {
+	TransferResponse
+	ExchangeResult
+}
+
+// ExchangeResult применённый курс и зачисленная сумма, отдельно от id транзакций для результата операции пакета
+type ExchangeResult struct {
+	Rate         Money `json:"rate"`
+	CreditAmount Money `json:"credit_amount"`
+}
+
+// ExchangeRate курс обмена: за единицу FromTicker начисляется Rate единиц ToTicker
+type ExchangeRate struct {
+	FromTicker string    `json:"from_ticker"`
+	ToTicker   string    `json:"to_ticker"`
+	Rate       Money     `json:"rate"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (req *ExchangeRate) Validate() error {
+	if !req.Rate.IsPositive() {
+		return ValidationRateError
+	}
+	if !req.Rate.InRange() {
+		return ValidationAmountRangeError
+	}
+	if req.FromTicker == req.ToTicker {
+		return ValidationSameTickerError
+	}
+
+	return nil
+}
+
+type ExchangeRatesResponse struct {
+	Rates []ExchangeRate `json:"rates"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// OperationFee операция транзакций комиссии: списания комиссии с кошелька клиента и зачисления на кошелёк комиссий
+const OperationFee = "fee"
+
+var (
+	ValidationFeeOperationError = errors.New("fees can be set only for invoice and withdraw operations")
+	ValidationFeeError          = errors.New("fee values must not be negative")
+	ValidationFeeRateError      = errors.New("fee rate must be lower then 1")
+	ValidationFeeRangeError     = errors.New("min fee must not be greater then max fee")
+)
+
+// FeeRule правило расчёта комиссии для операции по тикеру: Fixed + Amount * Rate, ограниченное снизу Min и сверху Max.
+// Rate задаётся долей от суммы операции, например "0.015" для комиссии в 1.5%.
+type FeeRule struct {
+	Operation string     `json:"operation"`
+	Ticker    string     `json:"ticker"`
+	Fixed     Money      `json:"fixed"`
+	Rate      Money      `json:"rate"`
+	Min       *Money     `json:"min,omitempty"`
+	Max       *Money     `json:"max,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func (req *FeeRule) Validate() error {
+	if req.Operation != OperationInvoice && req.Operation != OperationWithdraw {
+		return ValidationFeeOperationError
+	}
+	for _, value := range []*Money{&req.Fixed, &req.Rate, req.Min, req.Max} {
+		if value != nil && value.Sign() < 0 {
+			return ValidationFeeError
+		}
+		if value != nil && !value.InRange() {
+			return ValidationAmountRangeError
+		}
+	}
+	if req.Rate.Cmp(MoneyFromInt(1)) >= 0 {
+		return ValidationFeeRateError
+	}
+	if req.Min != nil && req.Max != nil && req.Min.Cmp(*req.Max) > 0 {
+		return ValidationFeeRangeError
+	}
+
+	return nil
+}
+
+// Calculate считает комиссию для суммы amount с точностью тикера scale, процентная часть округляется банковским округлением
+func (req *FeeRule) Calculate(amount Money, scale int) Money {
+	fee := req.Fixed.Add(amount.Mul(req.Rate, scale, RoundHalfEven))
+	if req.Min != nil && fee.Cmp(*req.Min) < 0 {
+		fee = *req.Min
+	}
+	if req.Max != nil && fee.Cmp(*req.Max) > 0 {
+		fee = *req.Max
+	}
+
+	return fee.Round(scale, RoundHalfEven)
+}
+
+// DeleteFeeRequest -> удаление правила расчёта комиссии для операции по тикеру
+type DeleteFeeRequest struct {
+	Operation string `json:"operation"`
+	Ticker    string `json:"ticker"`
+}
+
+func (req *DeleteFeeRequest) Validate() error {
+	if req.Operation != OperationInvoice && req.Operation != OperationWithdraw {
+		return ValidationFeeOperationError
+	}
+
+	return nil
+}
+
+type FeesResponse struct {
+	Fees []FeeRule `json:"fees"`
+}
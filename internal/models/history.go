@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultHistoryLimit количество транзакций на одной странице истории, если limit не указан
+	DefaultHistoryLimit = 50
+	// MaxHistoryLimit максимальное количество транзакций на одной странице истории
+	MaxHistoryLimit = 500
+)
+
+var (
+	ValidationWalletIDError     = errors.New("wallet id must be positive")
+	ValidationHistoryLimitError = fmt.Errorf("limit must be between 0 and %d", MaxHistoryLimit)
+	ValidationTimeRangeError    = errors.New("from must be before to")
+	ValidationCursorError       = errors.New("invalid cursor")
+	ValidationStatusError       = errors.New("unknown transaction status")
+)
+
+// HistoryRequest -> получение истории транзакций кошелька, от новых к старым.
+// Все фильтры необязательные: Ticker - код валюты, Status - статус транзакции ("Success", "Error", "Created"),
+// From и To - границы времени создания транзакции [From, To).
+// Cursor - значение NextCursor из предыдущей страницы ответа, Limit - размер страницы.
+type HistoryRequest struct {
+	WalletID int                `json:"wallet_id"`
+	Ticker   string             `json:"ticker,omitempty"`
+	Status   *TransactionStatus `json:"status,omitempty"`
+	From     *time.Time         `json:"from,omitempty"`
+	To       *time.Time         `json:"to,omitempty"`
+	Cursor   string             `json:"cursor,omitempty"`
+	Limit    int                `json:"limit,omitempty"`
+}
+
+func (req *HistoryRequest) Validate() error {
+	if req.WalletID <= 0 {
+		return ValidationWalletIDError
+	}
+	if req.Limit < 0 || req.Limit > MaxHistoryLimit {
+		return ValidationHistoryLimitError
+	}
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return ValidationTimeRangeError
+	}
+	if _, err := req.CursorID(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PageSize возвращает размер страницы с учётом значения по умолчанию
+func (req *HistoryRequest) PageSize() int {
+	if req.Limit == 0 {
+		return DefaultHistoryLimit
+	}
+
+	return req.Limit
+}
+
+// CursorID возвращает id транзакции, после которой начинается страница, или 0 для первой страницы
+func (req *HistoryRequest) CursorID() (int, error) {
+	if req.Cursor == "" {
+		return 0, nil
+	}
+
+	bytes, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+	if err != nil {
+		return 0, ValidationCursorError
+	}
+	id, err := strconv.Atoi(string(bytes))
+	if err != nil || id <= 0 {
+		return 0, ValidationCursorError
+	}
+
+	return id, nil
+}
+
+// EncodeCursor кодирует id последней транзакции на странице в курсор для получения следующей страницы
+func EncodeCursor(transactionID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(transactionID)))
+}
+
+// TransactionInfo транзакция в ответе на запрос истории
+type TransactionInfo struct {
+	ID        int               `json:"id"`
+	Ticker    string            `json:"ticker"`
+	Amount    Money             `json:"amount"`
+	Status    TransactionStatus `json:"status"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// HistoryResponse страница истории транзакций. NextCursor пустой, если это последняя страница.
+type HistoryResponse struct {
+	Transactions []TransactionInfo `json:"transactions"`
+	NextCursor   string            `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Системные счета журнала проводок. Средства кошельков учитываются на счетах кошельков,
+// системные счета отражают движение средств за пределами кошельков.
+const (
+	// AccountCashIn внешний счёт, с которого поступают средства при зачислении (invoice)
+	AccountCashIn = "cash_in"
+	// AccountCashOut внешний счёт, на который уходят подтверждённые списания (withdraw + capture)
+	AccountCashOut = "cash_out"
+	// AccountHolds счёт замороженных списаний до capture или release
+	AccountHolds = "holds"
+	// AccountExchange счёт обменной позиции, через который проходит обмен валют
+	AccountExchange = "exchange"
+	// AccountOpeningBalance счёт, против которого проведены транзакции, созданные до появления журнала
+	AccountOpeningBalance = "opening_balance"
+)
+
+var ErrEmptyJournalEntry = errors.New("journal entry must have postings")
+
+// Posting проводка по счёту кошелька (WalletID) или системному счёту (Account) в одном тикере.
+// Положительная сумма увеличивает остаток счёта, отрицательная уменьшает.
+// TransactionID связывает проводку по кошельку с записью в таблице transactions.
+type Posting struct {
+	WalletID      int
+	Account       string
+	TickerID      int
+	Amount        Money
+	TransactionID int
+}
+
+// JournalEntry запись журнала: набор проводок одной операции, сумма которых по каждому тикеру равна нулю
+type JournalEntry struct {
+	ID        int
+	Operation string
+	Postings  []Posting
+}
+
+// WalletPosting проводка по кошельку из записи transaction на сумму amount
+func (e *JournalEntry) WalletPosting(transaction *Transaction, amount Money) *JournalEntry {
+	e.Postings = append(e.Postings, Posting{
+		WalletID:      transaction.WalletID,
+		TickerID:      transaction.TickerID,
+		Amount:        amount,
+		TransactionID: transaction.ID,
+	})
+
+	return e
+}
+
+// SystemPosting проводка по системному счёту account
+func (e *JournalEntry) SystemPosting(account string, tickerID int, amount Money) *JournalEntry {
+	e.Postings = append(e.Postings, Posting{Account: account, TickerID: tickerID, Amount: amount})
+
+	return e
+}
+
+// Validate проверяет что запись сбалансирована: сумма проводок по каждому тикеру равна нулю
+func (e *JournalEntry) Validate() error {
+	if len(e.Postings) == 0 {
+		return ErrEmptyJournalEntry
+	}
+
+	sums := make(map[int]Money)
+	for _, posting := range e.Postings {
+		sums[posting.TickerID] = sums[posting.TickerID].Add(posting.Amount)
+	}
+	for tickerID, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("journal entry for the %s operation is not balanced: postings of ticker %d sum to %s",
+				e.Operation, tickerID, sum)
+		}
+	}
+
+	return nil
+}
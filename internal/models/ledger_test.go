@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJournalEntryValidate(t *testing.T) {
+	transaction := &Transaction{ID: 1, WalletID: 1, TickerID: 1}
+	tests := []struct {
+		name  string
+		entry *JournalEntry
+		valid bool
+	}{
+		{
+			name: "invoice",
+			entry: (&JournalEntry{Operation: OperationInvoice}).
+				WalletPosting(transaction, MustParseMoney("10")).
+				SystemPosting(AccountCashIn, 1, MustParseMoney("-10")),
+			valid: true,
+		},
+		{
+			name: "exchange",
+			entry: (&JournalEntry{Operation: OperationExchange}).
+				WalletPosting(transaction, MustParseMoney("-10")).
+				SystemPosting(AccountExchange, 1, MustParseMoney("10")).
+				SystemPosting(AccountExchange, 2, MustParseMoney("-9")).
+				WalletPosting(&Transaction{ID: 2, WalletID: 1, TickerID: 2}, MustParseMoney("9")),
+			valid: true,
+		},
+		{
+			name: "unbalanced",
+			entry: (&JournalEntry{Operation: OperationInvoice}).
+				WalletPosting(transaction, MustParseMoney("10")).
+				SystemPosting(AccountCashIn, 1, MustParseMoney("-9.99999999")),
+		},
+		{
+			// суммы сходятся в целом, но не по каждому тикеру
+			name: "balanced across tickers",
+			entry: (&JournalEntry{Operation: OperationExchange}).
+				WalletPosting(transaction, MustParseMoney("-10")).
+				WalletPosting(&Transaction{ID: 2, WalletID: 1, TickerID: 2}, MustParseMoney("10")),
+		},
+	}
+
+	for _, tt := range tests {
+		if err := tt.entry.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+
+	if err := (&JournalEntry{Operation: OperationInvoice}).Validate(); !errors.Is(err, ErrEmptyJournalEntry) {
+		t.Errorf("empty entry: Validate() = %v, want %v", err, ErrEmptyJournalEntry)
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ValidationLimitError      = errors.New("limits must be positive")
+	ValidationEmptyLimitError = errors.New("at least one limit must be set")
+)
+
+// WithdrawalLimit ограничения на списания по тикеру. Если WalletID не указан, то ограничения действуют для всех
+// кошельков, у которых нет собственных ограничений по этому тикеру.
+// MaxSingle - максимальная сумма одного списания, Daily и Monthly - максимальная сумма списаний за текущие
+// календарные сутки и месяц. Неуказанное ограничение не проверяется.
+type WithdrawalLimit struct {
+	WalletID  int        `json:"wallet_id,omitempty"`
+	Ticker    string     `json:"ticker"`
+	MaxSingle *Money     `json:"max_single,omitempty"`
+	Daily     *Money     `json:"daily,omitempty"`
+	Monthly   *Money     `json:"monthly,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func (req *WithdrawalLimit) Validate() error {
+	if req.WalletID < 0 {
+		return ValidationWalletIDError
+	}
+	if req.MaxSingle == nil && req.Daily == nil && req.Monthly == nil {
+		return ValidationEmptyLimitError
+	}
+	for _, limit := range []*Money{req.MaxSingle, req.Daily, req.Monthly} {
+		if limit != nil && !limit.IsPositive() {
+			return ValidationLimitError
+		}
+		if limit != nil && !limit.InRange() {
+			return ValidationAmountRangeError
+		}
+	}
+
+	return nil
+}
+
+// WithdrawalLimitsRequest -> получение ограничений на списания. Если WalletID не указан, то возвращаются
+// все ограничения, иначе ограничения, которые действуют для кошелька.
+type WithdrawalLimitsRequest struct {
+	WalletID int `json:"wallet_id,omitempty"`
+}
+
+func (req *WithdrawalLimitsRequest) Validate() error {
+	if req.WalletID < 0 {
+		return ValidationWalletIDError
+	}
+
+	return nil
+}
+
+// DeleteWithdrawalLimitRequest -> удаление ограничений кошелька (или общих, если WalletID не указан) по тикеру
+type DeleteWithdrawalLimitRequest struct {
+	WalletID int    `json:"wallet_id,omitempty"`
+	Ticker   string `json:"ticker"`
+}
+
+func (req *DeleteWithdrawalLimitRequest) Validate() error {
+	if req.WalletID < 0 {
+		return ValidationWalletIDError
+	}
+
+	return nil
+}
+
+type WithdrawalLimitsResponse struct {
+	Limits []WithdrawalLimit `json:"limits"`
+}
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MoneyScale максимальное количество знаков после запятой, с которым хранятся суммы.
+// Совпадает с точностью колонок numeric(30, 8) в бд.
+const MoneyScale = 8
+
+// moneyIntDigits количество знаков до запятой в колонках numeric(30, 8)
+const moneyIntDigits = 30 - MoneyScale
+
+// maxParseDigits ограничивает длину целой части в ParseMoney, чтобы не разбирать произвольно длинные строки.
+// Суммы больше диапазона колонок разбираются, чтобы результат вычислений в бд можно было прочитать,
+// но запросы с такими суммами отклоняются при проверке.
+const maxParseDigits = 64
+
+var (
+	ErrMoneyFormat    = errors.New("amount must be a decimal number")
+	ErrMoneyPrecision = fmt.Errorf("amount has more then %d digits after the decimal point", MoneyScale)
+	ErrMoneyOverflow  = errors.New("amount is out of range")
+)
+
+var pow10 = [MoneyScale + 1]int64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000}
+
+// unitsLimit первое количество минимальных единиц, которое не помещается в колонки numeric(30, 8)
+var unitsLimit = new(big.Int).Exp(big.NewInt(10), big.NewInt(moneyIntDigits+MoneyScale), nil)
+
+// RoundingMode правило округления суммы до точности тикера
+type RoundingMode int
+
+const (
+	// RoundHalfEven банковское округление, используется для вычисляемых сумм (комиссий, обмена валют)
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp половина округляется от нуля
+	RoundHalfUp
+	// RoundDown отбрасывает лишние знаки (округление к нулю)
+	RoundDown
+)
+
+// Money десятичная денежная сумма без погрешностей чисел с плавающей точкой.
+// Хранится как целое число минимальных единиц с точностью MoneyScale знаков после запятой,
+// в JSON кодируется строкой, в бд хранится в колонках типа numeric.
+// Целое число не ограничено по размеру, поэтому сложение и умножение не переполняются, а выход за диапазон
+// колонок проверяет InRange. Значение units не меняется после создания суммы, операции возвращают новые суммы.
+// Суммы, пришедшие в запросах, не округляются: если знаков после запятой больше, чем точность тикера,
+// то запрос отклоняется. Вычисляемые суммы округляются до точности тикера явно через Round или Mul.
+type Money struct {
+	// units nil для нулевой суммы
+	units *big.Int
+	// scale количество знаков после запятой, с которым сумма выводится
+	scale int
+}
+
+// MoneyFromInt создаёт сумму из целого числа
+func MoneyFromInt(v int64) Money {
+	return Money{units: new(big.Int).Mul(big.NewInt(v), big.NewInt(pow10[MoneyScale]))}
+}
+
+// ParseMoney разбирает сумму из десятичной записи, например "10", "-0.25" или "1000.00000001"
+func ParseMoney(s string) (Money, error) {
+	str := s
+	negative := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		negative = str[0] == '-'
+		str = str[1:]
+	}
+
+	intPart, fracPart, hasPoint := strings.Cut(str, ".")
+	if intPart == "" && fracPart == "" || hasPoint && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, fmt.Errorf("%w: %q", ErrMoneyFormat, s)
+	}
+	if len(fracPart) > MoneyScale {
+		return Money{}, ErrMoneyPrecision
+	}
+	if len(strings.TrimLeft(intPart, "0")) > maxParseDigits {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	units, _ := new(big.Int).SetString(intPart+fracPart+strings.Repeat("0", MoneyScale-len(fracPart)), 10)
+	if negative {
+		units.Neg(units)
+	}
+
+	return Money{units: units, scale: len(fracPart)}, nil
+}
+
+// MustParseMoney как ParseMoney, но паникует при ошибке. Используется для констант и тестовых данных.
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// int возвращает количество минимальных единиц, результат нельзя изменять
+func (m Money) int() *big.Int {
+	if m.units == nil {
+		return new(big.Int)
+	}
+
+	return m.units
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{units: new(big.Int).Add(m.int(), other.int()), scale: max(m.scale, other.scale)}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{units: new(big.Int).Sub(m.int(), other.int()), scale: max(m.scale, other.scale)}
+}
+
+func (m Money) Neg() Money {
+	return Money{units: new(big.Int).Neg(m.int()), scale: m.scale}
+}
+
+// Cmp возвращает -1, 0 или 1, если m меньше, равна или больше other
+func (m Money) Cmp(other Money) int {
+	return m.int().Cmp(other.int())
+}
+
+// Sign возвращает -1, 0 или 1 в зависимости от знака суммы
+func (m Money) Sign() int {
+	return m.int().Sign()
+}
+
+func (m Money) IsZero() bool {
+	return m.Sign() == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Sign() > 0
+}
+
+// InRange проверяет что сумма помещается в колонки numeric(30, 8)
+func (m Money) InRange() bool {
+	return new(big.Int).Abs(m.int()).Cmp(unitsLimit) < 0
+}
+
+// Exponent возвращает фактическое количество значащих знаков после запятой
+func (m Money) Exponent() int {
+	units, r := m.int(), new(big.Int)
+	exp := MoneyScale
+	for exp > 0 && r.Rem(units, big.NewInt(pow10[MoneyScale-exp+1])).Sign() == 0 {
+		exp--
+	}
+
+	return exp
+}
+
+// FitsScale проверяет что у суммы не больше scale значащих знаков после запятой
+func (m Money) FitsScale(scale int) bool {
+	return m.Exponent() <= scale
+}
+
+// Round округляет сумму до scale знаков после запятой по правилу mode
+func (m Money) Round(scale int, mode RoundingMode) Money {
+	if scale >= MoneyScale {
+		return Money{units: m.units, scale: MoneyScale}
+	}
+	if scale < 0 {
+		scale = 0
+	}
+
+	factor := big.NewInt(pow10[MoneyScale-scale])
+	q := roundQuotient(m.int(), factor, mode)
+	return Money{units: q.Mul(q, factor), scale: scale}
+}
+
+// Mul умножает сумму на коэффициент (курс обмена, процент комиссии) и округляет результат
+// до scale знаков после запятой по правилу mode. Промежуточное произведение считается без потери точности.
+func (m Money) Mul(rate Money, scale int, mode RoundingMode) Money {
+	if scale > MoneyScale {
+		scale = MoneyScale
+	}
+	if scale < 0 {
+		scale = 0
+	}
+
+	product := new(big.Int).Mul(m.int(), rate.int())
+	// произведение имеет точность 2 * MoneyScale знаков, приводим его к scale знакам
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(2*MoneyScale-scale)), nil)
+	q := roundQuotient(product, divisor, mode)
+
+	return Money{units: q.Mul(q, big.NewInt(pow10[MoneyScale-scale])), scale: scale}
+}
+
+// roundQuotient возвращает частное n / d, округлённое по правилу mode
+func roundQuotient(n, d *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	// сравниваем удвоенный остаток с делителем, чтобы понять в какую сторону округлять
+	twiceRem := new(big.Int).Abs(r)
+	twiceRem.Lsh(twiceRem, 1)
+	if roundAway(twiceRem.Cmp(d), q.Bit(0) == 1, mode) {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+
+	return q
+}
+
+// roundAway решает, нужно ли округлять от нуля. half показывает, меньше (-1), равен (0) или больше (1)
+// отброшенный остаток половины единицы последнего знака
+func roundAway(half int, odd bool, mode RoundingMode) bool {
+	switch mode {
+	case RoundDown:
+		return false
+	case RoundHalfUp:
+		return half >= 0
+	default:
+		return half > 0 || half == 0 && odd
+	}
+}
+
+// String возвращает десятичную запись суммы с количеством знаков после запятой, с которым сумма была задана
+func (m Money) String() string {
+	return m.StringFixed(max(m.scale, m.Exponent()))
+}
+
+// StringFixed возвращает десятичную запись суммы ровно с scale знаками после запятой.
+// Если значащих знаков больше, то они отбрасываются.
+func (m Money) StringFixed(scale int) string {
+	if scale > MoneyScale {
+		scale = MoneyScale
+	}
+	if scale < 0 {
+		scale = 0
+	}
+
+	sign := ""
+	if m.Sign() < 0 {
+		sign = "-"
+	}
+	whole, frac := new(big.Int).QuoRem(new(big.Int).Abs(m.int()), big.NewInt(pow10[MoneyScale]), new(big.Int))
+	if scale == 0 {
+		return sign + whole.String()
+	}
+
+	fracStr := fmt.Sprintf("%0*d", MoneyScale, frac.Int64())[:scale]
+	return sign + whole.String() + "." + fracStr
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+// UnmarshalJSON принимает сумму как строкой ("10.5"), так и числом (10.5).
+// Число разбирается из его текстовой записи, без преобразования в float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if unquoted, err := strconv.Unquote(str); err == nil {
+		str = unquoted
+	}
+
+	parsed, err := ParseMoney(str)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+
+	return nil
+}
+
+// Scan читает сумму из колонки типа numeric
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = MoneyFromInt(v)
+		return nil
+	default:
+		return fmt.Errorf("can't scan %T into Money", src)
+	}
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+
+	return nil
+}
+
+// Value передаёт сумму в бд десятичной строкой
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{in: "10", want: "10"},
+		{in: "-0.25", want: "-0.25"},
+		{in: "+1.50", want: "1.50"},
+		{in: ".5", want: "0.5"},
+		{in: "1000.00000001", want: "1000.00000001"},
+		{in: "92233720368.54775808", want: "92233720368.54775808"},
+		{in: "9999999999999999999999.99999999", want: "9999999999999999999999.99999999"},
+		{in: strings.Repeat("9", maxParseDigits), want: strings.Repeat("9", maxParseDigits)},
+		{in: strings.Repeat("9", maxParseDigits+1), err: ErrMoneyOverflow},
+		{in: "1.000000001", err: ErrMoneyPrecision},
+		{in: "", err: ErrMoneyFormat},
+		{in: "1.", err: ErrMoneyFormat},
+		{in: "1e3", err: ErrMoneyFormat},
+		{in: "--1", err: ErrMoneyFormat},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseMoney(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParseMoney(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmeticDoesntOverflow(t *testing.T) {
+	// больше максимума int64 минимальных единиц
+	large := MustParseMoney("92233720368.54775807")
+	if got := large.Add(MustParseMoney("0.00000001")).String(); got != "92233720368.54775808" {
+		t.Errorf("Add = %s, want 92233720368.54775808", got)
+	}
+	if got := large.Neg().Sub(MustParseMoney("0.00000002")).String(); got != "-92233720368.54775809" {
+		t.Errorf("Sub = %s, want -92233720368.54775809", got)
+	}
+	if got := large.Mul(MoneyFromInt(1000), 2, RoundHalfEven).String(); got != "92233720368547.76" {
+		t.Errorf("Mul = %s, want 92233720368547.76", got)
+	}
+}
+
+func TestMoneyInRange(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "0", want: true},
+		{in: "9999999999999999999999.99999999", want: true},
+		{in: "-9999999999999999999999.99999999", want: true},
+		{in: "10000000000000000000000", want: false},
+		{in: "-10000000000000000000000", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := MustParseMoney(tt.in).InRange(); got != tt.want {
+			t.Errorf("InRange(%s) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int
+		mode  RoundingMode
+		want  string
+	}{
+		{in: "1.005", scale: 2, mode: RoundHalfEven, want: "1.00"},
+		{in: "1.015", scale: 2, mode: RoundHalfEven, want: "1.02"},
+		{in: "1.0051", scale: 2, mode: RoundHalfEven, want: "1.01"},
+		{in: "-1.015", scale: 2, mode: RoundHalfEven, want: "-1.02"},
+		{in: "1.005", scale: 2, mode: RoundHalfUp, want: "1.01"},
+		{in: "-1.005", scale: 2, mode: RoundHalfUp, want: "-1.01"},
+		{in: "1.0049", scale: 2, mode: RoundHalfUp, want: "1.00"},
+		{in: "1.009", scale: 2, mode: RoundDown, want: "1.00"},
+		{in: "-1.009", scale: 2, mode: RoundDown, want: "-1.00"},
+		{in: "2.5", scale: 0, mode: RoundHalfEven, want: "2"},
+		{in: "0.12345678", scale: 8, mode: RoundDown, want: "0.12345678"},
+	}
+
+	for _, tt := range tests {
+		if got := MustParseMoney(tt.in).Round(tt.scale, tt.mode).String(); got != tt.want {
+			t.Errorf("Round(%s, %d, %d) = %s, want %s", tt.in, tt.scale, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	tests := []struct {
+		amount string
+		rate   string
+		scale  int
+		mode   RoundingMode
+		want   string
+	}{
+		// 10.05 * 0.5 = 5.025
+		{amount: "10.05", rate: "0.5", scale: 2, mode: RoundHalfEven, want: "5.02"},
+		{amount: "10.05", rate: "0.5", scale: 2, mode: RoundHalfUp, want: "5.03"},
+		{amount: "10.05", rate: "0.5", scale: 2, mode: RoundDown, want: "5.02"},
+		// 10.15 * 0.5 = 5.075
+		{amount: "10.15", rate: "0.5", scale: 2, mode: RoundHalfEven, want: "5.08"},
+		{amount: "10.15", rate: "0.5", scale: 2, mode: RoundHalfUp, want: "5.08"},
+		{amount: "10.15", rate: "0.5", scale: 2, mode: RoundDown, want: "5.07"},
+		// -10.05 * 0.5 = -5.025
+		{amount: "-10.05", rate: "0.5", scale: 2, mode: RoundHalfEven, want: "-5.02"},
+		{amount: "-10.05", rate: "0.5", scale: 2, mode: RoundHalfUp, want: "-5.03"},
+		{amount: "-10.05", rate: "0.5", scale: 2, mode: RoundDown, want: "-5.02"},
+		// остаток больше половины округляется от нуля во всех режимах, кроме RoundDown
+		{amount: "1", rate: "0.00666667", scale: 2, mode: RoundHalfEven, want: "0.01"},
+		{amount: "1", rate: "0.00666667", scale: 2, mode: RoundDown, want: "0.00"},
+		{amount: "3", rate: "0.33333333", scale: 8, mode: RoundHalfEven, want: "0.99999999"},
+		{amount: "100", rate: "1.23456789", scale: 0, mode: RoundHalfUp, want: "123"},
+	}
+
+	for _, tt := range tests {
+		got := MustParseMoney(tt.amount).Mul(MustParseMoney(tt.rate), tt.scale, tt.mode).String()
+		if got != tt.want {
+			t.Errorf("Mul(%s, %s, %d, %d) = %s, want %s", tt.amount, tt.rate, tt.scale, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyScanLargeBalance(t *testing.T) {
+	var m Money
+	if err := m.Scan([]byte("123456789012345678901.12345678")); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got := m.String(); got != "123456789012345678901.12345678" {
+		t.Errorf("Scan = %s, want 123456789012345678901.12345678", got)
+	}
+}
+
+func TestValidateAmountRange(t *testing.T) {
+	req := &InvoiceRequest{WalletID: 1, Ticker: "USD", Amount: MustParseMoney("10000000000000000000000")}
+	if err := req.Validate(); !errors.Is(err, ValidationAmountRangeError) {
+		t.Errorf("Validate() = %v, want %v", err, ValidationAmountRangeError)
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// BalanceMismatch расхождение баланса кошелька по тикеру с суммой транзакций.
+// Expected считается как сумма успешных транзакций и замороженных списаний в статусе Created.
+type BalanceMismatch struct {
+	WalletID   int    `json:"wallet_id"`
+	Ticker     string `json:"ticker"`
+	Balance    Money  `json:"balance"`
+	Expected   Money  `json:"expected"`
+	Difference Money  `json:"difference"`
+}
+
+// ReconciliationReport результат сверки таблицы balances с журналом транзакций
+type ReconciliationReport struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Checked    int               `json:"checked"`
+	Mismatches []BalanceMismatch `json:"mismatches"`
+}
+
+// OK возвращает true, если расхождений не найдено
+func (r *ReconciliationReport) OK() bool {
+	return len(r.Mismatches) == 0
+}
@@ -0,0 +1,40 @@
+package models
+
+import "errors"
+
+// OperationReversal операция компенсирующих транзакций, которые отменяют ошибочное зачисление или подтверждённое списание
+const OperationReversal = "reversal"
+
+var ValidationReversalAmountError = errors.New("reversal amount must be positive")
+
+// ReverseRequest -> отмена (полная или частичная) успешного зачисления или подтверждённого списания.
+// Создаётся компенсирующая транзакция, связанная с исходной. Если Amount не указан, то отменяется
+// весь ещё не отменённый остаток исходной транзакции.
+type ReverseRequest struct {
+	TransactionID  int    `json:"transaction_id"`
+	Amount         *Money `json:"amount,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (req *ReverseRequest) Validate() error {
+	if req.TransactionID <= 0 {
+		return ValidationTransactionIDError
+	}
+	if req.Amount != nil && !req.Amount.IsPositive() {
+		return ValidationReversalAmountError
+	}
+	if req.Amount != nil && !req.Amount.InRange() {
+		return ValidationAmountRangeError
+	}
+
+	return validateIdempotencyKey(req.IdempotencyKey)
+}
+
+// ReverseResponse тело успешного ответа на отмену: id компенсирующей транзакции, отменённая сумма
+// и остаток исходной транзакции, который ещё можно отменить
+type ReverseResponse struct {
+	TransactionID         int   `json:"transaction_id"`
+	ReversedTransactionID int   `json:"reversed_transaction_id"`
+	Amount                Money `json:"amount"`
+	Remaining             Money `json:"remaining"`
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ValidationScheduledOperationError = errors.New("only invoice, withdraw and transfer can be scheduled")
+	ValidationScheduleRepeatError     = errors.New("repeat must be one of once, daily, weekly, monthly")
+	ValidationScheduleEndError        = errors.New("end_at must be after start_at")
+	ValidationToWalletError           = errors.New("to_wallet_id is allowed only for transfer")
+	ValidationScheduledPaymentIDError = errors.New("scheduled payment id must be positive")
+)
+
+// Repeat периодичность запланированного платежа
+type Repeat string
+
+const (
+	RepeatOnce    Repeat = "once"
+	RepeatDaily   Repeat = "daily"
+	RepeatWeekly  Repeat = "weekly"
+	RepeatMonthly Repeat = "monthly"
+)
+
+func (r Repeat) valid() bool {
+	switch r {
+	case RepeatOnce, RepeatDaily, RepeatWeekly, RepeatMonthly:
+		return true
+	default:
+		return false
+	}
+}
+
+// Occurrence возвращает время выполнения платежа с номером n (с нуля), начиная со start.
+// Время каждого выполнения считается от start, а не от предыдущего, поэтому ежемесячный платёж 31-го числа
+// выполняется в последний день коротких месяцев и снова 31-го в длинных.
+func (r Repeat) Occurrence(start time.Time, n int) time.Time {
+	switch r {
+	case RepeatDaily:
+		return start.AddDate(0, 0, n)
+	case RepeatWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case RepeatMonthly:
+		firstDay := time.Date(start.Year(), start.Month()+time.Month(n), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := firstDay.AddDate(0, 1, -1).Day()
+		return firstDay.AddDate(0, 0, min(start.Day(), lastDay)-1)
+	default:
+		return start
+	}
+}
+
+// ScheduledPaymentStatus состояние запланированного платежа. Выполняются только активные платежи,
+// платёж завершается после последнего выполнения.
+type ScheduledPaymentStatus int
+
+const (
+	ScheduledPaymentActive    ScheduledPaymentStatus = 0
+	ScheduledPaymentCompleted ScheduledPaymentStatus = 1
+	ScheduledPaymentCancelled ScheduledPaymentStatus = 2
+)
+
+var scheduledPaymentStatusNames = map[ScheduledPaymentStatus]string{
+	ScheduledPaymentActive:    "active",
+	ScheduledPaymentCompleted: "completed",
+	ScheduledPaymentCancelled: "cancelled",
+}
+
+func (s ScheduledPaymentStatus) String() string {
+	if name, ok := scheduledPaymentStatusNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ScheduledPaymentStatus(%d)", int(s))
+}
+
+// MarshalText кодирует статус в JSON его названием
+func (s ScheduledPaymentStatus) MarshalText() ([]byte, error) {
+	if _, ok := scheduledPaymentStatusNames[s]; !ok {
+		return nil, ValidationStatusError
+	}
+
+	return []byte(s.String()), nil
+}
+
+func (s *ScheduledPaymentStatus) UnmarshalText(text []byte) error {
+	for status, name := range scheduledPaymentStatusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+
+	return ValidationStatusError
+}
+
+// ScheduledPayment зачисление, списание или перевод, который сервис выполняет сам в NextRunAt.
+// Runs количество уже выполненных запусков, NextRunAt не заполняется у завершённых и отменённых платежей.
+// LastTransactionID и LastError результат последнего запуска.
+type ScheduledPayment struct {
+	ScheduledPaymentID int                    `json:"scheduled_payment_id"`
+	Operation          string                 `json:"operation"`
+	WalletID           int                    `json:"wallet_id"`
+	ToWalletID         int                    `json:"to_wallet_id,omitempty"`
+	Ticker             string                 `json:"ticker"`
+	Amount             Money                  `json:"amount"`
+	Repeat             Repeat                 `json:"repeat"`
+	StartAt            time.Time              `json:"start_at"`
+	EndAt              *time.Time             `json:"end_at,omitempty"`
+	NextRunAt          *time.Time             `json:"next_run_at,omitempty"`
+	Runs               int                    `json:"runs"`
+	Status             ScheduledPaymentStatus `json:"status"`
+	LastRunAt          *time.Time             `json:"last_run_at,omitempty"`
+	LastTransactionID  int                    `json:"last_transaction_id,omitempty"`
+	LastError          string                 `json:"last_error,omitempty"`
+	CreatedAt          time.Time              `json:"created_at"`
+}
+
+// NextRun возвращает время выполнения после runs выполненных запусков или false, если запусков больше нет
+func (p *ScheduledPayment) NextRun(runs int) (time.Time, bool) {
+	if p.Repeat == RepeatOnce && runs > 0 {
+		return time.Time{}, false
+	}
+	next := p.Repeat.Occurrence(p.StartAt, runs)
+	if p.EndAt != nil && next.After(*p.EndAt) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
+
+// IdempotencyKey ключ идемпотентности запуска платежа. Он одинаковый при повторе того же запуска,
+// поэтому запуск, результат которого не успели сохранить, не выполняется второй раз.
+func (p *ScheduledPayment) IdempotencyKey() string {
+	return fmt.Sprintf("scheduled:%d:%d", p.ScheduledPaymentID, p.Runs)
+}
+
+// CreateScheduledPaymentRequest -> планирование зачисления, списания или перевода на StartAt
+// (сразу, если не указано) с повтором Repeat до EndAt. Без Repeat платёж выполняется один раз.
+type CreateScheduledPaymentRequest struct {
+	Operation  string     `json:"operation"`
+	WalletID   int        `json:"wallet_id"`
+	ToWalletID int        `json:"to_wallet_id,omitempty"`
+	Ticker     string     `json:"ticker"`
+	Amount     Money      `json:"amount"`
+	Repeat     Repeat     `json:"repeat,omitempty"`
+	StartAt    *time.Time `json:"start_at,omitempty"`
+	EndAt      *time.Time `json:"end_at,omitempty"`
+}
+
+func (req *CreateScheduledPaymentRequest) Validate() error {
+	switch req.Operation {
+	case OperationInvoice, OperationWithdraw:
+		if req.ToWalletID != 0 {
+			return ValidationToWalletError
+		}
+	case OperationTransfer:
+		if req.ToWalletID <= 0 {
+			return ValidationWalletIDError
+		}
+		if req.ToWalletID == req.WalletID {
+			return ValidationSameWalletError
+		}
+	default:
+		return ValidationScheduledOperationError
+	}
+	if req.WalletID <= 0 {
+		return ValidationWalletIDError
+	}
+	if err := validateAmount(req.Amount); err != nil {
+		return err
+	}
+	if req.Repeat != "" && !req.Repeat.valid() {
+		return ValidationScheduleRepeatError
+	}
+	if req.EndAt != nil {
+		start := time.Now()
+		if req.StartAt != nil {
+			start = *req.StartAt
+		}
+		if !start.Before(*req.EndAt) {
+			return ValidationScheduleEndError
+		}
+	}
+
+	return nil
+}
+
+// RepeatOrOnce возвращает периодичность платежа с учётом значения по умолчанию
+func (req *CreateScheduledPaymentRequest) RepeatOrOnce() Repeat {
+	if req.Repeat == "" {
+		return RepeatOnce
+	}
+
+	return req.Repeat
+}
+
+// ScheduledPaymentsRequest -> список запланированных платежей, в которых кошелёк списывает или получает средства
+type ScheduledPaymentsRequest struct {
+	WalletID int `json:"wallet_id"`
+}
+
+func (req *ScheduledPaymentsRequest) Validate() error {
+	if req.WalletID <= 0 {
+		return ValidationWalletIDError
+	}
+
+	return nil
+}
+
+type ScheduledPaymentsResponse struct {
+	ScheduledPayments []ScheduledPayment `json:"scheduled_payments"`
+}
+
+// CancelScheduledPaymentRequest -> отмена будущих запусков платежа, уже выполненные запуски не отменяются
+type CancelScheduledPaymentRequest struct {
+	ScheduledPaymentID int `json:"scheduled_payment_id"`
+}
+
+func (req *CancelScheduledPaymentRequest) Validate() error {
+	if req.ScheduledPaymentID <= 0 {
+		return ValidationScheduledPaymentIDError
+	}
+
+	return nil
+}
+
+// ScheduledRunResult результат запуска Run запланированного платежа: id созданной транзакции или ошибка бизнес-логики
+type ScheduledRunResult struct {
+	ScheduledPaymentID int
+	Run                int
+	TransactionID      int
+	Error              string
+}
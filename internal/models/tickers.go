@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxTickerDisplayNameLength максимальная длина отображаемого названия тикера, ограничена размером колонки в бд
+const MaxTickerDisplayNameLength = 64
+
+// tickerCodePattern код тикера: от 2 до 16 заглавных латинских букв и цифр, например "USD" или "USDT"
+var tickerCodePattern = regexp.MustCompile(`^[A-Z0-9]{2,16}$`)
+
+var (
+	ValidationTickerCodeError        = errors.New("ticker code must consist of 2 to 16 uppercase latin letters and digits")
+	ValidationTickerScaleError       = fmt.Errorf("ticker scale must be between 0 and %d", MoneyScale)
+	ValidationTickerDisplayNameError = fmt.Errorf("ticker display name is longer then %d characters", MaxTickerDisplayNameLength)
+	ValidationTickerAmountError      = errors.New("ticker min and max amounts must be positive")
+	ValidationTickerRangeError       = errors.New("ticker min amount must not be greater then max amount")
+	ValidationTickerAmountScaleError = errors.New("ticker min and max amounts can't have more digits after the decimal point then the ticker scale")
+)
+
+// Ticker валюта. Name - уникальный код валюты, DisplayName - название для отображения клиентам,
+// Scale - количество знаков после запятой, допустимое в суммах по этой валюте.
+// MinAmount и MaxAmount ограничивают сумму одной операции, неуказанная граница не проверяется.
+// Операции по отключённому тикеру (Enabled = false) отклоняются, кроме подтверждения и отмены уже замороженных списаний.
+type Ticker struct {
+	TickerID    int        `json:"ticker_id"`
+	Name        string     `json:"name"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Scale       int        `json:"scale"`
+	MinAmount   *Money     `json:"min_amount,omitempty"`
+	MaxAmount   *Money     `json:"max_amount,omitempty"`
+	Enabled     bool       `json:"enabled"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// validateTickerSettings проверяет настройки тикера, которые можно менять после его создания
+func validateTickerSettings(displayName string, minAmount, maxAmount *Money) error {
+	if utf8.RuneCountInString(displayName) > MaxTickerDisplayNameLength {
+		return ValidationTickerDisplayNameError
+	}
+	for _, amount := range []*Money{minAmount, maxAmount} {
+		if amount != nil && !amount.IsPositive() {
+			return ValidationTickerAmountError
+		}
+		if amount != nil && !amount.InRange() {
+			return ValidationAmountRangeError
+		}
+	}
+	if minAmount != nil && maxAmount != nil && minAmount.Cmp(*maxAmount) > 0 {
+		return ValidationTickerRangeError
+	}
+
+	return nil
+}
+
+// CreateTickerRequest -> добавление новой валюты. Созданный тикер сразу включён.
+type CreateTickerRequest struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+	Scale       int    `json:"scale"`
+	MinAmount   *Money `json:"min_amount,omitempty"`
+	MaxAmount   *Money `json:"max_amount,omitempty"`
+}
+
+func (req *CreateTickerRequest) Validate() error {
+	if !tickerCodePattern.MatchString(req.Name) {
+		return ValidationTickerCodeError
+	}
+	if req.Scale < 0 || req.Scale > MoneyScale {
+		return ValidationTickerScaleError
+	}
+	for _, amount := range []*Money{req.MinAmount, req.MaxAmount} {
+		if amount != nil && !amount.FitsScale(req.Scale) {
+			return ValidationTickerAmountScaleError
+		}
+	}
+
+	return validateTickerSettings(req.DisplayName, req.MinAmount, req.MaxAmount)
+}
+
+// UpdateTickerRequest -> замена отображаемого названия и границ суммы операции тикера.
+// Точность тикера не меняется, потому что по ней уже посчитаны сохранённые суммы.
+type UpdateTickerRequest struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+	MinAmount   *Money `json:"min_amount,omitempty"`
+	MaxAmount   *Money `json:"max_amount,omitempty"`
+}
+
+func (req *UpdateTickerRequest) Validate() error {
+	return validateTickerSettings(req.DisplayName, req.MinAmount, req.MaxAmount)
+}
+
+// SetTickerStatusRequest -> включение или отключение операций по тикеру
+type SetTickerStatusRequest struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (req *SetTickerStatusRequest) Validate() error {
+	if !tickerCodePattern.MatchString(req.Name) {
+		return ValidationTickerCodeError
+	}
+
+	return nil
+}
+
+type TickersResponse struct {
+	Tickers []Ticker `json:"tickers"`
+}
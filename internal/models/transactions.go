@@ -1,13 +1,43 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxIdempotencyKeyLength максимальная длина ключа идемпотентности, ограничена размером колонки в бд
+const MaxIdempotencyKeyLength = 255
+
+func validateIdempotencyKey(key string) error {
+	if len(key) > MaxIdempotencyKeyLength {
+		return ValidationIdempotencyKeyError
+	}
+
+	return nil
+}
 
-var ValidationAmountError = errors.New("amount lower then 0")
+// validateAmount проверяет сумму операции: она должна быть положительной и помещаться в колонки бд
+func validateAmount(amount Money) error {
+	if !amount.IsPositive() {
+		return ValidationAmountError
+	}
+	if !amount.InRange() {
+		return ValidationAmountRangeError
+	}
 
-type Wallet struct {
-	WalletID int
+	return nil
 }
 
+var (
+	ValidationAmountError         = errors.New("amount lower then 0")
+	ValidationAmountRangeError    = fmt.Errorf("amount must be less then 10^%d", moneyIntDigits)
+	ValidationSameWalletError     = errors.New("source and destination wallets are the same")
+	ValidationTransactionIDError  = errors.New("transaction id must be positive")
+	ValidationIdempotencyKeyError = fmt.Errorf("idempotency key is longer then %d characters", MaxIdempotencyKeyLength)
+	ValidationBalanceAtError      = errors.New("balance_at must not be in the future")
+)
+
 type TransactionStatus int
 
 const (
@@ -16,53 +46,163 @@ const (
 	TransactionStatusCreated TransactionStatus = 2
 )
 
+var transactionStatusNames = map[TransactionStatus]string{
+	TransactionStatusSuccess: "Success",
+	TransactionStatusError:   "Error",
+	TransactionStatusCreated: "Created",
+}
+
+func (s TransactionStatus) String() string {
+	if name, ok := transactionStatusNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("TransactionStatus(%d)", int(s))
+}
+
+// MarshalText кодирует статус в JSON его названием
+func (s TransactionStatus) MarshalText() ([]byte, error) {
+	if _, ok := transactionStatusNames[s]; !ok {
+		return nil, ValidationStatusError
+	}
+
+	return []byte(s.String()), nil
+}
+
+func (s *TransactionStatus) UnmarshalText(text []byte) error {
+	for status, name := range transactionStatusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+
+	return ValidationStatusError
+}
+
 type Transaction struct {
 	ID       int               `json:"id"`
 	WalletID int               `json:"wallet_id"`
 	TickerID int               `json:"ticker_id"`
-	Amount   float32           `json:"amount"`
+	Amount   Money             `json:"amount"`
 	Status   TransactionStatus `json:"status,omitempty"`
+	// LinkedTransactionID id второй транзакции той же операции, например зачисления при обмене валют
+	LinkedTransactionID int `json:"linked_transaction_id,omitempty"`
+	// Operation операция, в результате которой создана транзакция
+	Operation string `json:"operation,omitempty"`
 }
 
+// Операции, в результате которых создаются транзакции
+const (
+	OperationInvoice  = "invoice"
+	OperationWithdraw = "withdraw"
+	OperationTransfer = "transfer"
+	OperationExchange = "exchange"
+)
+
 // - Invoice -> человеку зачисляются средства по ручке "/invoice" с такими параметрами в теле,
-// как код валюты ("USD", "RUB", "EUR", etc.), количество средств (число с плавающей точкой), номер кошелька или карты.
+// как код валюты ("USD", "RUB", "EUR", etc.), количество средств (десятичное число в виде строки, например "10.50"), номер кошелька или карты.
+// Повторная доставка запроса с тем же IdempotencyKey возвращает сохранённый ответ без повторного зачисления.
 type InvoiceRequest struct {
-	WalletID int     `json:"wallet_id"`
-	Ticker   string  `json:"ticker"`
-	Amount   float32 `json:"amount"`
+	WalletID       int    `json:"wallet_id"`
+	Ticker         string `json:"ticker"`
+	Amount         Money  `json:"amount"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 func (req *InvoiceRequest) Validate() error {
-	if req.Amount <= 0 {
-		return ValidationAmountError
+	if err := validateAmount(req.Amount); err != nil {
+		return err
 	}
 
-	return nil
+	return validateIdempotencyKey(req.IdempotencyKey)
 }
 
 // Withdraw -> человек выводит средства со своего баланса по валюте, с параметрами в теле
 // код валюты, количество средств, номер кошелька или карты откуда снимаются средства.
+// Средства замораживаются до подтверждения или отмены списания через HoldRequest.
 type WithdrawRequest struct {
-	WalletID int     `json:"wallet_id"`
-	Ticker   string  `json:"ticker"`
-	Amount   float32 `json:"amount"`
+	WalletID       int    `json:"wallet_id"`
+	Ticker         string `json:"ticker"`
+	Amount         Money  `json:"amount"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 func (req *WithdrawRequest) Validate() error {
-	if req.Amount <= 0 {
-		return ValidationAmountError
+	if err := validateAmount(req.Amount); err != nil {
+		return err
 	}
 
-	return nil
+	return validateIdempotencyKey(req.IdempotencyKey)
+}
+
+// OperationResponse тело успешного ответа на операцию, создающую транзакцию
+type OperationResponse struct {
+	TransactionID int `json:"transaction_id"`
+	// Fee комиссия за операцию и id записи о её списании, не заполняются если комиссии нет
+	Fee              *Money `json:"fee,omitempty"`
+	FeeTransactionID int    `json:"fee_transaction_id,omitempty"`
+}
+
+// HoldRequest -> подтверждение (capture) или отмена (release) замороженного списания по его id,
+// полученному в ответе на withdraw.
+type HoldRequest struct {
+	TransactionID  int    `json:"transaction_id"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (req *HoldRequest) Validate() error {
+	if req.TransactionID <= 0 {
+		return ValidationTransactionIDError
+	}
+
+	return validateIdempotencyKey(req.IdempotencyKey)
+}
+
+// Transfer -> перевод средств по одной валюте с одного кошелька на другой в рамках одной транзакции в бд
+type TransferRequest struct {
+	FromWalletID   int    `json:"from_wallet_id"`
+	ToWalletID     int    `json:"to_wallet_id"`
+	Ticker         string `json:"ticker"`
+	Amount         Money  `json:"amount"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (req *TransferRequest) Validate() error {
+	if err := validateAmount(req.Amount); err != nil {
+		return err
+	}
+	if req.FromWalletID == req.ToWalletID {
+		return ValidationSameWalletError
+	}
+
+	return validateIdempotencyKey(req.IdempotencyKey)
+}
+
+// TransferResponse тело успешного ответа на перевод с id транзакций списания и зачисления
+type TransferResponse struct {
+	DebitTransactionID  int `json:"debit_transaction_id"`
+	CreditTransactionID int `json:"credit_transaction_id"`
 }
 
 // Должна быть реализована ручка по получению актуального и замороженного баланса клиентов.
 // Актуальный баланс это тот баланс, который можно вывести. Замороженный баланс - баланс со статусом "Created".
+// Если указан BalanceAt, то возвращаются балансы на этот момент времени, посчитанные по журналу транзакций.
 type GetBalanceRequest struct {
-	WalletID int `json:"wallet_id"`
+	WalletID  int        `json:"wallet_id"`
+	BalanceAt *time.Time `json:"balance_at,omitempty"`
+}
+
+func (req *GetBalanceRequest) Validate() error {
+	if req.BalanceAt != nil && req.BalanceAt.After(time.Now()) {
+		return ValidationBalanceAtError
+	}
+
+	return nil
 }
 
 type GetBalanceResponse struct {
-	ActualBalance map[string]float32 `json:"actual_balance,omitempty"`
-	FrozenBalance map[string]float32 `json:"frozen_balance,omitempty"`
+	ActualBalance map[string]Money `json:"actual_balance,omitempty"`
+	FrozenBalance map[string]Money `json:"frozen_balance,omitempty"`
+	BalanceAt     *time.Time       `json:"balance_at,omitempty"`
 }
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ValidationReasonError = errors.New("reason is required to suspend or close a wallet")
+
+// WalletStatus состояние кошелька. Операции с балансом разрешены только для активных кошельков,
+// закрытый кошелёк нельзя открыть заново.
+type WalletStatus int
+
+const (
+	WalletStatusActive    WalletStatus = 0
+	WalletStatusSuspended WalletStatus = 1
+	WalletStatusClosed    WalletStatus = 2
+)
+
+var walletStatusNames = map[WalletStatus]string{
+	WalletStatusActive:    "active",
+	WalletStatusSuspended: "suspended",
+	WalletStatusClosed:    "closed",
+}
+
+func (s WalletStatus) String() string {
+	if name, ok := walletStatusNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("WalletStatus(%d)", int(s))
+}
+
+// MarshalText кодирует статус в JSON его названием
+func (s WalletStatus) MarshalText() ([]byte, error) {
+	if _, ok := walletStatusNames[s]; !ok {
+		return nil, ValidationStatusError
+	}
+
+	return []byte(s.String()), nil
+}
+
+func (s *WalletStatus) UnmarshalText(text []byte) error {
+	for status, name := range walletStatusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+
+	return ValidationStatusError
+}
+
+type Wallet struct {
+	WalletID        int          `json:"wallet_id"`
+	Status          WalletStatus `json:"status"`
+	StatusReason    string       `json:"status_reason,omitempty"`
+	StatusChangedAt time.Time    `json:"status_changed_at"`
+	// CustomerID клиент, которому принадлежит кошелёк, 0 если кошелёк не привязан к клиенту
+	CustomerID int `json:"customer_id,omitempty"`
+}
+
+// GetWalletRequest -> получение кошелька вместе с его состоянием
+type GetWalletRequest struct {
+	WalletID int `json:"wallet_id"`
+}
+
+func (req *GetWalletRequest) Validate() error {
+	if req.WalletID <= 0 {
+		return ValidationWalletIDError
+	}
+
+	return nil
+}
+
+// SetWalletStatusRequest -> изменение состояния кошелька, например блокировка по требованию комплаенса.
+// Для блокировки и закрытия кошелька нужно указать причину.
+type SetWalletStatusRequest struct {
+	WalletID int          `json:"wallet_id"`
+	Status   WalletStatus `json:"status"`
+	Reason   string       `json:"reason,omitempty"`
+}
+
+func (req *SetWalletStatusRequest) Validate() error {
+	if req.WalletID <= 0 {
+		return ValidationWalletIDError
+	}
+	if req.Status != WalletStatusActive && req.Reason == "" {
+		return ValidationReasonError
+	}
+
+	return nil
+}
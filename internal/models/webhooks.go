@@ -0,0 +1,242 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MaxWebhookSecretLength максимальная длина секрета подписи, ограничена размером колонки в бд
+const MaxWebhookSecretLength = 255
+
+var (
+	ValidationWebhookURLError    = errors.New("url must be an absolute http or https url")
+	ValidationWebhookHostError   = errors.New("url must point to a public address")
+	ValidationWebhookSecretError = fmt.Errorf("secret is longer then %d characters", MaxWebhookSecretLength)
+	ValidationWebhookIDError     = errors.New("webhook id must be positive")
+	ValidationWebhookWalletError = errors.New("wallet id must not be negative")
+)
+
+// webhookForbiddenNets сети, которые не входят в проверки net.IP, но тоже не должны быть адресами уведомлений:
+// "этот" хост, общее адресное пространство провайдеров (CGNAT) и сеть для тестов производительности
+var webhookForbiddenNets = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.IPv4(198, 18, 0, 0), Mask: net.CIDRMask(15, 32)},
+}
+
+// WebhookIPAllowed проверяет что на адрес ip можно отправлять уведомления. Адреса самого сервиса, внутренних сетей,
+// link-local (в том числе адрес метаданных облака 169.254.169.254) и multicast запрещены, иначе через адрес
+// уведомлений можно было бы отправлять запросы во внутренние сервисы.
+func WebhookIPAllowed(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range webhookForbiddenNets {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Повтор доставки уведомления: задержка перед попыткой n+1 после неудачной попытки n равна
+// WebhookRetryBaseDelay * 2^(n-1), но не больше WebhookRetryMaxDelay. После WebhookMaxAttempts попыток
+// доставка считается неудачной и больше не повторяется.
+const (
+	WebhookMaxAttempts    = 10
+	WebhookRetryBaseDelay = 30 * time.Second
+	WebhookRetryMaxDelay  = 6 * time.Hour
+)
+
+// WebhookRetryDelay возвращает задержку перед следующей попыткой после неудачной попытки attempt (начиная с 1)
+func WebhookRetryDelay(attempt int) time.Duration {
+	d := WebhookRetryBaseDelay << (attempt - 1)
+	if d <= 0 || d > WebhookRetryMaxDelay {
+		d = WebhookRetryMaxDelay
+	}
+
+	return d
+}
+
+// Webhook адрес, на который сервис отправляет уведомления о транзакциях кошелька WalletID,
+// или всех кошельков, если WalletID не указан. Уведомления подписываются секретом Secret,
+// он возвращается только при создании.
+type Webhook struct {
+	WebhookID int       `json:"webhook_id"`
+	WalletID  int       `json:"wallet_id,omitempty"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWebhookRequest -> регистрация адреса для уведомлений о транзакциях, которые перешли в статус Success или Error.
+// Если Secret не указан, то он генерируется.
+type CreateWebhookRequest struct {
+	WalletID int    `json:"wallet_id,omitempty"`
+	URL      string `json:"url"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+func (req *CreateWebhookRequest) Validate() error {
+	if req.WalletID < 0 {
+		return ValidationWebhookWalletError
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ValidationWebhookURLError
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ValidationWebhookHostError
+	}
+	if ip := net.ParseIP(host); ip != nil && !WebhookIPAllowed(ip) {
+		return ValidationWebhookHostError
+	}
+	if len(req.Secret) > MaxWebhookSecretLength {
+		return ValidationWebhookSecretError
+	}
+
+	return nil
+}
+
+// WebhooksRequest -> список адресов для уведомлений кошелька или, если WalletID не указан, всех адресов
+type WebhooksRequest struct {
+	WalletID int `json:"wallet_id,omitempty"`
+}
+
+func (req *WebhooksRequest) Validate() error {
+	if req.WalletID < 0 {
+		return ValidationWebhookWalletError
+	}
+
+	return nil
+}
+
+type WebhooksResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// DeleteWebhookRequest -> удаление адреса, уведомления на него больше не отправляются
+type DeleteWebhookRequest struct {
+	WebhookID int `json:"webhook_id"`
+}
+
+func (req *DeleteWebhookRequest) Validate() error {
+	if req.WebhookID <= 0 {
+		return ValidationWebhookIDError
+	}
+
+	return nil
+}
+
+// WebhookDeliveryStatus состояние доставки уведомления, доставка в статусе pending ещё будет повторена
+type WebhookDeliveryStatus int
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = 0
+	WebhookDeliverySucceeded WebhookDeliveryStatus = 1
+	WebhookDeliveryFailed    WebhookDeliveryStatus = 2
+)
+
+var webhookDeliveryStatusNames = map[WebhookDeliveryStatus]string{
+	WebhookDeliveryPending:   "pending",
+	WebhookDeliverySucceeded: "succeeded",
+	WebhookDeliveryFailed:    "failed",
+}
+
+func (s WebhookDeliveryStatus) String() string {
+	if name, ok := webhookDeliveryStatusNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("WebhookDeliveryStatus(%d)", int(s))
+}
+
+// MarshalText кодирует статус в JSON его названием
+func (s WebhookDeliveryStatus) MarshalText() ([]byte, error) {
+	if _, ok := webhookDeliveryStatusNames[s]; !ok {
+		return nil, ValidationStatusError
+	}
+
+	return []byte(s.String()), nil
+}
+
+func (s *WebhookDeliveryStatus) UnmarshalText(text []byte) error {
+	for status, name := range webhookDeliveryStatusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+
+	return ValidationStatusError
+}
+
+// WebhookDelivery запись журнала доставки одного уведомления на один адрес. Payload тело уведомления,
+// это событие TransactionEvent. URL и Secret нужны только для отправки и в журнал не выводятся.
+type WebhookDelivery struct {
+	DeliveryID     int                   `json:"delivery_id"`
+	WebhookID      int                   `json:"webhook_id"`
+	EventID        int64                 `json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time            `json:"last_attempt_at,omitempty"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookDeliveriesRequest -> журнал доставок на адрес WebhookID от новых к старым, не больше Limit записей
+type WebhookDeliveriesRequest struct {
+	WebhookID int `json:"webhook_id"`
+	Limit     int `json:"limit,omitempty"`
+}
+
+func (req *WebhookDeliveriesRequest) Validate() error {
+	if req.WebhookID <= 0 {
+		return ValidationWebhookIDError
+	}
+	if req.Limit < 0 || req.Limit > MaxHistoryLimit {
+		return ValidationHistoryLimitError
+	}
+
+	return nil
+}
+
+// LimitOrDefault возвращает количество записей журнала с учётом значения по умолчанию
+func (req *WebhookDeliveriesRequest) LimitOrDefault() int {
+	if req.Limit == 0 {
+		return DefaultHistoryLimit
+	}
+
+	return req.Limit
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookAttemptResult результат попытки Attempt доставки DeliveryID: код ответа адреса или ошибка запроса.
+// Попытка успешна, если адрес ответил кодом 2xx.
+type WebhookAttemptResult struct {
+	DeliveryID int
+	Attempt    int
+	StatusCode int
+	Error      string
+}
+
+func (r *WebhookAttemptResult) Succeeded() bool {
+	return r.Error == "" && r.StatusCode >= 200 && r.StatusCode < 300
+}
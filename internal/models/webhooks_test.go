@@ -0,0 +1,38 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCreateWebhookRequestValidate(t *testing.T) {
+	tests := []struct {
+		url string
+		err error
+	}{
+		{url: "https://example.com/hooks"},
+		{url: "http://93.184.216.34:8080/hooks"},
+		{url: "ftp://example.com/hooks", err: ValidationWebhookURLError},
+		{url: "/hooks", err: ValidationWebhookURLError},
+		{url: "http://localhost:8080/hooks", err: ValidationWebhookHostError},
+		{url: "http://api.localhost./hooks", err: ValidationWebhookHostError},
+		{url: "http://127.0.0.1/hooks", err: ValidationWebhookHostError},
+		{url: "http://10.0.0.5/hooks", err: ValidationWebhookHostError},
+		{url: "http://192.168.1.1/hooks", err: ValidationWebhookHostError},
+		{url: "http://169.254.169.254/latest/meta-data", err: ValidationWebhookHostError},
+		{url: "http://100.64.0.1/hooks", err: ValidationWebhookHostError},
+		{url: "http://0.0.0.0/hooks", err: ValidationWebhookHostError},
+		{url: "http://[::1]/hooks", err: ValidationWebhookHostError},
+		{url: "http://[fe80::1]/hooks", err: ValidationWebhookHostError},
+		{url: "http://[fd00::1]/hooks", err: ValidationWebhookHostError},
+		{url: "http://[::ffff:127.0.0.1]/hooks", err: ValidationWebhookHostError},
+		{url: "http://224.0.0.1/hooks", err: ValidationWebhookHostError},
+	}
+
+	for _, tt := range tests {
+		req := &CreateWebhookRequest{URL: tt.url}
+		if err := req.Validate(); !errors.Is(err, tt.err) {
+			t.Errorf("Validate(%s) = %v, want %v", tt.url, err, tt.err)
+		}
+	}
+}
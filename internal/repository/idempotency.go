@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+)
+
+// Названия операций, под которыми сохраняются ответы в таблице idempotency_keys
+const (
+	idempotencyOpInvoice  = "invoice"
+	idempotencyOpWithdraw = "withdraw"
+	idempotencyOpTransfer = "transfer"
+	idempotencyOpCapture  = "capture"
+	idempotencyOpRelease  = "release"
+	idempotencyOpExchange = "exchange"
+	idempotencyOpReverse  = "reverse"
+	idempotencyOpBatch    = "batch"
+)
+
+// pqUniqueViolation код ошибки PostgreSQL при нарушении уникальности
+const pqUniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
+
+// findIdempotentResponse ищет сохранённый ответ на уже обработанный запрос операции operation с ключом key
+// и записывает его в resp. Ключи разных операций не пересекаются. Если ключ не указан или запрос ещё не обрабатывался,
+// то возвращает false, а если запрос завершился ошибкой бизнес-логики, то возвращает ту же ошибку.
+func (p *PostgresRepo) findIdempotentResponse(ctx context.Context, key, operation string, resp any) (bool, error) {
+	if key == "" {
+		return false, nil
+	}
+
+	var body []byte
+	var errorCode, reason sql.NullString
+	if err := p.db.QueryRowContext(ctx,
+		"SELECT response, error_code, reason FROM idempotency_keys WHERE key = $1 AND operation = $2",
+		key, operation).Scan(&body, &errorCode, &reason); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, err
+	}
+	if errorCode.Valid {
+		return false, LogicErrors{Code: errorCode.String, Reason: reason.String}
+	}
+
+	if err := json.Unmarshal(body, resp); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// saveIdempotentResponse сохраняет ответ на запрос в рамках той же транзакции, в которой выполняется операция
+func saveIdempotentResponse(ctx context.Context, tx *sql.Tx, key, operation string, resp any) error {
+	if key == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO idempotency_keys (key, operation, response) VALUES ($1, $2, $3)", key, operation, body); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// saveIdempotentError сохраняет в рамках транзакции tx ошибку бизнес-логики, которой завершился запрос с ключом key
+func saveIdempotentError(ctx context.Context, tx *sql.Tx, key, operation string, outcome LogicErrors) error {
+	if key == "" {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO idempotency_keys (key, operation, error_code, reason) VALUES ($1, $2, $3, $4)",
+		key, operation, outcome.Code, outcome.Reason); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+recordIdempotentError сохраняет ошибку бизнес-логики queryError, которой завершился запрос с ключом key, и возвращает её,
+остальные ошибки возвращаются без сохранения, и запрос можно повторить. Вызывается после отката транзакции операции:
+операция без изменений в бд не оставляет ничего, что надо было бы сохранить вместе с ошибкой, а запись о неудачной
+транзакции сохраняется вместе с ключом в rollbackOperation. Если ключ уже сохранён, то запись не меняется.
+*/
+func (p *PostgresRepo) recordIdempotentError(ctx context.Context, queryError error, key, operation string) error {
+	var outcome LogicErrors
+	if key == "" || !errors.As(queryError, &outcome) {
+		return queryError
+	}
+
+	if _, err := p.db.ExecContext(ctx,
+		"INSERT INTO idempotency_keys (key, operation, error_code, reason) VALUES ($1, $2, $3, $4) ON CONFLICT (key, operation) DO NOTHING",
+		key, operation, outcome.Code, outcome.Reason); err != nil {
+		return err
+	}
+
+	return queryError
+}
+
+// replayIdempotentResponse вызывается после отката транзакции, в которой не удалось сохранить ключ идемпотентности.
+// Если ключ успел сохранить параллельно обработанный дубликат запроса, то в resp записывается его ответ,
+// иначе возвращается исходная ошибка.
+func (p *PostgresRepo) replayIdempotentResponse(ctx context.Context, queryError error, key, operation string, resp any) error {
+	if !isUniqueViolation(queryError) {
+		return queryError
+	}
+
+	found, err := p.findIdempotentResponse(ctx, key, operation, resp)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return queryError
+	}
+
+	return nil
+}
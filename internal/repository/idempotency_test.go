@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestIdempotencyKeyScopedByOperation(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		ticker := r.ticker(t, "IDEM")
+		walletID := r.wallet(t)
+		key := fmt.Sprintf("key-%s-%s", ticker, r.suffix)
+
+		invoice := &models.InvoiceRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("10"), IdempotencyKey: key}
+		first, err := r.Invoice(ctx, invoice)
+		if err != nil {
+			t.Fatalf("Invoice: %v", err)
+		}
+		repeated, err := r.Invoice(ctx, invoice)
+		if err != nil {
+			t.Fatalf("repeated Invoice: %v", err)
+		}
+		if repeated.TransactionID != first.TransactionID {
+			t.Fatalf("repeated Invoice created transaction %d, want replay of %d", repeated.TransactionID, first.TransactionID)
+		}
+
+		// тот же ключ у запроса другой операции не связан с зачислением
+		withdraw, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("4"), IdempotencyKey: key})
+		if err != nil {
+			t.Fatalf("WithDraw with the invoice key: %v", err)
+		}
+		if withdraw.TransactionID == first.TransactionID {
+			t.Fatalf("WithDraw replayed the invoice response %+v", withdraw)
+		}
+		if actual, frozen := r.balance(t, walletID, ticker); actual != "6.00" || frozen != "4.00" {
+			t.Fatalf("balance = %s/%s, want 6.00/4.00", actual, frozen)
+		}
+	})
+}
+
+func TestIdempotentErrorReplayed(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		ticker := r.ticker(t, "IDEM")
+		walletID := r.wallet(t)
+		r.invoice(t, walletID, ticker, "5")
+		key := fmt.Sprintf("failed-%s-%s", ticker, r.suffix)
+
+		withdraw := &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("10"), IdempotencyKey: key}
+		_, err := r.WithDraw(ctx, withdraw)
+		checkErrorCode(t, "WithDraw", err, ErrCodeNotEnoughCoins)
+
+		// после пополнения повтор запроса с тем же ключом получает ту же ошибку и не создаёт ещё одну запись о неудаче
+		r.invoice(t, walletID, ticker, "10")
+		_, err = r.WithDraw(ctx, withdraw)
+		checkErrorCode(t, "repeated WithDraw", err, ErrCodeNotEnoughCoins)
+
+		status := models.TransactionStatusError
+		history, err := r.ListTransactions(ctx, &models.HistoryRequest{WalletID: walletID, Status: &status})
+		if err != nil {
+			t.Fatalf("ListTransactions: %v", err)
+		}
+		if len(history.Transactions) != 1 {
+			t.Fatalf("failed transactions = %d, want 1", len(history.Transactions))
+		}
+		if actual, frozen := r.balance(t, walletID, ticker); actual != "15.00" || frozen != "0.00" {
+			t.Fatalf("balance = %s/%s, want 15.00/0.00", actual, frozen)
+		}
+
+		// с новым ключом запрос выполняется заново
+		withdraw.IdempotencyKey = key + "-next"
+		if _, err := r.WithDraw(ctx, withdraw); err != nil {
+			t.Fatalf("WithDraw with a new key: %v", err)
+		}
+	})
+}
+
+func TestIdempotentExchangeErrorReplayed(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		from, to := r.ticker(t, "IDEM"), r.ticker(t, "IDEM")
+		if err := r.SetExchangeRate(ctx, &models.ExchangeRate{FromTicker: from, ToTicker: to, Rate: models.MustParseMoney("2")}); err != nil {
+			t.Fatalf("SetExchangeRate: %v", err)
+		}
+		walletID := r.wallet(t)
+		r.invoice(t, walletID, from, "5")
+
+		exchange := &models.ExchangeRequest{WalletID: walletID, FromTicker: from, ToTicker: to, Amount: models.MustParseMoney("10"),
+			IdempotencyKey: fmt.Sprintf("exchange-%s-%s", from, r.suffix)}
+		for i := 0; i < 2; i++ {
+			_, err := r.Exchange(ctx, exchange)
+			checkErrorCode(t, "Exchange", err, ErrCodeNotEnoughCoins)
+			r.invoice(t, walletID, from, "10")
+		}
+
+		status := models.TransactionStatusError
+		history, err := r.ListTransactions(ctx, &models.HistoryRequest{WalletID: walletID, Status: &status})
+		if err != nil {
+			t.Fatalf("ListTransactions: %v", err)
+		}
+		if len(history.Transactions) != 1 {
+			t.Fatalf("failed transactions = %d, want 1", len(history.Transactions))
+		}
+	})
+}
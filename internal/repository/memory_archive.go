@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"time"
+)
+
+// ArchiveTransactions отмечает архивными до limit завершённых транзакций, которые не менялись с момента before.
+// История и сверка в MemoryRepo и так читают все транзакции, архивную транзакцию только нельзя отменить.
+func (m *MemoryRepo) ArchiveTransactions(ctx context.Context, before time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	archived := 0
+	for _, t := range m.transactions {
+		if archived >= limit {
+			break
+		}
+		if t.archived || t.Status == models.TransactionStatusCreated || !t.UpdatedAt.Before(before) {
+			continue
+		}
+		t.archived = true
+		archived++
+	}
+
+	return archived, nil
+}
+
+// MaintainTransactionPartitions ничего не делает, в памяти транзакции не секционируются
+func (m *MemoryRepo) MaintainTransactionPartitions(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
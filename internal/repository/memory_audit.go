@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+)
+
+func (m *MemoryRepo) RecordAudit(ctx context.Context, record *models.AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record.ID, record.CreatedAt = len(m.audit)+1, m.now()
+	copied := *record
+	m.audit = append(m.audit, &copied)
+
+	return nil
+}
+
+func (m *MemoryRepo) ListAuditLog(ctx context.Context, req *models.AuditLogRequest) (*models.AuditLogResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cursorID, err := req.CursorID()
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.PageSize()
+	resp := &models.AuditLogResponse{Records: make([]models.AuditRecord, 0, limit)}
+	for i := len(m.audit) - 1; i >= 0 && len(resp.Records) <= limit; i-- {
+		r := m.audit[i]
+		switch {
+		case cursorID != 0 && r.ID >= cursorID,
+			req.AppID != "" && r.AppID != req.AppID,
+			req.UserID != "" && r.UserID != req.UserID,
+			req.RoutingKey != "" && r.RoutingKey != req.RoutingKey,
+			req.TransactionID != 0 && r.TransactionID != req.TransactionID,
+			req.From != nil && r.CreatedAt.Before(*req.From),
+			req.To != nil && !r.CreatedAt.Before(*req.To):
+			continue
+		}
+
+		resp.Records = append(resp.Records, *r)
+	}
+
+	// если нашли больше записей чем размер страницы, то есть следующая страница
+	if len(resp.Records) > limit {
+		resp.Records = resp.Records[:limit]
+		resp.NextCursor = models.EncodeCursor(resp.Records[limit-1].ID)
+	}
+
+	return resp, nil
+}
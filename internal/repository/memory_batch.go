@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"maps"
+)
+
+// Batch выполняет операции пакета по порядку. При ошибке одной из них восстанавливаются балансы и отбрасываются
+// транзакции, проводки, события и доставки, созданные пакетом, как при откате транзакции в PostgresRepo.
+func (m *MemoryRepo) Batch(ctx context.Context, req *models.BatchRequest) (_ *models.BatchResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpBatch, err) }()
+
+	resp := &models.BatchResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpBatch, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	// операции пакета только добавляют транзакции, проводки, события и доставки уведомлений и меняют балансы,
+	// поэтому для отката достаточно копии балансов и длин срезов
+	balances := maps.Clone(m.balances)
+	transactions, journal, outbox, deliveries := len(m.transactions), len(m.journal), len(m.outbox), len(m.deliveries)
+	rollback := func(err error) error {
+		m.balances = balances
+		m.transactions, m.journal, m.outbox = m.transactions[:transactions], m.journal[:journal], m.outbox[:outbox]
+		m.deliveries = m.deliveries[:deliveries]
+		return err
+	}
+
+	now := m.now()
+	resp.Results = make([]models.BatchLegResult, 0, len(req.Legs))
+	for i := range req.Legs {
+		leg := &req.Legs[i]
+		result := models.BatchLegResult{Operation: leg.Operation}
+		var err error
+		switch leg.Operation {
+		case models.OperationInvoice:
+			result.OperationResponse, err = m.applyInvoice(leg.InvoiceRequest(), now)
+		case models.OperationWithdraw:
+			result.OperationResponse, err = m.applyWithdraw(leg.WithdrawRequest(), now)
+		case models.OperationTransfer:
+			result.TransferResponse, err = m.applyTransfer(leg.TransferRequest(), now)
+		case models.OperationExchange:
+			var exchange *models.ExchangeResponse
+			if exchange, err = m.applyExchange(leg.ExchangeRequest(), now); err == nil {
+				result.SetExchange(exchange)
+			}
+		default:
+			err = models.ValidationBatchOperationError
+		}
+		if err != nil {
+			return nil, rollback(batchLegError(i, err))
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpBatch, resp); err != nil {
+		return nil, rollback(err)
+	}
+
+	return resp, nil
+}
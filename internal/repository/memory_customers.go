@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"sort"
+)
+
+func (m *MemoryRepo) CreateCustomer(ctx context.Context, req *models.CreateCustomerRequest) (*models.Customer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if req.ExternalID != "" {
+		for _, customer := range m.customers {
+			if customer.ExternalID == req.ExternalID {
+				return nil, CustomerAlreadyExists(req.ExternalID)
+			}
+		}
+	}
+
+	customer := &models.Customer{
+		CustomerID: len(m.customers) + 1,
+		ExternalID: req.ExternalID,
+		Name:       req.Name,
+		CreatedAt:  m.now(),
+	}
+	m.customers[customer.CustomerID] = customer
+
+	return m.getCustomer(customer.CustomerID)
+}
+
+// getCustomer возвращает копию клиента вместе со списком id его кошельков
+func (m *MemoryRepo) getCustomer(customerID int) (*models.Customer, error) {
+	stored, ok := m.customers[customerID]
+	if !ok {
+		return nil, CustomerDoesntExist(customerID)
+	}
+
+	customer := *stored
+	customer.WalletIDs = make([]int, 0)
+	for _, wallet := range m.wallets {
+		if wallet.CustomerID == customerID {
+			customer.WalletIDs = append(customer.WalletIDs, wallet.WalletID)
+		}
+	}
+	sort.Ints(customer.WalletIDs)
+
+	return &customer, nil
+}
+
+func (m *MemoryRepo) GetCustomer(ctx context.Context, req *models.GetCustomerRequest) (*models.Customer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.getCustomer(req.CustomerID)
+}
+
+func (m *MemoryRepo) AttachWallet(ctx context.Context, req *models.AttachWalletRequest) (*models.Customer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.customers[req.CustomerID]; !ok {
+		return nil, CustomerDoesntExist(req.CustomerID)
+	}
+	wallet, ok := m.wallets[req.WalletID]
+	if !ok {
+		return nil, WalletDoesntExist(req.WalletID)
+	}
+	if wallet.Status == models.WalletStatusClosed {
+		return nil, WalletClosed(req.WalletID)
+	}
+	if wallet.CustomerID != 0 && wallet.CustomerID != req.CustomerID {
+		return nil, WalletOwnedByOtherCustomer(req.WalletID)
+	}
+	wallet.CustomerID = req.CustomerID
+
+	return m.getCustomer(req.CustomerID)
+}
+
+func (m *MemoryRepo) GetCustomerBalance(ctx context.Context, req *models.GetCustomerRequest) (*models.CustomerBalanceResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	customer, err := m.getCustomer(req.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, frozen := m.currentBalances(func(walletID int) bool {
+		return m.wallets[walletID].CustomerID == req.CustomerID
+	})
+
+	return &models.CustomerBalanceResponse{
+		CustomerID:    customer.CustomerID,
+		WalletIDs:     customer.WalletIDs,
+		ActualBalance: actual,
+		FrozenBalance: frozen,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"time"
+)
+
+// memoryOutboxEvent строка таблицы outbox вместе с блокировкой публикации
+type memoryOutboxEvent struct {
+	models.TransactionEvent
+	lockedUntil time.Time
+	published   bool
+}
+
+// recordTransactionEvents сохраняет события о транзакции, номера событий назначаются при выборе для публикации.
+// Для событий финального статуса создаются доставки уведомлений.
+func (m *MemoryRepo) recordTransactionEvents(transaction *models.Transaction, created bool, at time.Time) {
+	for _, eventType := range models.TransactionEventTypes(transaction.Status, created) {
+		event := &memoryOutboxEvent{TransactionEvent: models.TransactionEvent{
+			EventID:             int64(len(m.outbox) + 1),
+			Type:                eventType,
+			WalletID:            transaction.WalletID,
+			TransactionID:       transaction.ID,
+			Ticker:              m.tickersByID[transaction.TickerID].Name,
+			Amount:              transaction.Amount,
+			Status:              transaction.Status,
+			Operation:           transaction.Operation,
+			LinkedTransactionID: transaction.LinkedTransactionID,
+			OccurredAt:          at.UTC(),
+		}}
+		m.outbox = append(m.outbox, event)
+
+		if eventType != models.EventTransactionCreated {
+			m.createWebhookDeliveries(&event.TransactionEvent, at)
+		}
+	}
+}
+
+func (m *MemoryRepo) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.TransactionEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	pending := make([]*memoryOutboxEvent, 0)
+	for _, event := range m.outbox {
+		if event.published {
+			continue
+		}
+		// события выбираются с начала очереди, пока предыдущая выборка не опубликована или не истекла
+		if event.lockedUntil.After(now) {
+			return []models.TransactionEvent{}, nil
+		}
+		pending = append(pending, event)
+	}
+
+	events := make([]models.TransactionEvent, 0, min(len(pending), limit))
+	for _, event := range pending[:min(len(pending), limit)] {
+		// номер назначается при первом выборе и сохраняется при повторной публикации
+		if event.Sequence == 0 {
+			m.eventSequences[event.WalletID]++
+			event.Sequence = m.eventSequences[event.WalletID]
+		}
+		event.lockedUntil = now.Add(lease)
+		events = append(events, event.TransactionEvent)
+	}
+
+	return events, nil
+}
+
+func (m *MemoryRepo) MarkOutboxPublished(ctx context.Context, ids []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		if id <= 0 || id > int64(len(m.outbox)) {
+			continue
+		}
+		event := m.outbox[id-1]
+		event.published, event.lockedUntil = true, time.Time{}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClaimOutboxEventsAssignsWalletSequences(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepo(1)
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.CreateWallet(ctx); err != nil {
+			t.Fatalf("CreateWallet: %v", err)
+		}
+	}
+	for _, name := range []string{"USD", "EUR"} {
+		if _, err := repo.CreateTicker(ctx, &models.CreateTickerRequest{Name: name, Scale: 2}); err != nil {
+			t.Fatalf("CreateTicker(%s): %v", name, err)
+		}
+		fee := &models.FeeRule{Operation: models.OperationInvoice, Ticker: name, Fixed: models.MustParseMoney("0.10")}
+		if err := repo.SetFee(ctx, fee); err != nil {
+			t.Fatalf("SetFee(%s): %v", name, err)
+		}
+	}
+
+	// одновременные операции с разными тикерами одного кошелька, каждая из них зачисляет комиссию на кошелёк 1
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(ticker string) {
+			defer wg.Done()
+			req := &models.InvoiceRequest{WalletID: 2, Ticker: ticker, Amount: models.MustParseMoney("1")}
+			if _, err := repo.Invoice(ctx, req); err != nil {
+				t.Errorf("Invoice(%s): %v", ticker, err)
+			}
+		}([]string{"USD", "EUR"}[i%2])
+	}
+	wg.Wait()
+
+	first, err := repo.ClaimOutboxEvents(ctx, 15, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimOutboxEvents: %v", err)
+	}
+	// блокировка истекла до публикации, события выбираются снова с теми же номерами
+	now = now.Add(2 * time.Minute)
+	again, err := repo.ClaimOutboxEvents(ctx, 15, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimOutboxEvents after lease: %v", err)
+	}
+	for i := range first {
+		if again[i].EventID != first[i].EventID || again[i].Sequence != first[i].Sequence {
+			t.Fatalf("event %d reclaimed as %d with sequence %d, was sequence %d",
+				first[i].EventID, again[i].EventID, again[i].Sequence, first[i].Sequence)
+		}
+	}
+
+	events := make([]models.TransactionEvent, 0)
+	for batch := again; len(batch) > 0; {
+		ids := make([]int64, 0, len(batch))
+		for _, event := range batch {
+			ids = append(ids, event.EventID)
+		}
+		if err := repo.MarkOutboxPublished(ctx, ids); err != nil {
+			t.Fatalf("MarkOutboxPublished: %v", err)
+		}
+		events = append(events, batch...)
+
+		if batch, err = repo.ClaimOutboxEvents(ctx, 15, time.Minute); err != nil {
+			t.Fatalf("ClaimOutboxEvents: %v", err)
+		}
+	}
+
+	last := make(map[int]int64)
+	for _, event := range events {
+		if event.Sequence != last[event.WalletID]+1 {
+			t.Fatalf("event %d of wallet %d has sequence %d, want %d",
+				event.EventID, event.WalletID, event.Sequence, last[event.WalletID]+1)
+		}
+		last[event.WalletID] = event.Sequence
+	}
+	// у каждой транзакции событие создания и событие успешного статуса: на кошельке 2 счёт и списание комиссии,
+	// на кошельке 1 зачисление комиссии
+	if last[1] != 40 || last[2] != 80 {
+		t.Fatalf("last sequences = %v, want 40 events of fee wallet and 80 of wallet 2", last)
+	}
+}
@@ -0,0 +1,948 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+MemoryRepo реализация Repository, которая хранит все данные в памяти процесса.
+Нужна для unit-тестов обработчиков app.App и локального запуска сервиса без PostgreSQL.
+
+Семантика операций совпадает с PostgresRepo: те же ошибки бизнес-логики, статусы транзакций,
+записи о неудачных списаниях, комиссии, ограничения на списания и проводки журнала.
+Все методы выполняются под одним мьютексом, поэтому операции над репозиторием полностью сериализованы
+и повторы при конфликтах не нужны. Ошибки бизнес-логики проверяются до изменения данных, так что отказ
+не оставляет операцию применённой частично.
+*/
+type MemoryRepo struct {
+	mu sync.Mutex
+	// feeWalletID кошелёк, на который зачисляются комиссии за операции
+	feeWalletID int
+	// now источник текущего времени, все записи одной операции получают одно и то же время
+	now func() time.Time
+
+	wallets      map[int]*models.Wallet
+	tickers      map[string]*models.Ticker
+	tickersByID  map[int]*models.Ticker
+	balances     map[balanceKey]models.Money
+	transactions []*memoryTransaction
+	journal      []*models.JournalEntry
+	rates        map[exchangePair]models.ExchangeRate
+	// limits ограничения на списания, общие ограничения хранятся с walletID = 0
+	limits      map[balanceKey]models.WithdrawalLimit
+	fees        map[feeKey]models.FeeRule
+	idempotency map[idempotencyKey]idempotentResponse
+	customers   map[int]*models.Customer
+	// scheduled запланированные платежи, id платежа равен индексу + 1
+	scheduled []*memoryScheduledPayment
+	// outbox события о транзакциях, id события равен индексу + 1
+	outbox         []*memoryOutboxEvent
+	eventSequences map[int]int64
+	// webhooks адреса для уведомлений, удалённые адреса остаются в срезе как nil, id адреса равен индексу + 1
+	webhooks []*models.Webhook
+	// deliveries доставки уведомлений, id доставки равен индексу + 1
+	deliveries []*memoryWebhookDelivery
+	// audit журнал аудита, id записи равен индексу + 1
+	audit []*models.AuditRecord
+}
+
+var _ Repository = (*MemoryRepo)(nil)
+
+// memoryTransaction строка таблицы transactions вместе со временем создания и изменения.
+// Архивные транзакции остаются в срезе и отличаются только флагом archived.
+type memoryTransaction struct {
+	models.Transaction
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	archived  bool
+}
+
+type exchangePair struct {
+	fromTickerID int
+	toTickerID   int
+}
+
+type feeKey struct {
+	operation string
+	tickerID  int
+}
+
+// idempotencyKey ключ идемпотентности запроса, ключи разных операций не пересекаются
+type idempotencyKey struct {
+	key       string
+	operation string
+}
+
+// idempotentResponse сохранённый ответ на запрос или ошибка бизнес-логики, если код ошибки не пустой
+type idempotentResponse struct {
+	response []byte
+	failure  LogicErrors
+}
+
+// NewMemoryRepo создаёт пустой репозиторий в памяти. feeWalletID кошелёк для зачисления комиссий,
+// 0 если комиссии не настроены
+func NewMemoryRepo(feeWalletID int) *MemoryRepo {
+	return &MemoryRepo{
+		feeWalletID:    feeWalletID,
+		now:            time.Now,
+		wallets:        make(map[int]*models.Wallet),
+		tickers:        make(map[string]*models.Ticker),
+		tickersByID:    make(map[int]*models.Ticker),
+		balances:       make(map[balanceKey]models.Money),
+		rates:          make(map[exchangePair]models.ExchangeRate),
+		limits:         make(map[balanceKey]models.WithdrawalLimit),
+		fees:           make(map[feeKey]models.FeeRule),
+		idempotency:    make(map[idempotencyKey]idempotentResponse),
+		customers:      make(map[int]*models.Customer),
+		eventSequences: make(map[int]int64),
+	}
+}
+
+func (m *MemoryRepo) CreateWallet(ctx context.Context) (*models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wallet := &models.Wallet{WalletID: len(m.wallets) + 1, Status: models.WalletStatusActive, StatusChangedAt: m.now()}
+	m.wallets[wallet.WalletID] = wallet
+	copied := *wallet
+
+	return &copied, nil
+}
+
+func (m *MemoryRepo) GetWallet(ctx context.Context, req *models.GetWalletRequest) (*models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wallet, ok := m.wallets[req.WalletID]
+	if !ok {
+		return nil, WalletDoesntExist(req.WalletID)
+	}
+	copied := *wallet
+
+	return &copied, nil
+}
+
+func (m *MemoryRepo) SetWalletStatus(ctx context.Context, req *models.SetWalletStatusRequest) (*models.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wallet, ok := m.wallets[req.WalletID]
+	if !ok {
+		return nil, WalletDoesntExist(req.WalletID)
+	}
+	if wallet.Status == models.WalletStatusClosed {
+		return nil, WalletClosed(req.WalletID)
+	}
+
+	// закрыть можно только пустой кошелёк, как и в PostgresRepo
+	if req.Status == models.WalletStatusClosed {
+		for key, amount := range m.balances {
+			if key.walletID == req.WalletID && amount.IsPositive() {
+				return nil, WalletNotEmpty(req.WalletID)
+			}
+		}
+		for _, t := range m.transactions {
+			if t.WalletID == req.WalletID && t.Status == models.TransactionStatusCreated {
+				return nil, WalletNotEmpty(req.WalletID)
+			}
+		}
+	}
+
+	wallet.Status, wallet.StatusReason, wallet.StatusChangedAt = req.Status, req.Reason, m.now()
+	copied := *wallet
+
+	return &copied, nil
+}
+
+// getTickerByName возвращает тикер по названию или ошибку TickerDoesntExist
+func (m *MemoryRepo) getTickerByName(name string) (*models.Ticker, error) {
+	ticker, ok := m.tickers[name]
+	if !ok {
+		return nil, TickerDoesntExist(name)
+	}
+
+	return ticker, nil
+}
+
+// checkWallet проверяет что кошелёк существует и с ним разрешены операции
+func (m *MemoryRepo) checkWallet(walletID int) error {
+	wallet, ok := m.wallets[walletID]
+	if !ok {
+		return WalletDoesntExist(walletID)
+	}
+
+	return checkWalletActive(walletID, wallet.Status, wallet.StatusReason)
+}
+
+// findIdempotentResponse ищет сохранённый ответ на запрос с ключом key и записывает его в resp.
+// Если запрос завершился ошибкой бизнес-логики, то возвращает ту же ошибку.
+func (m *MemoryRepo) findIdempotentResponse(key, operation string, resp any) (bool, error) {
+	if key == "" {
+		return false, nil
+	}
+
+	stored, ok := m.idempotency[idempotencyKey{key: key, operation: operation}]
+	if !ok {
+		return false, nil
+	}
+	if stored.failure.Code != "" {
+		return false, stored.failure
+	}
+
+	if err := json.Unmarshal(stored.response, resp); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// saveIdempotentResponse сохраняет ответ в том же виде, что и PostgresRepo, чтобы повтор возвращал копию ответа
+func (m *MemoryRepo) saveIdempotentResponse(key, operation string, resp any) error {
+	if key == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	m.idempotency[idempotencyKey{key: key, operation: operation}] = idempotentResponse{response: body}
+
+	return nil
+}
+
+// saveIdempotentError сохраняет ошибку бизнес-логики queryError, которой завершился запрос с ключом key, и возвращает её.
+// Остальные ошибки не сохраняются, а уже сохранённый ответ не меняется.
+func (m *MemoryRepo) saveIdempotentError(key, operation string, queryError error) error {
+	var outcome LogicErrors
+	if key == "" || !errors.As(queryError, &outcome) {
+		return queryError
+	}
+
+	k := idempotencyKey{key: key, operation: operation}
+	if _, ok := m.idempotency[k]; !ok {
+		m.idempotency[k] = idempotentResponse{failure: outcome}
+	}
+
+	return queryError
+}
+
+// createTransaction добавляет запись о транзакции и присваивает ей id
+func (m *MemoryRepo) createTransaction(transaction *models.Transaction, at time.Time) {
+	transaction.ID = len(m.transactions) + 1
+	m.transactions = append(m.transactions, &memoryTransaction{Transaction: *transaction, CreatedAt: at, UpdatedAt: at})
+	m.recordTransactionEvents(transaction, true, at)
+}
+
+// updateTransaction сохраняет статус и связанную транзакцию записи transaction
+func (m *MemoryRepo) updateTransaction(transaction *models.Transaction, at time.Time) {
+	stored := m.transactions[transaction.ID-1]
+	changed := stored.Status != transaction.Status
+	if changed {
+		stored.UpdatedAt = at
+	}
+	stored.Status, stored.LinkedTransactionID = transaction.Status, transaction.LinkedTransactionID
+	if changed {
+		m.recordTransactionEvents(&stored.Transaction, false, at)
+	}
+}
+
+// getTransaction возвращает копию записи о транзакции или nil, если её нет
+func (m *MemoryRepo) getTransaction(transactionID int) *models.Transaction {
+	if transactionID <= 0 || transactionID > len(m.transactions) {
+		return nil
+	}
+	transaction := m.transactions[transactionID-1].Transaction
+
+	return &transaction
+}
+
+// postEntry проверяет что запись журнала сбалансирована и сохраняет её
+func (m *MemoryRepo) postEntry(entry *models.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	entry.ID = len(m.journal) + 1
+	m.journal = append(m.journal, entry)
+
+	return nil
+}
+
+// checkBalance проверяет что на кошельке хватает средств для списания amount вместе с комиссией fee.
+// Как и в PostgresRepo, при нехватке средств на существующем балансе сохраняется запись о неудачном списании.
+func (m *MemoryRepo) checkBalance(walletID int, ticker *models.Ticker, amount, fee models.Money, operation string, at time.Time) error {
+	balance, ok := m.balances[balanceKey{walletID: walletID, tickerID: ticker.TickerID}]
+	if !ok {
+		return NotEnoughCoins(walletID, ticker.Name)
+	}
+	if balance.Cmp(amount.Add(fee)) < 0 {
+		m.createTransaction(&models.Transaction{
+			WalletID:  walletID,
+			TickerID:  ticker.TickerID,
+			Amount:    amount.Neg(),
+			Status:    models.TransactionStatusError,
+			Operation: operation,
+		}, at)
+
+		return NotEnoughCoins(walletID, ticker.Name)
+	}
+
+	return nil
+}
+
+// addBalance изменяет баланс кошелька по тикеру на amount
+func (m *MemoryRepo) addBalance(walletID, tickerID int, amount models.Money) {
+	key := balanceKey{walletID: walletID, tickerID: tickerID}
+	m.balances[key] = m.balances[key].Add(amount)
+}
+
+// createFeeTransaction создаёт связанную с операцией transaction запись о списании комиссии
+func (m *MemoryRepo) createFeeTransaction(transaction *models.Transaction, fee models.Money, at time.Time) *models.Transaction {
+	feeTransaction := &models.Transaction{
+		WalletID:            transaction.WalletID,
+		TickerID:            transaction.TickerID,
+		Amount:              fee.Neg(),
+		Status:              transaction.Status,
+		Operation:           models.OperationFee,
+		LinkedTransactionID: transaction.ID,
+	}
+	m.createTransaction(feeTransaction, at)
+	transaction.LinkedTransactionID = feeTransaction.ID
+	m.updateTransaction(transaction, at)
+
+	return feeTransaction
+}
+
+// creditFeeWallet зачисляет списанную комиссию на кошелёк комиссий
+func (m *MemoryRepo) creditFeeWallet(feeTransaction *models.Transaction, at time.Time) *models.Transaction {
+	credit := &models.Transaction{
+		WalletID:            m.feeWalletID,
+		TickerID:            feeTransaction.TickerID,
+		Amount:              feeTransaction.Amount.Neg(),
+		Status:              models.TransactionStatusSuccess,
+		Operation:           models.OperationFee,
+		LinkedTransactionID: feeTransaction.ID,
+	}
+	m.createTransaction(credit, at)
+	m.addBalance(credit.WalletID, credit.TickerID, credit.Amount)
+
+	return credit
+}
+
+// ApplyFeeCredits ничего не делает, в памяти комиссия сразу зачисляется на баланс кошелька комиссий
+func (m *MemoryRepo) ApplyFeeCredits(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *MemoryRepo) Invoice(ctx context.Context, req *models.InvoiceRequest) (_ *models.OperationResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpInvoice, err) }()
+
+	resp := &models.OperationResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpInvoice, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	resp, err = m.applyInvoice(req, m.now())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpInvoice, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// applyInvoice выполняет зачисление без проверки ключа идемпотентности, вызывается под мьютексом
+func (m *MemoryRepo) applyInvoice(req *models.InvoiceRequest, now time.Time) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+	if err := m.checkWallet(req.WalletID); err != nil {
+		return nil, err
+	}
+	fee, err := m.calculateFee(models.OperationInvoice, ticker, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if fee.Cmp(req.Amount) >= 0 {
+		return nil, FeeExceedsAmount(req.Ticker, fee)
+	}
+
+	transaction := &models.Transaction{
+		WalletID:  req.WalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount,
+		Status:    models.TransactionStatusSuccess,
+		Operation: models.OperationInvoice,
+	}
+	m.createTransaction(transaction, now)
+	entry := (&models.JournalEntry{Operation: models.OperationInvoice}).
+		SystemPosting(models.AccountCashIn, ticker.TickerID, req.Amount.Neg()).
+		WalletPosting(transaction, req.Amount)
+	if fee.IsPositive() {
+		feeTransaction := m.createFeeTransaction(transaction, fee, now)
+		feeCredit := m.creditFeeWallet(feeTransaction, now)
+		entry.WalletPosting(feeTransaction, fee.Neg()).WalletPosting(feeCredit, fee)
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
+	}
+	m.addBalance(req.WalletID, ticker.TickerID, req.Amount.Sub(fee))
+
+	if err := m.postEntry(entry); err != nil {
+		return nil, err
+	}
+
+	resp.TransactionID = transaction.ID
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) WithDraw(ctx context.Context, req *models.WithdrawRequest) (_ *models.OperationResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpWithdraw, err) }()
+
+	resp := &models.OperationResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpWithdraw, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	resp, err = m.applyWithdraw(req, m.now())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpWithdraw, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// applyWithdraw замораживает списание без проверки ключа идемпотентности, вызывается под мьютексом
+func (m *MemoryRepo) applyWithdraw(req *models.WithdrawRequest, now time.Time) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+	if err := m.checkWallet(req.WalletID); err != nil {
+		return nil, err
+	}
+	if err := m.checkWithdrawalLimits(req.WalletID, ticker, req.Amount, now); err != nil {
+		return nil, err
+	}
+	fee, err := m.calculateFee(models.OperationWithdraw, ticker, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkBalance(req.WalletID, ticker, req.Amount, fee, models.OperationWithdraw, now); err != nil {
+		return nil, err
+	}
+
+	// списание и комиссия остаются замороженными до Capture или Release
+	transaction := &models.Transaction{
+		WalletID:  req.WalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount.Neg(),
+		Status:    models.TransactionStatusCreated,
+		Operation: models.OperationWithdraw,
+	}
+	m.createTransaction(transaction, now)
+	entry := (&models.JournalEntry{Operation: models.OperationWithdraw}).
+		WalletPosting(transaction, req.Amount.Neg()).
+		SystemPosting(models.AccountHolds, ticker.TickerID, req.Amount)
+	if fee.IsPositive() {
+		feeTransaction := m.createFeeTransaction(transaction, fee, now)
+		entry.WalletPosting(feeTransaction, fee.Neg()).SystemPosting(models.AccountHolds, ticker.TickerID, fee)
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
+	}
+	m.addBalance(req.WalletID, ticker.TickerID, req.Amount.Add(fee).Neg())
+
+	if err := m.postEntry(entry); err != nil {
+		return nil, err
+	}
+
+	resp.TransactionID = transaction.ID
+
+	return resp, nil
+}
+
+// getHeldTransaction возвращает незавершённое списание и привязанную к нему комиссию, если она есть
+func (m *MemoryRepo) getHeldTransaction(transactionID int) (*models.Transaction, *models.Transaction, error) {
+	transaction := m.getTransaction(transactionID)
+	if transaction == nil {
+		return nil, nil, TransactionDoesntExist(transactionID)
+	}
+	if transaction.Status != models.TransactionStatusCreated || transaction.Amount.Sign() >= 0 || transaction.Operation == models.OperationFee {
+		return nil, nil, TransactionNotHeld(transactionID)
+	}
+
+	fee := m.getTransaction(transaction.LinkedTransactionID)
+	if fee != nil && fee.Operation != models.OperationFee {
+		fee = nil
+	}
+
+	return transaction, fee, nil
+}
+
+func (m *MemoryRepo) Capture(ctx context.Context, req *models.HoldRequest) (_ *models.OperationResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpCapture, err) }()
+
+	resp := &models.OperationResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpCapture, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	transaction, feeTransaction, err := m.getHeldTransaction(req.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	transaction.Status = models.TransactionStatusSuccess
+	m.updateTransaction(transaction, now)
+	entry := (&models.JournalEntry{Operation: models.OperationWithdraw}).
+		SystemPosting(models.AccountHolds, transaction.TickerID, transaction.Amount).
+		SystemPosting(models.AccountCashOut, transaction.TickerID, transaction.Amount.Neg())
+	if feeTransaction != nil {
+		feeTransaction.Status = models.TransactionStatusSuccess
+		m.updateTransaction(feeTransaction, now)
+		feeCredit := m.creditFeeWallet(feeTransaction, now)
+		fee := feeTransaction.Amount.Neg()
+		entry.SystemPosting(models.AccountHolds, transaction.TickerID, feeTransaction.Amount).WalletPosting(feeCredit, fee)
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
+	}
+
+	if err := m.postEntry(entry); err != nil {
+		return nil, err
+	}
+
+	resp.TransactionID = transaction.ID
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpCapture, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) Release(ctx context.Context, req *models.HoldRequest) (_ *models.OperationResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpRelease, err) }()
+
+	resp := &models.OperationResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpRelease, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	transaction, feeTransaction, err := m.getHeldTransaction(req.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	entry := &models.JournalEntry{Operation: models.OperationWithdraw}
+	for _, t := range []*models.Transaction{transaction, feeTransaction} {
+		if t == nil {
+			continue
+		}
+		// замороженная сумма возвращается на кошелёк, суммы списаний хранятся с минусом
+		entry.SystemPosting(models.AccountHolds, t.TickerID, t.Amount).WalletPosting(t, t.Amount.Neg())
+		m.addBalance(t.WalletID, t.TickerID, t.Amount.Neg())
+		t.Status = models.TransactionStatusError
+		m.updateTransaction(t, now)
+	}
+	if feeTransaction != nil {
+		fee := feeTransaction.Amount.Neg()
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
+	}
+
+	if err := m.postEntry(entry); err != nil {
+		return nil, err
+	}
+
+	resp.TransactionID = transaction.ID
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpRelease, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) Transfer(ctx context.Context, req *models.TransferRequest) (_ *models.TransferResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpTransfer, err) }()
+
+	resp := &models.TransferResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpTransfer, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	resp, err = m.applyTransfer(req, m.now())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpTransfer, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// applyTransfer выполняет перевод без проверки ключа идемпотентности, вызывается под мьютексом
+func (m *MemoryRepo) applyTransfer(req *models.TransferRequest, now time.Time) (*models.TransferResponse, error) {
+	resp := &models.TransferResponse{}
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+	for _, walletID := range []int{req.FromWalletID, req.ToWalletID} {
+		if err := m.checkWallet(walletID); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkBalance(req.FromWalletID, ticker, req.Amount, models.Money{}, models.OperationTransfer, now); err != nil {
+		return nil, err
+	}
+
+	debit := &models.Transaction{
+		WalletID:  req.FromWalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount.Neg(),
+		Status:    models.TransactionStatusSuccess,
+		Operation: models.OperationTransfer,
+	}
+	credit := &models.Transaction{
+		WalletID:  req.ToWalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount,
+		Status:    models.TransactionStatusSuccess,
+		Operation: models.OperationTransfer,
+	}
+	for _, transaction := range []*models.Transaction{debit, credit} {
+		m.createTransaction(transaction, now)
+		m.addBalance(transaction.WalletID, transaction.TickerID, transaction.Amount)
+	}
+
+	entry := (&models.JournalEntry{Operation: models.OperationTransfer}).
+		WalletPosting(debit, debit.Amount).
+		WalletPosting(credit, credit.Amount)
+	if err := m.postEntry(entry); err != nil {
+		return nil, err
+	}
+
+	resp.DebitTransactionID, resp.CreditTransactionID = debit.ID, credit.ID
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) Exchange(ctx context.Context, req *models.ExchangeRequest) (_ *models.ExchangeResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpExchange, err) }()
+
+	resp := &models.ExchangeResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpExchange, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	resp, err = m.applyExchange(req, m.now())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpExchange, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// applyExchange обменивает средства без проверки ключа идемпотентности, вызывается под мьютексом
+func (m *MemoryRepo) applyExchange(req *models.ExchangeRequest, now time.Time) (*models.ExchangeResponse, error) {
+	resp := &models.ExchangeResponse{}
+	from, err := m.getTickerByName(req.FromTicker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, from); err != nil {
+		return nil, err
+	}
+	to, err := m.getTickerByName(req.ToTicker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTickerEnabled(to); err != nil {
+		return nil, err
+	}
+	if err := m.checkWallet(req.WalletID); err != nil {
+		return nil, err
+	}
+
+	rate, ok := m.rates[exchangePair{fromTickerID: from.TickerID, toTickerID: to.TickerID}]
+	if !ok {
+		return nil, ExchangeRateDoesntExist(req.FromTicker, req.ToTicker)
+	}
+	creditAmount := req.Amount.Mul(rate.Rate, to.Scale, models.RoundHalfEven)
+	if err := checkExchangeAmount(creditAmount, from, to); err != nil {
+		return nil, err
+	}
+	if err := m.checkBalance(req.WalletID, from, req.Amount, models.Money{}, models.OperationExchange, now); err != nil {
+		return nil, err
+	}
+
+	debit := &models.Transaction{
+		WalletID:  req.WalletID,
+		TickerID:  from.TickerID,
+		Amount:    req.Amount.Neg(),
+		Status:    models.TransactionStatusSuccess,
+		Operation: models.OperationExchange,
+	}
+	m.createTransaction(debit, now)
+	credit := &models.Transaction{
+		WalletID:            req.WalletID,
+		TickerID:            to.TickerID,
+		Amount:              creditAmount,
+		Status:              models.TransactionStatusSuccess,
+		Operation:           models.OperationExchange,
+		LinkedTransactionID: debit.ID,
+	}
+	m.createTransaction(credit, now)
+	debit.LinkedTransactionID = credit.ID
+	m.updateTransaction(debit, now)
+	m.addBalance(req.WalletID, from.TickerID, req.Amount.Neg())
+	m.addBalance(req.WalletID, to.TickerID, creditAmount)
+
+	entry := (&models.JournalEntry{Operation: models.OperationExchange}).
+		WalletPosting(debit, debit.Amount).
+		SystemPosting(models.AccountExchange, from.TickerID, req.Amount).
+		SystemPosting(models.AccountExchange, to.TickerID, creditAmount.Neg()).
+		WalletPosting(credit, credit.Amount)
+	if err := m.postEntry(entry); err != nil {
+		return nil, err
+	}
+
+	resp.DebitTransactionID, resp.CreditTransactionID = debit.ID, credit.ID
+	resp.Rate, resp.CreditAmount = rate.Rate, creditAmount
+
+	return resp, nil
+}
+
+/*
+GetBalance возвращает актуальный баланс из balances и сумму замороженных списаний.
+Баланс на момент в прошлом считается по всем транзакциям кошелька по тем же правилам, что и actualAtExpr
+и frozenAtExpr в PostgresRepo, поэтому снимки балансов в памяти не нужны.
+*/
+func (m *MemoryRepo) GetBalance(ctx context.Context, req *models.GetBalanceRequest) (*models.GetBalanceResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.wallets[req.WalletID]; !ok {
+		return nil, WalletDoesntExist(req.WalletID)
+	}
+
+	if req.BalanceAt == nil {
+		actual, frozen := m.currentBalances(func(walletID int) bool { return walletID == req.WalletID })
+		return &models.GetBalanceResponse{ActualBalance: actual, FrozenBalance: frozen}, nil
+	}
+
+	at := *req.BalanceAt
+	actual := make(map[int]models.Money)
+	frozen := make(map[int]models.Money)
+	for _, t := range m.transactions {
+		if t.WalletID != req.WalletID || t.CreatedAt.After(at) {
+			continue
+		}
+		changedAfter := t.UpdatedAt.After(at)
+		if t.Status != models.TransactionStatusError || changedAfter {
+			actual[t.TickerID] = actual[t.TickerID].Add(t.Amount)
+		}
+		if t.Status == models.TransactionStatusCreated || changedAfter {
+			frozen[t.TickerID] = frozen[t.TickerID].Sub(t.Amount)
+		}
+	}
+
+	// как и в getBalanceAt, выводятся только ненулевые балансы
+	resp := &models.GetBalanceResponse{
+		ActualBalance: m.tickerBalances(actual, true),
+		FrozenBalance: m.tickerBalances(frozen, true),
+		BalanceAt:     req.BalanceAt,
+	}
+
+	return resp, nil
+}
+
+// currentBalances складывает актуальный баланс и замороженные списания кошельков, для которых inWallet возвращает true
+func (m *MemoryRepo) currentBalances(inWallet func(walletID int) bool) (map[string]models.Money, map[string]models.Money) {
+	actual := make(map[int]models.Money)
+	frozen := make(map[int]models.Money)
+	for key, amount := range m.balances {
+		if inWallet(key.walletID) {
+			actual[key.tickerID] = actual[key.tickerID].Add(amount)
+		}
+	}
+	for _, t := range m.transactions {
+		if inWallet(t.WalletID) && t.Status == models.TransactionStatusCreated {
+			frozen[t.TickerID] = frozen[t.TickerID].Sub(t.Amount)
+		}
+	}
+
+	return m.tickerBalances(actual, false), m.tickerBalances(frozen, false)
+}
+
+// tickerBalances переводит суммы по id тикеров в суммы по названиям с точностью тикера
+func (m *MemoryRepo) tickerBalances(amounts map[int]models.Money, skipZero bool) map[string]models.Money {
+	balances := make(map[string]models.Money, len(amounts))
+	for tickerID, amount := range amounts {
+		if skipZero && amount.IsZero() {
+			continue
+		}
+		ticker := m.tickersByID[tickerID]
+		balances[ticker.Name] = amount.Round(ticker.Scale, models.RoundDown)
+	}
+
+	return balances
+}
+
+func (m *MemoryRepo) ListTransactions(ctx context.Context, req *models.HistoryRequest) (*models.HistoryResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.wallets[req.WalletID]; !ok {
+		return nil, WalletDoesntExist(req.WalletID)
+	}
+
+	cursorID, err := req.CursorID()
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.PageSize()
+	resp := &models.HistoryResponse{Transactions: make([]models.TransactionInfo, 0, limit)}
+	// id транзакции совпадает с её позицией в m.transactions, поэтому идём от новых к старым
+	for i := len(m.transactions) - 1; i >= 0 && len(resp.Transactions) <= limit; i-- {
+		t := m.transactions[i]
+		ticker := m.tickersByID[t.TickerID]
+		switch {
+		case t.WalletID != req.WalletID,
+			cursorID != 0 && t.ID >= cursorID,
+			req.Ticker != "" && ticker.Name != req.Ticker,
+			req.Status != nil && t.Status != *req.Status,
+			req.From != nil && t.CreatedAt.Before(*req.From),
+			req.To != nil && !t.CreatedAt.Before(*req.To):
+			continue
+		}
+
+		resp.Transactions = append(resp.Transactions, models.TransactionInfo{
+			ID:        t.ID,
+			Ticker:    ticker.Name,
+			Amount:    t.Amount.Round(ticker.Scale, models.RoundDown),
+			Status:    t.Status,
+			CreatedAt: t.CreatedAt,
+			UpdatedAt: t.UpdatedAt,
+		})
+	}
+
+	// если нашли больше транзакций чем размер страницы, то есть следующая страница
+	if len(resp.Transactions) > limit {
+		resp.Transactions = resp.Transactions[:limit]
+		resp.NextCursor = models.EncodeCursor(resp.Transactions[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+// Reconcile сравнивает balances с суммой успешных транзакций и замороженных списаний, как и PostgresRepo
+func (m *MemoryRepo) Reconcile(ctx context.Context) (*models.ReconciliationReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := &models.ReconciliationReport{StartedAt: m.now(), Mismatches: make([]models.BalanceMismatch, 0)}
+
+	expected := make(map[balanceKey]models.Money)
+	for _, t := range m.transactions {
+		if t.Status == models.TransactionStatusSuccess || t.Status == models.TransactionStatusCreated {
+			key := balanceKey{walletID: t.WalletID, tickerID: t.TickerID}
+			expected[key] = expected[key].Add(t.Amount)
+		}
+	}
+	keys := make([]balanceKey, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	for key := range m.balances {
+		if _, ok := expected[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].walletID != keys[j].walletID {
+			return keys[i].walletID < keys[j].walletID
+		}
+		return m.tickersByID[keys[i].tickerID].Name < m.tickersByID[keys[j].tickerID].Name
+	})
+
+	for _, key := range keys {
+		mismatch := models.BalanceMismatch{
+			WalletID: key.walletID,
+			Ticker:   m.tickersByID[key.tickerID].Name,
+			Balance:  m.balances[key],
+			Expected: expected[key],
+		}
+		report.Checked++
+
+		if mismatch.Balance.Cmp(mismatch.Expected) != 0 {
+			mismatch.Difference = mismatch.Balance.Sub(mismatch.Expected)
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+	}
+	report.FinishedAt = m.now()
+
+	return report, nil
+}
+
+// CreateBalanceSnapshot ничего не делает: баланс на момент в прошлом считается по всем транзакциям
+func (m *MemoryRepo) CreateBalanceSnapshot(ctx context.Context, at time.Time) error {
+	return nil
+}
+
+func (m *MemoryRepo) Close() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+)
+
+// reversibleRemaining сумма транзакции original, которую ещё можно отменить, по тем же правилам что и в PostgresRepo
+func (m *MemoryRepo) reversibleRemaining(original *models.Transaction) models.Money {
+	remaining := original.Amount.Neg()
+	if original.Operation == models.OperationInvoice {
+		remaining = original.Amount
+	}
+
+	for _, t := range m.transactions {
+		if t.LinkedTransactionID != original.ID || t.Status != models.TransactionStatusSuccess {
+			continue
+		}
+		switch t.Operation {
+		case models.OperationFee:
+			if original.Operation == models.OperationInvoice {
+				remaining = remaining.Add(t.Amount)
+			}
+		case models.OperationReversal:
+			// отмены хранятся со знаком, противоположным исходной транзакции
+			if t.Amount.Sign() < 0 {
+				remaining = remaining.Add(t.Amount)
+			} else {
+				remaining = remaining.Sub(t.Amount)
+			}
+		}
+	}
+
+	return remaining
+}
+
+func (m *MemoryRepo) Reverse(ctx context.Context, req *models.ReverseRequest) (_ *models.ReverseResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer func() { err = m.saveIdempotentError(req.IdempotencyKey, idempotencyOpReverse, err) }()
+
+	resp := &models.ReverseResponse{}
+	if found, err := m.findIdempotentResponse(req.IdempotencyKey, idempotencyOpReverse, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	original := m.getTransaction(req.TransactionID)
+	if original == nil {
+		return nil, TransactionDoesntExist(req.TransactionID)
+	}
+	if !isReversible(original) || m.transactions[original.ID-1].archived {
+		return nil, TransactionNotReversible(original.ID)
+	}
+	ticker := m.tickersByID[original.TickerID]
+
+	remaining := m.reversibleRemaining(original)
+	if !remaining.IsPositive() {
+		return nil, TransactionAlreadyReversed(original.ID)
+	}
+	amount := remaining
+	if req.Amount != nil {
+		amount = *req.Amount
+		if err := checkAmountScale(amount, ticker); err != nil {
+			return nil, err
+		}
+		if amount.Cmp(remaining) > 0 {
+			return nil, ReversalExceedsAmount(original.ID, ticker.Name, remaining)
+		}
+	}
+
+	if m.wallets[original.WalletID].Status == models.WalletStatusClosed {
+		return nil, WalletClosed(original.WalletID)
+	}
+
+	now := m.now()
+	delta := amount
+	if original.Amount.IsPositive() {
+		delta = amount.Neg()
+		if balance, ok := m.balances[balanceKey{walletID: original.WalletID, tickerID: original.TickerID}]; !ok || balance.Cmp(amount) < 0 {
+			m.createTransaction(&models.Transaction{
+				WalletID:            original.WalletID,
+				TickerID:            original.TickerID,
+				Amount:              delta,
+				Status:              models.TransactionStatusError,
+				LinkedTransactionID: original.ID,
+				Operation:           models.OperationReversal,
+			}, now)
+
+			return nil, NotEnoughCoins(original.WalletID, ticker.Name)
+		}
+	}
+
+	transaction := &models.Transaction{
+		WalletID:            original.WalletID,
+		TickerID:            original.TickerID,
+		Amount:              delta,
+		Status:              models.TransactionStatusSuccess,
+		LinkedTransactionID: original.ID,
+		Operation:           models.OperationReversal,
+	}
+	m.createTransaction(transaction, now)
+	m.addBalance(original.WalletID, original.TickerID, delta)
+
+	entry := (&models.JournalEntry{Operation: models.OperationReversal}).
+		WalletPosting(transaction, delta).
+		SystemPosting(reversalAccount(original.Operation), original.TickerID, delta.Neg())
+	if err := m.postEntry(entry); err != nil {
+		return nil, err
+	}
+
+	resp.TransactionID = transaction.ID
+	resp.ReversedTransactionID = original.ID
+	resp.Amount = amount
+	resp.Remaining = remaining.Sub(amount)
+	if err := m.saveIdempotentResponse(req.IdempotencyKey, idempotencyOpReverse, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"sort"
+	"time"
+)
+
+// memoryScheduledPayment строка таблицы scheduled_payments вместе с блокировкой планировщика
+type memoryScheduledPayment struct {
+	models.ScheduledPayment
+	lockedUntil time.Time
+}
+
+func (m *MemoryRepo) getScheduledPayment(id int) (*memoryScheduledPayment, error) {
+	if id <= 0 || id > len(m.scheduled) {
+		return nil, ScheduledPaymentDoesntExist(id)
+	}
+
+	return m.scheduled[id-1], nil
+}
+
+func (m *MemoryRepo) CreateScheduledPayment(ctx context.Context, req *models.CreateScheduledPaymentRequest) (*models.ScheduledPayment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAmountScale(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+	for _, walletID := range []int{req.WalletID, req.ToWalletID} {
+		if walletID == 0 {
+			continue
+		}
+		if err := m.checkWallet(walletID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := m.now()
+	payment := models.ScheduledPayment{
+		ScheduledPaymentID: len(m.scheduled) + 1,
+		Operation:          req.Operation,
+		WalletID:           req.WalletID,
+		ToWalletID:         req.ToWalletID,
+		Ticker:             ticker.Name,
+		Amount:             req.Amount,
+		Repeat:             req.RepeatOrOnce(),
+		StartAt:            now,
+		EndAt:              req.EndAt,
+		Status:             models.ScheduledPaymentActive,
+		CreatedAt:          now,
+	}
+	if req.StartAt != nil {
+		payment.StartAt = *req.StartAt
+	}
+	next, _ := payment.NextRun(0)
+	payment.NextRunAt = &next
+	m.scheduled = append(m.scheduled, &memoryScheduledPayment{ScheduledPayment: payment})
+
+	return &payment, nil
+}
+
+func (m *MemoryRepo) ListScheduledPayments(ctx context.Context, req *models.ScheduledPaymentsRequest) (*models.ScheduledPaymentsResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &models.ScheduledPaymentsResponse{ScheduledPayments: make([]models.ScheduledPayment, 0)}
+	for _, payment := range m.scheduled {
+		if payment.WalletID == req.WalletID || payment.ToWalletID == req.WalletID {
+			resp.ScheduledPayments = append(resp.ScheduledPayments, payment.ScheduledPayment)
+		}
+	}
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) CancelScheduledPayment(ctx context.Context, req *models.CancelScheduledPaymentRequest) (*models.ScheduledPayment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payment, err := m.getScheduledPayment(req.ScheduledPaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.ScheduledPaymentActive {
+		return nil, ScheduledPaymentNotActive(payment.ScheduledPaymentID, payment.Status)
+	}
+
+	payment.Status, payment.NextRunAt = models.ScheduledPaymentCancelled, nil
+	copied := payment.ScheduledPayment
+
+	return &copied, nil
+}
+
+func (m *MemoryRepo) ClaimDueScheduledPayments(ctx context.Context, limit int, lease time.Duration) ([]models.ScheduledPayment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	due := make([]*memoryScheduledPayment, 0)
+	for _, payment := range m.scheduled {
+		if payment.Status == models.ScheduledPaymentActive && !payment.NextRunAt.After(now) && payment.lockedUntil.Before(now) {
+			due = append(due, payment)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(*due[j].NextRunAt)
+	})
+
+	payments := make([]models.ScheduledPayment, 0, min(len(due), limit))
+	for _, payment := range due[:min(len(due), limit)] {
+		payment.lockedUntil = now.Add(lease)
+		payments = append(payments, payment.ScheduledPayment)
+	}
+
+	return payments, nil
+}
+
+func (m *MemoryRepo) CompleteScheduledRun(ctx context.Context, result *models.ScheduledRunResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payment, err := m.getScheduledPayment(result.ScheduledPaymentID)
+	if err != nil {
+		return err
+	}
+	// результат этого запуска уже сохранён
+	if payment.Runs != result.Run {
+		return nil
+	}
+
+	now := m.now()
+	payment.Runs++
+	payment.lockedUntil = time.Time{}
+	payment.LastRunAt, payment.LastTransactionID, payment.LastError = &now, result.TransactionID, result.Error
+	next, ok := payment.NextRun(payment.Runs)
+	switch {
+	case payment.Status != models.ScheduledPaymentActive:
+		payment.NextRunAt = nil
+	case !ok:
+		payment.Status, payment.NextRunAt = models.ScheduledPaymentCompleted, nil
+	default:
+		payment.NextRunAt = &next
+	}
+
+	return nil
+}
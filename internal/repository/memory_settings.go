@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+func (m *MemoryRepo) SetExchangeRate(ctx context.Context, req *models.ExchangeRate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, err := m.getTickerByName(req.FromTicker)
+	if err != nil {
+		return err
+	}
+	to, err := m.getTickerByName(req.ToTicker)
+	if err != nil {
+		return err
+	}
+
+	m.rates[exchangePair{fromTickerID: from.TickerID, toTickerID: to.TickerID}] = models.ExchangeRate{
+		FromTicker: from.Name,
+		ToTicker:   to.Name,
+		Rate:       req.Rate,
+		UpdatedAt:  m.now(),
+	}
+
+	return nil
+}
+
+func (m *MemoryRepo) GetExchangeRates(ctx context.Context) (*models.ExchangeRatesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &models.ExchangeRatesResponse{Rates: make([]models.ExchangeRate, 0, len(m.rates))}
+	for _, rate := range m.rates {
+		resp.Rates = append(resp.Rates, rate)
+	}
+	sort.Slice(resp.Rates, func(i, j int) bool {
+		if resp.Rates[i].FromTicker != resp.Rates[j].FromTicker {
+			return resp.Rates[i].FromTicker < resp.Rates[j].FromTicker
+		}
+		return resp.Rates[i].ToTicker < resp.Rates[j].ToTicker
+	})
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) SetWithdrawalLimit(ctx context.Context, req *models.WithdrawalLimit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.wallets[req.WalletID]; req.WalletID != 0 && !ok {
+		return WalletDoesntExist(req.WalletID)
+	}
+
+	now := m.now()
+	limit := *req
+	limit.Ticker, limit.UpdatedAt = ticker.Name, &now
+	m.limits[balanceKey{walletID: req.WalletID, tickerID: ticker.TickerID}] = limit
+
+	return nil
+}
+
+// effectiveLimit возвращает ограничения кошелька по тикеру, а если их нет, то общие
+func (m *MemoryRepo) effectiveLimit(walletID, tickerID int) (models.WithdrawalLimit, bool) {
+	if limit, ok := m.limits[balanceKey{walletID: walletID, tickerID: tickerID}]; ok {
+		return limit, true
+	}
+	limit, ok := m.limits[balanceKey{tickerID: tickerID}]
+
+	return limit, ok
+}
+
+func (m *MemoryRepo) ListWithdrawalLimits(ctx context.Context, req *models.WithdrawalLimitsRequest) (*models.WithdrawalLimitsResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &models.WithdrawalLimitsResponse{Limits: make([]models.WithdrawalLimit, 0)}
+	if req.WalletID == 0 {
+		for _, limit := range m.limits {
+			resp.Limits = append(resp.Limits, limit)
+		}
+		sort.Slice(resp.Limits, func(i, j int) bool {
+			if resp.Limits[i].WalletID != resp.Limits[j].WalletID {
+				return resp.Limits[i].WalletID < resp.Limits[j].WalletID
+			}
+			return resp.Limits[i].Ticker < resp.Limits[j].Ticker
+		})
+
+		return resp, nil
+	}
+
+	// действующие для кошелька ограничения по каждому тикеру в порядке id тикеров
+	for tickerID := 1; tickerID <= len(m.tickersByID); tickerID++ {
+		if limit, ok := m.effectiveLimit(req.WalletID, tickerID); ok {
+			resp.Limits = append(resp.Limits, limit)
+		}
+	}
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) DeleteWithdrawalLimit(ctx context.Context, req *models.DeleteWithdrawalLimitRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return err
+	}
+
+	key := balanceKey{walletID: req.WalletID, tickerID: ticker.TickerID}
+	if _, ok := m.limits[key]; !ok {
+		return WithdrawalLimitDoesntExist(req.WalletID, req.Ticker)
+	}
+	delete(m.limits, key)
+
+	return nil
+}
+
+// checkWithdrawalLimits проверяет списание amount по тем же правилам, что и PostgresRepo.checkWithdrawalLimits:
+// суммы за сутки и месяц считаются по подтверждённым и замороженным списаниям с начала календарных суток и месяца
+func (m *MemoryRepo) checkWithdrawalLimits(walletID int, ticker *models.Ticker, amount models.Money, now time.Time) error {
+	limit, ok := m.effectiveLimit(walletID, ticker.TickerID)
+	if !ok {
+		return nil
+	}
+
+	if limit.MaxSingle != nil && amount.Cmp(*limit.MaxSingle) > 0 {
+		return WithdrawalLimitExceeded(walletID, ticker.Name, "single", *limit.MaxSingle)
+	}
+	if limit.Daily == nil && limit.Monthly == nil {
+		return nil
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var daily, monthly models.Money
+	for _, t := range m.transactions {
+		if t.WalletID != walletID || t.TickerID != ticker.TickerID || t.Operation != models.OperationWithdraw ||
+			t.Status == models.TransactionStatusError || t.CreatedAt.Before(monthStart) {
+			continue
+		}
+		// суммы списаний хранятся с минусом
+		monthly = monthly.Sub(t.Amount)
+		if !t.CreatedAt.Before(dayStart) {
+			daily = daily.Sub(t.Amount)
+		}
+	}
+
+	if limit.Daily != nil && daily.Add(amount).Cmp(*limit.Daily) > 0 {
+		return WithdrawalLimitExceeded(walletID, ticker.Name, "daily", *limit.Daily)
+	}
+	if limit.Monthly != nil && monthly.Add(amount).Cmp(*limit.Monthly) > 0 {
+		return WithdrawalLimitExceeded(walletID, ticker.Name, "monthly", *limit.Monthly)
+	}
+
+	return nil
+}
+
+func (m *MemoryRepo) SetFee(ctx context.Context, req *models.FeeRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return err
+	}
+	for _, value := range []*models.Money{&req.Fixed, req.Min, req.Max} {
+		if value == nil {
+			continue
+		}
+		if err := checkAmountScale(*value, ticker); err != nil {
+			return err
+		}
+	}
+
+	now := m.now()
+	fee := *req
+	fee.Ticker, fee.UpdatedAt = ticker.Name, &now
+	m.fees[feeKey{operation: req.Operation, tickerID: ticker.TickerID}] = fee
+
+	return nil
+}
+
+func (m *MemoryRepo) ListFees(ctx context.Context) (*models.FeesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &models.FeesResponse{Fees: make([]models.FeeRule, 0, len(m.fees))}
+	for _, fee := range m.fees {
+		resp.Fees = append(resp.Fees, fee)
+	}
+	sort.Slice(resp.Fees, func(i, j int) bool {
+		if resp.Fees[i].Operation != resp.Fees[j].Operation {
+			return resp.Fees[i].Operation < resp.Fees[j].Operation
+		}
+		return resp.Fees[i].Ticker < resp.Fees[j].Ticker
+	})
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) DeleteFee(ctx context.Context, req *models.DeleteFeeRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker, err := m.getTickerByName(req.Ticker)
+	if err != nil {
+		return err
+	}
+
+	key := feeKey{operation: req.Operation, tickerID: ticker.TickerID}
+	if _, ok := m.fees[key]; !ok {
+		return FeeDoesntExist(req.Operation, req.Ticker)
+	}
+	delete(m.fees, key)
+
+	return nil
+}
+
+// calculateFee считает комиссию за операцию по сохранённому правилу. Если правила нет, то комиссия нулевая.
+// В PostgresRepo зачисление на несуществующий кошелёк комиссий отклоняет внешний ключ, здесь он проверяется явно.
+func (m *MemoryRepo) calculateFee(operation string, ticker *models.Ticker, amount models.Money) (models.Money, error) {
+	rule, ok := m.fees[feeKey{operation: operation, tickerID: ticker.TickerID}]
+	if !ok {
+		return models.Money{}, nil
+	}
+
+	fee := rule.Calculate(amount, ticker.Scale)
+	if fee.IsPositive() {
+		if m.feeWalletID == 0 {
+			return models.Money{}, ErrFeeWalletNotConfigured
+		}
+		if _, ok := m.wallets[m.feeWalletID]; !ok {
+			return models.Money{}, fmt.Errorf("fee wallet %d doesn't exist", m.feeWalletID)
+		}
+	}
+
+	return fee, nil
+}
+
+func (m *MemoryRepo) CreateTicker(ctx context.Context, req *models.CreateTickerRequest) (*models.Ticker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tickers[req.Name]; ok {
+		return nil, TickerAlreadyExists(req.Name)
+	}
+
+	now := m.now()
+	ticker := &models.Ticker{
+		TickerID:    len(m.tickersByID) + 1,
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Scale:       req.Scale,
+		MinAmount:   req.MinAmount,
+		MaxAmount:   req.MaxAmount,
+		Enabled:     true,
+		UpdatedAt:   &now,
+	}
+	m.tickers[ticker.Name], m.tickersByID[ticker.TickerID] = ticker, ticker
+	copied := *ticker
+
+	return &copied, nil
+}
+
+func (m *MemoryRepo) UpdateTicker(ctx context.Context, req *models.UpdateTickerRequest) (*models.Ticker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker, err := m.getTickerByName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, amount := range []*models.Money{req.MinAmount, req.MaxAmount} {
+		if amount != nil {
+			if err := checkAmountScale(*amount, ticker); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	now := m.now()
+	ticker.DisplayName, ticker.MinAmount, ticker.MaxAmount, ticker.UpdatedAt = req.DisplayName, req.MinAmount, req.MaxAmount, &now
+	copied := *ticker
+
+	return &copied, nil
+}
+
+func (m *MemoryRepo) SetTickerStatus(ctx context.Context, req *models.SetTickerStatusRequest) (*models.Ticker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker, err := m.getTickerByName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	ticker.Enabled, ticker.UpdatedAt = req.Enabled, &now
+	copied := *ticker
+
+	return &copied, nil
+}
+
+func (m *MemoryRepo) ListTickers(ctx context.Context) (*models.TickersResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &models.TickersResponse{Tickers: make([]models.Ticker, 0, len(m.tickers))}
+	for _, ticker := range m.tickers {
+		resp.Tickers = append(resp.Tickers, *ticker)
+	}
+	sort.Slice(resp.Tickers, func(i, j int) bool {
+		return resp.Tickers[i].Name < resp.Tickers[j].Name
+	})
+
+	return resp, nil
+}
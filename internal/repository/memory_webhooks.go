@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// memoryWebhookDelivery строка таблицы webhook_deliveries вместе с блокировкой отправки
+type memoryWebhookDelivery struct {
+	models.WebhookDelivery
+	lockedUntil time.Time
+}
+
+func (m *MemoryRepo) getWebhook(id int) (*models.Webhook, error) {
+	if id <= 0 || id > len(m.webhooks) || m.webhooks[id-1] == nil {
+		return nil, WebhookDoesntExist(id)
+	}
+
+	return m.webhooks[id-1], nil
+}
+
+// createWebhookDeliveries создаёт доставки события event на все адреса его кошелька и общие адреса
+func (m *MemoryRepo) createWebhookDeliveries(event *models.TransactionEvent, at time.Time) {
+	payload, _ := json.Marshal(event)
+	for _, webhook := range m.webhooks {
+		if webhook == nil || (webhook.WalletID != 0 && webhook.WalletID != event.WalletID) {
+			continue
+		}
+		next := at
+		m.deliveries = append(m.deliveries, &memoryWebhookDelivery{WebhookDelivery: models.WebhookDelivery{
+			DeliveryID:    len(m.deliveries) + 1,
+			WebhookID:     webhook.WebhookID,
+			EventID:       event.EventID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &next,
+			CreatedAt:     at,
+		}})
+	}
+}
+
+func (m *MemoryRepo) CreateWebhook(ctx context.Context, req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.wallets[req.WalletID]; req.WalletID != 0 && !ok {
+		return nil, WalletDoesntExist(req.WalletID)
+	}
+
+	webhook := &models.Webhook{
+		WebhookID: len(m.webhooks) + 1,
+		WalletID:  req.WalletID,
+		URL:       req.URL,
+		Secret:    req.Secret,
+		CreatedAt: m.now(),
+	}
+	if webhook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		webhook.Secret = secret
+	}
+	m.webhooks = append(m.webhooks, webhook)
+	copied := *webhook
+
+	return &copied, nil
+}
+
+func (m *MemoryRepo) ListWebhooks(ctx context.Context, req *models.WebhooksRequest) (*models.WebhooksResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &models.WebhooksResponse{Webhooks: make([]models.Webhook, 0)}
+	for _, webhook := range m.webhooks {
+		if webhook == nil || (req.WalletID != 0 && webhook.WalletID != req.WalletID) {
+			continue
+		}
+		copied := *webhook
+		copied.Secret = ""
+		resp.Webhooks = append(resp.Webhooks, copied)
+	}
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) DeleteWebhook(ctx context.Context, req *models.DeleteWebhookRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.getWebhook(req.WebhookID); err != nil {
+		return err
+	}
+	m.webhooks[req.WebhookID-1] = nil
+
+	return nil
+}
+
+func (m *MemoryRepo) ListWebhookDeliveries(ctx context.Context, req *models.WebhookDeliveriesRequest) (*models.WebhookDeliveriesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.getWebhook(req.WebhookID); err != nil {
+		return nil, err
+	}
+
+	resp := &models.WebhookDeliveriesResponse{Deliveries: make([]models.WebhookDelivery, 0)}
+	for i := len(m.deliveries) - 1; i >= 0 && len(resp.Deliveries) < req.LimitOrDefault(); i-- {
+		if m.deliveries[i].WebhookID == req.WebhookID {
+			resp.Deliveries = append(resp.Deliveries, m.deliveries[i].WebhookDelivery)
+		}
+	}
+
+	return resp, nil
+}
+
+func (m *MemoryRepo) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	due := make([]*memoryWebhookDelivery, 0)
+	for _, delivery := range m.deliveries {
+		// доставки удалённых адресов удаляются вместе с ними, как по ON DELETE CASCADE в PostgresRepo
+		if m.webhooks[delivery.WebhookID-1] == nil {
+			continue
+		}
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) && delivery.lockedUntil.Before(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt)
+	})
+
+	deliveries := make([]models.WebhookDelivery, 0, min(len(due), limit))
+	for _, delivery := range due[:min(len(due), limit)] {
+		delivery.lockedUntil = now.Add(lease)
+		claimed := delivery.WebhookDelivery
+		webhook := m.webhooks[delivery.WebhookID-1]
+		claimed.URL, claimed.Secret = webhook.URL, webhook.Secret
+		deliveries = append(deliveries, claimed)
+	}
+
+	return deliveries, nil
+}
+
+func (m *MemoryRepo) CompleteWebhookAttempt(ctx context.Context, result *models.WebhookAttemptResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if result.DeliveryID <= 0 || result.DeliveryID > len(m.deliveries) {
+		return nil
+	}
+	delivery := m.deliveries[result.DeliveryID-1]
+	if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != result.Attempt-1 ||
+		m.webhooks[delivery.WebhookID-1] == nil {
+		return nil
+	}
+
+	now := m.now()
+	status, next := webhookAttemptOutcome(result, now)
+	delivery.Attempts, delivery.Status, delivery.NextAttemptAt = result.Attempt, status, nil
+	if !next.IsZero() {
+		delivery.NextAttemptAt = &next
+	}
+	delivery.lockedUntil = time.Time{}
+	delivery.LastAttemptAt, delivery.LastStatusCode, delivery.LastError = &now, result.StatusCode, result.Error
+
+	return nil
+}
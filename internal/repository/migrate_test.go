@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"bwg_transactional_system/migrations"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	loaded, err := loadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(loaded) == 0 {
+		t.Fatalf("no embedded migrations")
+	}
+	for i, migration := range loaded {
+		if i > 0 && migration.Version <= loaded[i-1].Version {
+			t.Errorf("migration %d_%s is not sorted after %d", migration.Version, migration.Name, loaded[i-1].Version)
+		}
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			t.Errorf("migration %d_%s has an empty up or down file", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1;")}
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		err  string
+	}{
+		{name: "missing down", fsys: fstest.MapFS{"1_a.up.sql": file}, err: "must have both up and down files"},
+		{name: "no direction", fsys: fstest.MapFS{"1_a.sql": file}, err: "must end with .up.sql or .down.sql"},
+		{name: "no version", fsys: fstest.MapFS{"a.up.sql": file, "a.down.sql": file}, err: "must start with a numeric version"},
+		{name: "same version", fsys: fstest.MapFS{"1_a.up.sql": file, "1_a.down.sql": file, "1_b.up.sql": file},
+			err: "have the same version 1"},
+	}
+
+	for _, tt := range tests {
+		if _, err := loadMigrations(tt.fsys); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: loadMigrations error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// newTestSchemaDB подключается к тестовой бд с отдельной схемой по умолчанию, чтобы миграции теста
+// не затрагивали таблицы приложения. Схема удаляется после теста.
+func newTestSchemaDB(t *testing.T) *sql.DB {
+	t.Helper()
+	cfg := testConfig(t)
+	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.Username, cfg.DBName, cfg.Password, cfg.SSLMode)
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+// tableExists проверяет есть ли таблица в схеме по умолчанию
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	t.Helper()
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		t.Fatalf("to_regclass(%s): %v", table, err)
+	}
+
+	return exists
+}
+
+// migrationVersions возвращает версии миграций через запятую
+func migrationVersions(done []Migration) string {
+	versions := make([]string, 0, len(done))
+	for _, migration := range done {
+		versions = append(versions, fmt.Sprint(migration.Version))
+	}
+
+	return strings.Join(versions, ",")
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := newTestSchemaDB(t)
+	ctx := context.Background()
+	migrator, err := NewMigrator(db, fstest.MapFS{
+		"1_first.up.sql":    {Data: []byte("CREATE TABLE first (id int);")},
+		"1_first.down.sql":  {Data: []byte("DROP TABLE first;")},
+		"2_second.up.sql":   {Data: []byte("CREATE TABLE second (id int);")},
+		"2_second.down.sql": {Data: []byte("DROP TABLE second;")},
+	})
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil || migrationVersions(applied) != "1,2" {
+		t.Fatalf("Up = %s, %v, want 1,2", migrationVersions(applied), err)
+	}
+	if !tableExists(t, db, "first") || !tableExists(t, db, "second") {
+		t.Fatalf("tables are missing after Up")
+	}
+
+	// повторный запуск ничего не применяет
+	applied, err = migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("repeated Up = %s, %v, want nothing", migrationVersions(applied), err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Fatalf("migration %d is not applied", status.Version)
+		}
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil || migrationVersions(reverted) != "2" {
+		t.Fatalf("Down(1) = %s, %v, want 2", migrationVersions(reverted), err)
+	}
+	if !tableExists(t, db, "first") || tableExists(t, db, "second") {
+		t.Fatalf("Down(1) must drop only the second table")
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil || migrationVersions(applied) != "2" {
+		t.Fatalf("Up after Down = %s, %v, want 2", migrationVersions(applied), err)
+	}
+
+	reverted, err = migrator.Down(ctx, 10)
+	if err != nil || migrationVersions(reverted) != "2,1" {
+		t.Fatalf("Down(10) = %s, %v, want 2,1", migrationVersions(reverted), err)
+	}
+	if tableExists(t, db, "first") || tableExists(t, db, "second") {
+		t.Fatalf("tables remain after reverting all migrations")
+	}
+}
+
+func TestMigratorFailedMigration(t *testing.T) {
+	db := newTestSchemaDB(t)
+	ctx := context.Background()
+	migrator, err := NewMigrator(db, fstest.MapFS{
+		"1_first.up.sql":    {Data: []byte("CREATE TABLE first (id int);")},
+		"1_first.down.sql":  {Data: []byte("DROP TABLE first;")},
+		"2_broken.up.sql":   {Data: []byte("CREATE TABLE broken (id int); SELECT missing_column FROM first;")},
+		"2_broken.down.sql": {Data: []byte("DROP TABLE broken;")},
+	})
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	// миграция выполняется в одной транзакции с записью версии, поэтому неудачная миграция не оставляет следов
+	if _, err := migrator.Up(ctx); err == nil {
+		t.Fatalf("Up succeeded with a broken migration")
+	}
+	if !tableExists(t, db, "first") || tableExists(t, db, "broken") {
+		t.Fatalf("broken migration must be rolled back and the previous one kept")
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Fatalf("statuses = %+v, want only the first migration applied", statuses)
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"time"
+)
+
+// archiveQuery переносит до $4 завершённых транзакций, которые не менялись с момента $1, из transactions
+// в transactions_archive и возвращает количество перенесённых транзакций
+const archiveQuery = `
+WITH moved AS (
+    DELETE FROM transactions
+        WHERE (id, created_at) IN (SELECT id, created_at
+                                   FROM transactions
+                                   WHERE status IN ($2, $3)
+                                     AND updated_at < $1
+                                   ORDER BY id
+                                   LIMIT $4 FOR UPDATE SKIP LOCKED)
+        RETURNING id, wallet_id, ticker_id, amount, status, created_at, updated_at, linked_transaction_id, operation),
+     archived AS (
+         INSERT INTO transactions_archive (id, wallet_id, ticker_id, amount, status, created_at, updated_at,
+                                           linked_transaction_id, operation)
+             SELECT * FROM moved
+             RETURNING 1)
+SELECT COUNT(*)
+FROM archived`
+
+/*
+ArchiveTransactions переносит до limit транзакций в финальном статусе, которые не менялись с момента before,
+в таблицу transactions_archive. Перенос выполняется одним запросом, поэтому транзакция в любой момент находится
+ровно в одной из таблиц, а история, сверка и балансы на момент в прошлом читают обе через all_transactions.
+
+Замороженные списания не архивируются, пока не будут подтверждены или отменены.
+*/
+func (p *PostgresRepo) ArchiveTransactions(ctx context.Context, before time.Time, limit int) (int, error) {
+	var archived int
+	if err := p.db.QueryRowContext(ctx, archiveQuery, before, models.TransactionStatusSuccess,
+		models.TransactionStatusError, limit).Scan(&archived); err != nil {
+		return 0, err
+	}
+
+	return archived, nil
+}
+
+/*
+MaintainTransactionPartitions готовит разделы transactions и удаляет ненужные.
+
+1) Создаём разделы текущего и следующего месяца, чтобы новые транзакции не попадали в раздел по умолчанию
+
+2) Удаляем разделы месяцев, закончившихся до before, из которых все транзакции уже перенесены в архив,
+и возвращаем количество удалённых разделов
+*/
+func (p *PostgresRepo) MaintainTransactionPartitions(ctx context.Context, before time.Time) (int, error) {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, m := range []time.Time{month, month.AddDate(0, 1, 0)} {
+		if _, err := p.db.ExecContext(ctx, "SELECT create_transactions_partition($1::date)", m.Format(time.DateOnly)); err != nil {
+			return 0, err
+		}
+	}
+
+	var dropped int
+	if err := p.db.QueryRowContext(ctx, "SELECT drop_empty_transactions_partitions($1)", before).Scan(&dropped); err != nil {
+		return 0, err
+	}
+
+	return dropped, nil
+}
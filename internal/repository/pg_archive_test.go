@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// ageTransactions делает завершённые транзакции кошелька старше порога архивации и возвращает этот порог.
+// В PostgresRepo время изменения транзакций сдвигается в прошлое, чтобы не архивировать транзакции других тестов.
+func (r *testRepo) ageTransactions(t *testing.T, walletID int) time.Time {
+	t.Helper()
+	if r.pg == nil {
+		return time.Now().Add(time.Hour)
+	}
+
+	if _, err := r.pg.db.Exec(
+		"UPDATE transactions SET updated_at = updated_at - interval '400 days' WHERE wallet_id = $1", walletID); err != nil {
+		t.Fatalf("age transactions: %v", err)
+	}
+
+	return time.Now().Add(-399 * 24 * time.Hour)
+}
+
+// archiveAll переносит в архив все транзакции, завершённые до before
+func (r *testRepo) archiveAll(t *testing.T, before time.Time) {
+	t.Helper()
+	for {
+		archived, err := r.ArchiveTransactions(context.Background(), before, 100)
+		if err != nil {
+			t.Fatalf("ArchiveTransactions: %v", err)
+		}
+		if archived < 100 {
+			return
+		}
+	}
+}
+
+// history возвращает id и статусы всех транзакций кошелька
+func (r *testRepo) history(t *testing.T, walletID int) string {
+	t.Helper()
+	resp, err := r.ListTransactions(context.Background(), &models.HistoryRequest{WalletID: walletID, Limit: models.MaxHistoryLimit})
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+
+	history := ""
+	for _, transaction := range resp.Transactions {
+		history += fmt.Sprintf("%d:%d ", transaction.ID, transaction.Status)
+	}
+
+	return history
+}
+
+func TestArchiveTransactions(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		ticker := r.ticker(t, "ARC")
+		walletID := r.wallet(t)
+		invoiceID := r.invoice(t, walletID, ticker, "10")
+		r.invoice(t, walletID, ticker, "5")
+		if _, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("3")}); err != nil {
+			t.Fatalf("WithDraw: %v", err)
+		}
+		history := r.history(t, walletID)
+
+		r.archiveAll(t, r.ageTransactions(t, walletID))
+
+		// история читает и архив, а балансы не меняются
+		if got := r.history(t, walletID); got != history {
+			t.Fatalf("history after archiving = %s, want %s", got, history)
+		}
+		if actual, frozen := r.balance(t, walletID, ticker); actual != "12.00" || frozen != "3.00" {
+			t.Fatalf("balance after archiving = %s/%s, want 12.00/3.00", actual, frozen)
+		}
+		_, err := r.Reverse(ctx, &models.ReverseRequest{TransactionID: invoiceID})
+		checkErrorCode(t, "Reverse of an archived transaction", err, ErrCodeNotReversible)
+
+		if r.pg == nil {
+			return
+		}
+		// завершённые транзакции перенесены в архив, замороженное списание осталось
+		var active, archived int
+		if err := r.pg.db.QueryRow(`SELECT (SELECT COUNT(*) FROM transactions WHERE wallet_id = $1),
+       (SELECT COUNT(*) FROM transactions_archive WHERE wallet_id = $1)`, walletID).Scan(&active, &archived); err != nil {
+			t.Fatalf("count transactions: %v", err)
+		}
+		if active != 1 || archived != 2 {
+			t.Fatalf("active/archived transactions = %d/%d, want 1/2", active, archived)
+		}
+	})
+}
+
+func TestMaintainTransactionPartitions(t *testing.T) {
+	p := newTestPostgresRepo(t)
+	ctx := context.Background()
+	r := &testRepo{Repository: p, pg: p, feeWalletID: p.feeWalletID, suffix: fmt.Sprint(time.Now().UnixNano() % 1e9)}
+	ticker := r.ticker(t, "PRT")
+	walletID := r.wallet(t)
+	r.invoice(t, walletID, ticker, "10")
+	history := r.history(t, walletID)
+
+	partitionExists := func(name string) bool {
+		var exists bool
+		if err := p.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", name).Scan(&exists); err != nil {
+			t.Fatalf("to_regclass(%s): %v", name, err)
+		}
+		return exists
+	}
+
+	// переносим транзакцию в раздел давно прошедшего месяца
+	const old = "transactions_p200001"
+	if _, err := p.db.Exec("SELECT create_transactions_partition('2000-01-01')"); err != nil {
+		t.Fatalf("create_transactions_partition: %v", err)
+	}
+	if _, err := p.db.Exec("UPDATE transactions SET created_at = '2000-01-15', updated_at = '2000-01-15' WHERE wallet_id = $1",
+		walletID); err != nil {
+		t.Fatalf("move transaction: %v", err)
+	}
+	before := time.Date(2000, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// раздел с транзакциями не удаляется, а разделы текущего и следующего месяца создаются
+	if _, err := p.MaintainTransactionPartitions(ctx, before); err != nil {
+		t.Fatalf("MaintainTransactionPartitions: %v", err)
+	}
+	if !partitionExists(old) {
+		t.Fatal("partition with transactions was dropped")
+	}
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, m := range []time.Time{month, month.AddDate(0, 1, 0)} {
+		if name := "transactions_p" + m.Format("200601"); !partitionExists(name) {
+			t.Fatalf("partition %s wasn't created", name)
+		}
+	}
+
+	// после архивации раздел пустой и удаляется, а транзакция остаётся в истории
+	r.archiveAll(t, before)
+	dropped, err := p.MaintainTransactionPartitions(ctx, before)
+	if err != nil {
+		t.Fatalf("MaintainTransactionPartitions: %v", err)
+	}
+	if dropped < 1 || partitionExists(old) {
+		t.Fatalf("dropped %d partitions, %s exists: %v", dropped, old, partitionExists(old))
+	}
+	if got := r.history(t, walletID); got != history {
+		t.Fatalf("history after dropping the partition = %s, want %s", got, history)
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RecordAudit добавляет запись в журнал аудита. Таблица audit_log защищена триггером от изменения и удаления записей.
+func (p *PostgresRepo) RecordAudit(ctx context.Context, record *models.AuditRecord) error {
+	return p.db.QueryRowContext(ctx, `
+INSERT INTO audit_log (app_id, user_id, message_id, correlation_id, routing_key, redelivered, body_sha256, code,
+                       error_code, transaction_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, created_at`,
+		record.AppID, record.UserID, record.MessageID, record.CorrelationID, record.RoutingKey, record.Redelivered,
+		record.BodySHA256, record.Code, record.ErrorCode, nullableID(record.TransactionID),
+	).Scan(&record.ID, &record.CreatedAt)
+}
+
+/*
+1) Собираем условия выборки из фильтров запроса, курсор задаёт id записи, после которой начинается страница
+
+2) Получаем на одну запись больше размера страницы, чтобы понять есть ли следующая страница
+
+3) Курсором следующей страницы становится id последней записи на странице
+*/
+func (p *PostgresRepo) ListAuditLog(ctx context.Context, req *models.AuditLogRequest) (*models.AuditLogResponse, error) {
+	cursorID, err := req.CursorID()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"true"}
+	args := []any{}
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if req.AppID != "" {
+		addCondition("app_id = $%d", req.AppID)
+	}
+	if req.UserID != "" {
+		addCondition("user_id = $%d", req.UserID)
+	}
+	if req.RoutingKey != "" {
+		addCondition("routing_key = $%d", req.RoutingKey)
+	}
+	if req.TransactionID != 0 {
+		addCondition("transaction_id = $%d", req.TransactionID)
+	}
+	if req.From != nil {
+		addCondition("created_at >= $%d", *req.From)
+	}
+	if req.To != nil {
+		addCondition("created_at < $%d", *req.To)
+	}
+	if cursorID != 0 {
+		addCondition("id < $%d", cursorID)
+	}
+
+	limit := req.PageSize()
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT id, app_id, user_id, message_id, correlation_id, routing_key, redelivered, body_sha256,
+       code, error_code, COALESCE(transaction_id, 0), created_at
+FROM audit_log
+WHERE %s
+ORDER BY id DESC
+LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.AuditLogResponse{Records: make([]models.AuditRecord, 0, limit)}
+	for rows.Next() {
+		var r models.AuditRecord
+		if err := rows.Scan(&r.ID, &r.AppID, &r.UserID, &r.MessageID, &r.CorrelationID, &r.RoutingKey, &r.Redelivered,
+			&r.BodySHA256, &r.Code, &r.ErrorCode, &r.TransactionID, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		resp.Records = append(resp.Records, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over audit log rows: %s", err)
+	}
+
+	// если получили больше записей чем размер страницы, то есть следующая страница
+	if len(resp.Records) > limit {
+		resp.Records = resp.Records[:limit]
+		resp.NextCursor = models.EncodeCursor(resp.Records[limit-1].ID)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+/*
+1) Открываем транзакцию и заранее блокируем балансы всех операций пакета в порядке (wallet_id, ticker_id),
+чтобы пакеты с общими кошельками не блокировали друг друга во встречном порядке
+
+2) Выполняем операции по порядку теми же шагами, что и отдельные invoice, withdraw, transfer и exchange.
+При первой ошибке транзакция откатывается целиком, запись о неудачном списании не сохраняется,
+так как ни одна операция пакета не применена
+
+3) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
+*/
+func (p *PostgresRepo) batch(ctx context.Context, req *models.BatchRequest) (*models.BatchResponse, error) {
+	resp := &models.BatchResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpBatch, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.lockBatchBalances(ctx, tx, req.Legs); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	resp.Results = make([]models.BatchLegResult, 0, len(req.Legs))
+	for i := range req.Legs {
+		leg := &req.Legs[i]
+		result := models.BatchLegResult{Operation: leg.Operation}
+		switch leg.Operation {
+		case models.OperationInvoice:
+			result.OperationResponse, err = p.applyInvoice(ctx, tx, leg.InvoiceRequest())
+		case models.OperationWithdraw:
+			result.OperationResponse, err = p.applyWithdraw(ctx, tx, leg.WithdrawRequest())
+		case models.OperationTransfer:
+			result.TransferResponse, err = p.applyTransfer(ctx, tx, leg.TransferRequest())
+		case models.OperationExchange:
+			var exchange *models.ExchangeResponse
+			if exchange, err = p.applyExchange(ctx, tx, leg.ExchangeRequest()); err == nil {
+				result.SetExchange(exchange)
+			}
+		default:
+			err = models.ValidationBatchOperationError
+		}
+		if err != nil {
+			return nil, rollbackTx(tx, batchLegError(i, err))
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpBatch, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpBatch, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Batch выполняет операции пакета в одной транзакции, при ошибке одной из них не применяется ни одна
+func (p *PostgresRepo) Batch(ctx context.Context, req *models.BatchRequest) (*models.BatchResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.BatchResponse, error) {
+		return p.batch(ctx, req)
+	})
+	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpBatch)
+	}
+
+	return resp, nil
+}
+
+// lockBatchBalances блокирует балансы всех кошельков пакета, для обмена по обоим тикерам.
+// Тикеры, которых не существует, пропускаются, ошибку по ним вернёт сама операция.
+func (p *PostgresRepo) lockBatchBalances(ctx context.Context, tx *sql.Tx, legs []models.BatchLeg) error {
+	seen := make(map[balanceKey]bool)
+	keys := make([]balanceKey, 0, len(legs))
+	for _, leg := range legs {
+		for _, name := range []string{leg.Ticker, leg.ToTicker} {
+			if name == "" {
+				continue
+			}
+			ticker, err := p.getTickerByName(ctx, name)
+			if err != nil {
+				var logicErr LogicErrors
+				if errors.As(err, &logicErr) {
+					continue
+				}
+				return err
+			}
+			for _, walletID := range []int{leg.WalletID, leg.ToWalletID} {
+				key := balanceKey{walletID: walletID, tickerID: ticker.TickerID}
+				if walletID == 0 || seen[key] {
+					continue
+				}
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	_, err := p.lockBalances(ctx, tx, keys...)
+	return err
+}
+
+// batchLegError добавляет к ошибке номер операции пакета. Ошибки бизнес-логики остаются ошибками бизнес-логики,
+// остальные оборачиваются так, чтобы по ним по-прежнему работали повторы.
+func batchLegError(leg int, err error) error {
+	var logicErr LogicErrors
+	if errors.As(err, &logicErr) {
+		return BatchLegFailed(leg, logicErr)
+	}
+
+	return fmt.Errorf("batch leg %d: %w", leg, err)
+}
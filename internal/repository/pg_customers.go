@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// customerBalanceQuery то же что и balanceQuery, но балансы и замороженные списания складываются
+// по всем кошелькам клиента $1
+const customerBalanceQuery = `
+SELECT t.name, t.scale, b.amount, f.amount
+FROM (SELECT ticker_id, SUM(amount) AS amount
+      FROM current_balances
+      WHERE wallet_id IN (SELECT wallet_id FROM wallets WHERE customer_id = $1)
+      GROUP BY ticker_id) b
+         FULL JOIN (SELECT ticker_id, -SUM(amount) AS amount
+                    FROM transactions
+                    WHERE wallet_id IN (SELECT wallet_id FROM wallets WHERE customer_id = $1)
+                      AND status = $2
+                    GROUP BY ticker_id) f ON f.ticker_id = b.ticker_id
+         JOIN tickers t ON t.ticker_id = COALESCE(b.ticker_id, f.ticker_id)`
+
+// nullableString возвращает NULL для пустой строки
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func (p *PostgresRepo) CreateCustomer(ctx context.Context, req *models.CreateCustomerRequest) (*models.Customer, error) {
+	customer := &models.Customer{ExternalID: req.ExternalID, Name: req.Name, WalletIDs: make([]int, 0)}
+	if err := p.db.QueryRowContext(ctx,
+		"INSERT INTO customers (external_id, name) VALUES ($1, $2) RETURNING customer_id, created_at",
+		nullableString(req.ExternalID), req.Name).Scan(&customer.CustomerID, &customer.CreatedAt); err != nil {
+		if isUniqueViolation(err) {
+			return nil, CustomerAlreadyExists(req.ExternalID)
+		}
+
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+// queryer общий интерфейс *sql.DB и *sql.Tx для запросов, которые выполняются как внутри транзакции, так и без неё
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// getCustomer читает клиента вместе со списком id его кошельков
+func getCustomer(ctx context.Context, q queryer, customerID int) (*models.Customer, error) {
+	customer := &models.Customer{CustomerID: customerID, WalletIDs: make([]int, 0)}
+	var externalID sql.NullString
+	if err := q.QueryRowContext(ctx,
+		"SELECT external_id, name, created_at FROM customers WHERE customer_id = $1", customerID).Scan(
+		&externalID, &customer.Name, &customer.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, CustomerDoesntExist(customerID)
+		}
+
+		return nil, err
+	}
+	customer.ExternalID = externalID.String
+
+	rows, err := q.QueryContext(ctx, "SELECT wallet_id FROM wallets WHERE customer_id = $1 ORDER BY wallet_id", customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var walletID int
+		if err := rows.Scan(&walletID); err != nil {
+			return nil, err
+		}
+		customer.WalletIDs = append(customer.WalletIDs, walletID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over wallet rows: %s", err)
+	}
+
+	return customer, nil
+}
+
+func (p *PostgresRepo) GetCustomer(ctx context.Context, req *models.GetCustomerRequest) (*models.Customer, error) {
+	return getCustomer(ctx, p.db, req.CustomerID)
+}
+
+/*
+1) Открываем транзакцию и проверяем что клиент существует
+
+2) Блокируем строку кошелька и проверяем что он существует, не закрыт и не принадлежит другому клиенту
+
+3) Привязываем кошелёк к клиенту и возвращаем клиента с обновлённым списком кошельков
+*/
+func (p *PostgresRepo) attachWallet(ctx context.Context, req *models.AttachWalletRequest) (*models.Customer, error) {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM customers WHERE customer_id = $1)", req.CustomerID).Scan(&exists); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	if !exists {
+		return nil, rollbackTx(tx, CustomerDoesntExist(req.CustomerID))
+	}
+
+	var status int
+	var customerID sql.NullInt64
+	if err := tx.QueryRowContext(ctx,
+		"SELECT status, customer_id FROM wallets WHERE wallet_id = $1 FOR UPDATE", req.WalletID).Scan(&status, &customerID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, rollbackTx(tx, WalletDoesntExist(req.WalletID))
+		}
+
+		return nil, rollbackTx(tx, err)
+	}
+	if models.WalletStatus(status) == models.WalletStatusClosed {
+		return nil, rollbackTx(tx, WalletClosed(req.WalletID))
+	}
+	// кошелёк нельзя перепривязать к другому клиенту, иначе средства одного клиента перейдут другому
+	if customerID.Valid && int(customerID.Int64) != req.CustomerID {
+		return nil, rollbackTx(tx, WalletOwnedByOtherCustomer(req.WalletID))
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE wallets SET customer_id = $1 WHERE wallet_id = $2", req.CustomerID, req.WalletID); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	customer, err := getCustomer(ctx, tx, req.CustomerID)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+func (p *PostgresRepo) AttachWallet(ctx context.Context, req *models.AttachWalletRequest) (*models.Customer, error) {
+	return withRetry(ctx, p.retry, func() (*models.Customer, error) {
+		return p.attachWallet(ctx, req)
+	})
+}
+
+// GetCustomerBalance возвращает балансы, сложенные по всем кошелькам клиента.
+// Список кошельков и балансы читаются в одной транзакции, поэтому согласованы между собой.
+func (p *PostgresRepo) GetCustomerBalance(ctx context.Context, req *models.GetCustomerRequest) (*models.CustomerBalanceResponse, error) {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	customer, err := getCustomer(ctx, tx, req.CustomerID)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, customerBalanceQuery, req.CustomerID, models.TransactionStatusCreated)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	actual, frozen, err := scanBalances(rows)
+	rows.Close()
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &models.CustomerBalanceResponse{
+		CustomerID:    customer.CustomerID,
+		WalletIDs:     customer.WalletIDs,
+		ActualBalance: actual,
+		FrozenBalance: frozen,
+	}, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestCustomers(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		usd, eur := r.ticker(t, "CUS"), r.ticker(t, "CUE")
+		externalID := fmt.Sprintf("crm-%s-%d", r.suffix, testTickers.Add(1))
+
+		customer, err := r.CreateCustomer(ctx, &models.CreateCustomerRequest{ExternalID: externalID, Name: "Acme"})
+		if err != nil {
+			t.Fatalf("CreateCustomer: %v", err)
+		}
+		if len(customer.WalletIDs) != 0 {
+			t.Fatalf("new customer wallets = %v, want none", customer.WalletIDs)
+		}
+		_, err = r.CreateCustomer(ctx, &models.CreateCustomerRequest{ExternalID: externalID})
+		checkErrorCode(t, "CreateCustomer with the same external id", err, ErrCodeCustomerExists)
+		other, err := r.CreateCustomer(ctx, &models.CreateCustomerRequest{})
+		if err != nil {
+			t.Fatalf("CreateCustomer without external id: %v", err)
+		}
+		if _, err := r.CreateCustomer(ctx, &models.CreateCustomerRequest{}); err != nil {
+			t.Fatalf("second CreateCustomer without external id: %v", err)
+		}
+
+		// балансы кошельков клиента складываются по тикерам, чужие кошельки не учитываются
+		first, second, foreign := r.wallet(t), r.wallet(t), r.wallet(t)
+		r.invoice(t, first, usd, "10")
+		r.invoice(t, second, usd, "5.50")
+		r.invoice(t, second, eur, "3")
+		r.invoice(t, foreign, usd, "100")
+		if _, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: first, Ticker: usd, Amount: models.MustParseMoney("2")}); err != nil {
+			t.Fatalf("WithDraw: %v", err)
+		}
+		for _, walletID := range []int{first, second, second} {
+			if customer, err = r.AttachWallet(ctx, &models.AttachWalletRequest{CustomerID: customer.CustomerID, WalletID: walletID}); err != nil {
+				t.Fatalf("AttachWallet(%d): %v", walletID, err)
+			}
+		}
+		if _, err := r.AttachWallet(ctx, &models.AttachWalletRequest{CustomerID: other.CustomerID, WalletID: foreign}); err != nil {
+			t.Fatalf("AttachWallet(%d): %v", foreign, err)
+		}
+
+		customer, err = r.GetCustomer(ctx, &models.GetCustomerRequest{CustomerID: customer.CustomerID})
+		if err != nil {
+			t.Fatalf("GetCustomer: %v", err)
+		}
+		sort.Ints(customer.WalletIDs)
+		if fmt.Sprint(customer.WalletIDs) != fmt.Sprint([]int{first, second}) || customer.ExternalID != externalID || customer.Name != "Acme" {
+			t.Fatalf("customer = %+v, want Acme %s with wallets %d and %d", customer, externalID, first, second)
+		}
+
+		balance, err := r.GetCustomerBalance(ctx, &models.GetCustomerRequest{CustomerID: customer.CustomerID})
+		if err != nil {
+			t.Fatalf("GetCustomerBalance: %v", err)
+		}
+		if got := fmt.Sprintf("%s %s %s", balance.ActualBalance[usd].StringFixed(2), balance.ActualBalance[eur].StringFixed(2),
+			balance.FrozenBalance[usd].StringFixed(2)); got != "13.50 3.00 2.00" {
+			t.Fatalf("customer balance = %s, want 13.50 3.00 2.00", got)
+		}
+
+		_, err = r.AttachWallet(ctx, &models.AttachWalletRequest{CustomerID: other.CustomerID, WalletID: first})
+		checkErrorCode(t, "AttachWallet of another customer's wallet", err, ErrCodeWalletOwned)
+		_, err = r.AttachWallet(ctx, &models.AttachWalletRequest{CustomerID: customer.CustomerID, WalletID: 1 << 30})
+		checkErrorCode(t, "AttachWallet of unknown wallet", err, ErrCodeWalletNotFound)
+		_, err = r.AttachWallet(ctx, &models.AttachWalletRequest{CustomerID: 1 << 30, WalletID: first})
+		checkErrorCode(t, "AttachWallet to unknown customer", err, ErrCodeCustomerNotFound)
+		_, err = r.GetCustomerBalance(ctx, &models.GetCustomerRequest{CustomerID: 1 << 30})
+		checkErrorCode(t, "GetCustomerBalance of unknown customer", err, ErrCodeCustomerNotFound)
+
+		closed := r.wallet(t)
+		if _, err := r.SetWalletStatus(ctx, &models.SetWalletStatusRequest{WalletID: closed, Status: models.WalletStatusClosed}); err != nil {
+			t.Fatalf("SetWalletStatus(closed): %v", err)
+		}
+		_, err = r.AttachWallet(ctx, &models.AttachWalletRequest{CustomerID: customer.CustomerID, WalletID: closed})
+		checkErrorCode(t, "AttachWallet of closed wallet", err, ErrCodeWalletClosed)
+	})
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SetExchangeRate создаёт или обновляет курс обмена между двумя тикерами
+func (p *PostgresRepo) SetExchangeRate(ctx context.Context, req *models.ExchangeRate) error {
+	from, err := p.getTickerByName(ctx, req.FromTicker)
+	if err != nil {
+		return err
+	}
+	to, err := p.getTickerByName(ctx, req.ToTicker)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.db.ExecContext(ctx,
+		`INSERT INTO exchange_rates (from_ticker_id, to_ticker_id, rate, updated_at) VALUES ($1, $2, $3, now())
+ON CONFLICT (from_ticker_id, to_ticker_id) DO UPDATE SET rate = $3, updated_at = now()`,
+		from.TickerID, to.TickerID, req.Rate); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetExchangeRates возвращает все курсы обмена
+func (p *PostgresRepo) GetExchangeRates(ctx context.Context) (*models.ExchangeRatesResponse, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT f.name, t.name, r.rate, r.updated_at
+FROM exchange_rates r
+    JOIN tickers f ON f.ticker_id = r.from_ticker_id
+    JOIN tickers t ON t.ticker_id = r.to_ticker_id
+ORDER BY f.name, t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.ExchangeRatesResponse{Rates: make([]models.ExchangeRate, 0)}
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.FromTicker, &rate.ToTicker, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		resp.Rates = append(resp.Rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over exchange rate rows: %s", err)
+	}
+
+	return resp, nil
+}
+
+/*
+1) Проверяем что существуют и включены оба тикера из операции, а сумма допустима для тикера списания
+
+2) Открываем транзакцию, проверяем кошелёк и получаем курс обмена, зачисляемая сумма округляется до точности тикера зачисления
+и должна помещаться в колонки баланса
+
+ 3. Проверяем баланс на кошельке по тикеру списания. Если его недостаточно, то отменяем транзакцию,
+    создаём запись о неудачной транзакции по списанию средств и возвращаем ошибку
+
+4) Создаём связанные записи о списании и зачислении со статусом models.TransactionStatusCreated
+
+5) Изменяем балансы кошелька по обоим тикерам
+
+6) Меняем статусы транзакций на успешные
+
+7) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
+*/
+func (p *PostgresRepo) exchange(ctx context.Context, req *models.ExchangeRequest) (*models.ExchangeResponse, error) {
+	resp := &models.ExchangeResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpExchange, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = p.applyExchange(ctx, tx, req)
+	if err != nil {
+		return nil, p.rollbackOperation(ctx, tx, err, req.IdempotencyKey, idempotencyOpExchange)
+	}
+
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpExchange, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpExchange, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// applyExchange обменивает средства в открытой транзакции tx. При нехватке средств возвращает *insufficientFunds.
+func (p *PostgresRepo) applyExchange(ctx context.Context, tx *sql.Tx, req *models.ExchangeRequest) (*models.ExchangeResponse, error) {
+	resp := &models.ExchangeResponse{}
+	from, err := p.getTickerByName(ctx, req.FromTicker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, from); err != nil {
+		return nil, err
+	}
+	to, err := p.getTickerByName(ctx, req.ToTicker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTickerEnabled(to); err != nil {
+		return nil, err
+	}
+
+	// проверяем то что нужный кошелёк существует и с ним разрешены операции
+	if err := checkWallet(ctx, tx, req.WalletID); err != nil {
+		return nil, err
+	}
+
+	// получаем курс обмена
+	var rate models.Money
+	if err := tx.QueryRowContext(ctx,
+		"SELECT rate FROM exchange_rates WHERE from_ticker_id = $1 AND to_ticker_id = $2", from.TickerID, to.TickerID).Scan(&rate); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ExchangeRateDoesntExist(req.FromTicker, req.ToTicker)
+		}
+		return nil, err
+	}
+	creditAmount := req.Amount.Mul(rate, to.Scale, models.RoundHalfEven)
+	if err := checkExchangeAmount(creditAmount, from, to); err != nil {
+		return nil, err
+	}
+
+	// блокируем балансы кошелька по обоим тикерам и проверяем баланс по тикеру списания
+	key := balanceKey{walletID: req.WalletID, tickerID: from.TickerID}
+	balances, err := p.lockBalances(ctx, tx, key, balanceKey{walletID: req.WalletID, tickerID: to.TickerID})
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := balances[key]
+	if !ok {
+		return nil, NotEnoughCoins(req.WalletID, req.FromTicker)
+	}
+	// случай когда на счету недостаточно денег, сохраняется запись о неуспешном списании
+	if balance.Cmp(req.Amount) < 0 {
+		return nil, &insufficientFunds{
+			LogicErrors: NotEnoughCoins(req.WalletID, req.FromTicker),
+			failed: &models.Transaction{
+				WalletID:  req.WalletID,
+				TickerID:  from.TickerID,
+				Amount:    req.Amount.Neg(),
+				Operation: models.OperationExchange,
+			},
+		}
+	}
+
+	// создаём связанные записи в таблице transactions
+	debit := &models.Transaction{
+		WalletID:  req.WalletID,
+		TickerID:  from.TickerID,
+		Amount:    req.Amount.Neg(),
+		Status:    models.TransactionStatusCreated,
+		Operation: models.OperationExchange,
+	}
+	if err := p.createTransaction(ctx, tx, debit); err != nil {
+		return nil, err
+	}
+	credit := &models.Transaction{
+		WalletID:            req.WalletID,
+		TickerID:            to.TickerID,
+		Amount:              creditAmount,
+		Status:              models.TransactionStatusCreated,
+		Operation:           models.OperationExchange,
+		LinkedTransactionID: debit.ID,
+	}
+	if err := p.createTransaction(ctx, tx, credit); err != nil {
+		return nil, err
+	}
+	if err := p.linkTransaction(ctx, tx, debit, credit.ID); err != nil {
+		return nil, err
+	}
+
+	// списываем средства по тикеру списания
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE balances SET amount = amount - $1 WHERE wallet_id = $2 AND ticker_id = $3", req.Amount, req.WalletID, from.TickerID); err != nil {
+		return nil, err
+	}
+
+	// зачисляем средства по тикеру зачисления
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO balances (wallet_id, ticker_id, amount) VALUES ($1, $2, $3) ON CONFLICT (wallet_id, ticker_id) DO UPDATE SET amount = balances.amount + $3",
+		req.WalletID, to.TickerID, creditAmount); err != nil {
+		return nil, err
+	}
+
+	// меняем статусы транзакций на успешные
+	for _, transaction := range []*models.Transaction{debit, credit} {
+		transaction.Status = models.TransactionStatusSuccess
+		if err := p.updateTransactionStatus(ctx, tx, transaction); err != nil {
+			return nil, err
+		}
+	}
+
+	// обмен проходит через счёт обменной позиции, так что проводки сбалансированы в каждом тикере
+	entry := (&models.JournalEntry{Operation: models.OperationExchange}).
+		WalletPosting(debit, debit.Amount).
+		SystemPosting(models.AccountExchange, from.TickerID, req.Amount).
+		SystemPosting(models.AccountExchange, to.TickerID, creditAmount.Neg()).
+		WalletPosting(credit, credit.Amount)
+	if err := p.postEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	resp.DebitTransactionID, resp.CreditTransactionID = debit.ID, credit.ID
+	resp.Rate, resp.CreditAmount = rate, creditAmount
+
+	return resp, nil
+}
+
+func (p *PostgresRepo) Exchange(ctx context.Context, req *models.ExchangeRequest) (*models.ExchangeResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.ExchangeResponse, error) {
+		return p.exchange(ctx, req)
+	})
+	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpExchange)
+	}
+
+	return resp, nil
+}
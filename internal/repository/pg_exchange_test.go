@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"testing"
+)
+
+func TestExchangeChecksAmounts(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		walletID := r.wallet(t)
+		from, to := r.ticker(t, "EXF"), r.ticker(t, "EXT")
+		if err := r.SetExchangeRate(ctx, &models.ExchangeRate{FromTicker: from, ToTicker: to, Rate: models.MustParseMoney("10000")}); err != nil {
+			t.Fatalf("SetExchangeRate: %v", err)
+		}
+		r.invoice(t, walletID, from, "100000000000000000000")
+
+		// зачисляемая сумма не помещается в numeric(30, 8)
+		_, err := r.Exchange(ctx, &models.ExchangeRequest{WalletID: walletID, FromTicker: from, ToTicker: to,
+			Amount: models.MustParseMoney("100000000000000000000")})
+		checkErrorCode(t, "Exchange too large", err, ErrCodeExchangeAmountTooLarge)
+		if actual, _ := r.balance(t, walletID, to); actual != "0.00" {
+			t.Fatalf("balance of %s after rejected exchange = %s, want 0.00", to, actual)
+		}
+
+		// при нехватке средств сохраняется запись о неуспешном списании
+		walletID = r.wallet(t)
+		r.invoice(t, walletID, from, "5")
+		_, err = r.Exchange(ctx, &models.ExchangeRequest{WalletID: walletID, FromTicker: from, ToTicker: to,
+			Amount: models.MustParseMoney("10")})
+		checkErrorCode(t, "Exchange not enough coins", err, ErrCodeNotEnoughCoins)
+
+		resp, err := r.ListTransactions(ctx, &models.HistoryRequest{WalletID: walletID, Limit: models.MaxHistoryLimit})
+		if err != nil {
+			t.Fatalf("ListTransactions: %v", err)
+		}
+		failed := 0
+		for _, transaction := range resp.Transactions {
+			if transaction.Status == models.TransactionStatusError {
+				failed++
+			}
+		}
+		if failed != 1 {
+			t.Fatalf("failed transactions = %d, want 1", failed)
+		}
+	})
+}
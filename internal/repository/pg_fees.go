@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrFeeWalletNotConfigured = errors.New("fee wallet is not configured")
+
+// SetFee создаёт или заменяет правило расчёта комиссии для операции по тикеру
+func (p *PostgresRepo) SetFee(ctx context.Context, req *models.FeeRule) error {
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return err
+	}
+	for _, value := range []*models.Money{&req.Fixed, req.Min, req.Max} {
+		if value == nil {
+			continue
+		}
+		if err := checkAmountScale(*value, ticker); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.db.ExecContext(ctx,
+		`INSERT INTO fees (operation, ticker_id, fixed, rate, min_fee, max_fee, updated_at) VALUES ($1, $2, $3, $4, $5, $6, now())
+ON CONFLICT (operation, ticker_id) DO UPDATE SET fixed = $3, rate = $4, min_fee = $5, max_fee = $6, updated_at = now()`,
+		req.Operation, ticker.TickerID, req.Fixed, req.Rate, req.Min, req.Max); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListFees возвращает все правила расчёта комиссий
+func (p *PostgresRepo) ListFees(ctx context.Context) (*models.FeesResponse, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT f.operation, t.name, f.fixed, f.rate, f.min_fee, f.max_fee, f.updated_at
+FROM fees f JOIN tickers t ON t.ticker_id = f.ticker_id
+ORDER BY f.operation, t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.FeesResponse{Fees: make([]models.FeeRule, 0)}
+	for rows.Next() {
+		var fee models.FeeRule
+		if err := rows.Scan(&fee.Operation, &fee.Ticker, &fee.Fixed, &fee.Rate, &fee.Min, &fee.Max, &fee.UpdatedAt); err != nil {
+			return nil, err
+		}
+		resp.Fees = append(resp.Fees, fee)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over fee rows: %s", err)
+	}
+
+	return resp, nil
+}
+
+// DeleteFee удаляет правило расчёта комиссии для операции по тикеру
+func (p *PostgresRepo) DeleteFee(ctx context.Context, req *models.DeleteFeeRequest) error {
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.db.ExecContext(ctx,
+		"DELETE FROM fees WHERE operation = $1 AND ticker_id = $2", req.Operation, ticker.TickerID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return FeeDoesntExist(req.Operation, req.Ticker)
+	}
+
+	return nil
+}
+
+// calculateFee считает комиссию за операцию по правилу из таблицы fees. Если правила нет, то комиссия нулевая.
+func (p *PostgresRepo) calculateFee(ctx context.Context, tx *sql.Tx, operation string, ticker *models.Ticker, amount models.Money) (models.Money, error) {
+	rule := &models.FeeRule{Operation: operation, Ticker: ticker.Name}
+	if err := tx.QueryRowContext(ctx,
+		"SELECT fixed, rate, min_fee, max_fee FROM fees WHERE operation = $1 AND ticker_id = $2", operation, ticker.TickerID).Scan(
+		&rule.Fixed, &rule.Rate, &rule.Min, &rule.Max); err != nil {
+		if err == sql.ErrNoRows {
+			return models.Money{}, nil
+		}
+
+		return models.Money{}, err
+	}
+
+	fee := rule.Calculate(amount, ticker.Scale)
+	if fee.IsPositive() && p.feeWalletID == 0 {
+		return models.Money{}, ErrFeeWalletNotConfigured
+	}
+
+	return fee, nil
+}
+
+// createFeeTransaction создаёт связанную с операцией transaction запись о списании комиссии с того же кошелька
+func (p *PostgresRepo) createFeeTransaction(ctx context.Context, tx *sql.Tx, transaction *models.Transaction, fee models.Money) (*models.Transaction, error) {
+	feeTransaction := &models.Transaction{
+		WalletID:            transaction.WalletID,
+		TickerID:            transaction.TickerID,
+		Amount:              fee.Neg(),
+		Status:              transaction.Status,
+		Operation:           models.OperationFee,
+		LinkedTransactionID: transaction.ID,
+	}
+	if err := p.createTransaction(ctx, tx, feeTransaction); err != nil {
+		return nil, err
+	}
+	if err := p.linkTransaction(ctx, tx, transaction, feeTransaction.ID); err != nil {
+		return nil, err
+	}
+
+	return feeTransaction, nil
+}
+
+/*
+creditFeeWallet зачисляет списанную комиссию на кошелёк комиссий, запись о зачислении связывается с записью о списании.
+
+Баланс кошелька комиссий в транзакции операции не меняется: зачисление добавляется отдельной строкой в fee_credits,
+иначе каждая операция с комиссией обновляла бы одну и ту же строку balances и параллельные операции разных кошельков
+откатывались бы с ошибкой сериализации. Зачисления переносятся в balances в ApplyFeeCredits или перед списанием
+с кошелька комиссий в lockBalances, а баланс до переноса читается из представления current_balances.
+*/
+func (p *PostgresRepo) creditFeeWallet(ctx context.Context, tx *sql.Tx, feeTransaction *models.Transaction) (*models.Transaction, error) {
+	credit := &models.Transaction{
+		WalletID:            p.feeWalletID,
+		TickerID:            feeTransaction.TickerID,
+		Amount:              feeTransaction.Amount.Neg(),
+		Status:              models.TransactionStatusSuccess,
+		Operation:           models.OperationFee,
+		LinkedTransactionID: feeTransaction.ID,
+	}
+	if err := p.createTransaction(ctx, tx, credit); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO fee_credits (transaction_id, wallet_id, ticker_id, amount) VALUES ($1, $2, $3, $4)",
+		credit.ID, credit.WalletID, credit.TickerID, credit.Amount); err != nil {
+		return nil, err
+	}
+
+	return credit, nil
+}
+
+// applyFeeCreditsQuery удаляет выбранные зачисления комиссий и добавляет их суммы к балансам кошельков одним запросом,
+// поэтому зачисление в любой момент учтено ровно в одной из таблиц. %s условие выбора зачислений
+const applyFeeCreditsQuery = `
+WITH applied AS (DELETE FROM fee_credits WHERE %s RETURNING wallet_id, ticker_id, amount),
+     credited AS (INSERT INTO balances (wallet_id, ticker_id, amount)
+         SELECT wallet_id, ticker_id, SUM(amount)
+         FROM applied
+         GROUP BY wallet_id, ticker_id
+         ORDER BY wallet_id, ticker_id
+         ON CONFLICT (wallet_id, ticker_id) DO UPDATE SET amount = balances.amount + EXCLUDED.amount)
+SELECT COUNT(*)
+FROM applied`
+
+// applyFeeCredits переносит в balances все зачисления комиссий по балансу key
+func applyFeeCredits(ctx context.Context, tx *sql.Tx, key balanceKey) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(applyFeeCreditsQuery, "wallet_id = $1 AND ticker_id = $2"), key.walletID, key.tickerID)
+	return err
+}
+
+/*
+ApplyFeeCredits переносит в balances до limit самых старых зачислений комиссий и возвращает их количество.
+
+Перенос конфликтует только со списаниями с кошелька комиссий, которые переносят зачисления сами,
+при конфликте сериализации запрос повторяется.
+*/
+func (p *PostgresRepo) ApplyFeeCredits(ctx context.Context, limit int) (int, error) {
+	return withRetry(ctx, p.retry, func() (int, error) {
+		tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+		if err != nil {
+			return 0, err
+		}
+
+		var applied int
+		if err := tx.QueryRowContext(ctx,
+			fmt.Sprintf(applyFeeCreditsQuery, "transaction_id IN (SELECT transaction_id FROM fee_credits ORDER BY transaction_id LIMIT $1)"),
+			limit).Scan(&applied); err != nil {
+			return 0, rollbackTx(tx, err)
+		}
+
+		return applied, tx.Commit()
+	})
+}
+
+// getHeldFee возвращает замороженную комиссию, связанную со списанием transaction, или nil если комиссии нет
+func (p *PostgresRepo) getHeldFee(ctx context.Context, tx *sql.Tx, transaction *models.Transaction) (*models.Transaction, error) {
+	fee := &models.Transaction{
+		WalletID:            transaction.WalletID,
+		TickerID:            transaction.TickerID,
+		Operation:           models.OperationFee,
+		LinkedTransactionID: transaction.ID,
+	}
+	var status int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT id, amount, status FROM transactions WHERE linked_transaction_id = $1 AND operation = $2 FOR UPDATE",
+		transaction.ID, models.OperationFee).Scan(&fee.ID, &fee.Amount, &status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	fee.Status = models.TransactionStatus(status)
+
+	return fee, nil
+}
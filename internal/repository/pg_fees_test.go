@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestFeeCredits(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		ticker := r.ticker(t, "FEE")
+		if err := r.SetFee(ctx, &models.FeeRule{Operation: models.OperationInvoice, Ticker: ticker, Fixed: models.MustParseMoney("1")}); err != nil {
+			t.Fatalf("SetFee: %v", err)
+		}
+
+		// параллельные зачисления на разные кошельки не конфликтуют из-за общего кошелька комиссий
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			walletID := r.wallet(t)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := r.Invoice(ctx, &models.InvoiceRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("100")}); err != nil {
+					t.Errorf("Invoice(%d): %v", walletID, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if t.Failed() {
+			return
+		}
+
+		if actual, _ := r.balance(t, r.feeWalletID, ticker); actual != "10.00" {
+			t.Fatalf("fee wallet balance before applying credits = %s, want 10.00", actual)
+		}
+		if _, err := r.ApplyFeeCredits(ctx, 3); err != nil {
+			t.Fatalf("ApplyFeeCredits: %v", err)
+		}
+		if actual, _ := r.balance(t, r.feeWalletID, ticker); actual != "10.00" {
+			t.Fatalf("fee wallet balance after applying credits = %s, want 10.00", actual)
+		}
+
+		// списание с кошелька комиссий видит и ещё не перенесённые зачисления
+		r.invoice(t, r.wallet(t), ticker, "100")
+		if _, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: r.feeWalletID, Ticker: ticker, Amount: models.MustParseMoney("11")}); err != nil {
+			t.Fatalf("WithDraw from fee wallet: %v", err)
+		}
+		if actual, frozen := r.balance(t, r.feeWalletID, ticker); actual != "0.00" || frozen != "11.00" {
+			t.Fatalf("fee wallet balance = %s/%s, want 0.00/11.00", actual, frozen)
+		}
+
+		report, err := r.Reconcile(ctx)
+		if err != nil {
+			t.Fatalf("Reconcile: %v", err)
+		}
+		for _, mismatch := range report.Mismatches {
+			if mismatch.WalletID == r.feeWalletID {
+				t.Fatalf("fee wallet mismatch: %+v", mismatch)
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+1) Проверяем то что нужный кошелёк существует
+
+2) Собираем условия выборки из фильтров запроса, курсор задаёт id транзакции, после которой начинается страница
+
+3) Получаем на одну транзакцию больше размера страницы, чтобы понять есть ли следующая страница
+
+4) Формируем вывод из ответа бд, курсором следующей страницы становится id последней транзакции на странице
+
+Транзакции сортируются по id от новых к старым, поэтому новые транзакции не сдвигают уже полученные страницы.
+*/
+func (p *PostgresRepo) ListTransactions(ctx context.Context, req *models.HistoryRequest) (*models.HistoryResponse, error) {
+	// проверяем то что нужный кошелёк существует
+	var wID int
+	if err := p.db.QueryRowContext(ctx, "SELECT wallet_id FROM wallets WHERE wallet_id = $1", req.WalletID).Scan(&wID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, WalletDoesntExist(req.WalletID)
+		}
+
+		return nil, err
+	}
+
+	cursorID, err := req.CursorID()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"t.wallet_id = $1"}
+	args := []any{req.WalletID}
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if req.Ticker != "" {
+		addCondition("tk.name = $%d", req.Ticker)
+	}
+	if req.Status != nil {
+		addCondition("t.status = $%d", int(*req.Status))
+	}
+	if req.From != nil {
+		addCondition("t.created_at >= $%d", *req.From)
+	}
+	if req.To != nil {
+		addCondition("t.created_at < $%d", *req.To)
+	}
+	if cursorID != 0 {
+		addCondition("t.id < $%d", cursorID)
+	}
+
+	limit := req.PageSize()
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT t.id, tk.name, tk.scale, t.amount, t.status, t.created_at, t.updated_at
+FROM all_transactions t JOIN tickers tk ON tk.ticker_id = t.ticker_id
+WHERE %s
+ORDER BY t.id DESC
+LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.HistoryResponse{Transactions: make([]models.TransactionInfo, 0, limit)}
+	for rows.Next() {
+		var info models.TransactionInfo
+		var scale, status int
+		if err := rows.Scan(&info.ID, &info.Ticker, &scale, &info.Amount, &status, &info.CreatedAt, &info.UpdatedAt); err != nil {
+			return nil, err
+		}
+		info.Amount = info.Amount.Round(scale, models.RoundDown)
+		info.Status = models.TransactionStatus(status)
+		resp.Transactions = append(resp.Transactions, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over transaction rows: %s", err)
+	}
+
+	// если получили больше транзакций чем размер страницы, то есть следующая страница
+	if len(resp.Transactions) > limit {
+		resp.Transactions = resp.Transactions[:limit]
+		resp.NextCursor = models.EncodeCursor(resp.Transactions[limit-1].ID)
+	}
+
+	return resp, nil
+}
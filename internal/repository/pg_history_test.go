@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// listHistory возвращает все страницы истории по запросу req, каждая транзакция записывается как "тикер:сумма:статус"
+func (r *testRepo) listHistory(t *testing.T, req models.HistoryRequest) ([]string, int) {
+	t.Helper()
+	var transactions []string
+	pages := 0
+	for {
+		resp, err := r.ListTransactions(context.Background(), &req)
+		if err != nil {
+			t.Fatalf("ListTransactions: %v", err)
+		}
+		pages++
+		for _, transaction := range resp.Transactions {
+			transactions = append(transactions, fmt.Sprintf("%s:%s:%d", transaction.Ticker, transaction.Amount, transaction.Status))
+		}
+		if resp.NextCursor == "" {
+			return transactions, pages
+		}
+		req.Cursor = resp.NextCursor
+	}
+}
+
+func TestListTransactions(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		walletID := r.wallet(t)
+		a, b := r.ticker(t, "HSA"), r.ticker(t, "HSB")
+
+		// первые две транзакции уходят в архив, история читается из обеих таблиц
+		r.invoice(t, walletID, a, "1")
+		r.invoice(t, walletID, a, "2")
+		r.archiveAll(t, r.ageTransactions(t, walletID))
+		r.invoice(t, walletID, a, "3")
+		r.invoice(t, walletID, b, "4")
+		_, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: a, Amount: models.MustParseMoney("100")})
+		checkErrorCode(t, "WithDraw", err, ErrCodeNotEnoughCoins)
+
+		all := []string{a + ":-100.00:1", b + ":4.00:0", a + ":3.00:0", a + ":2.00:0", a + ":1.00:0"}
+		transactions, pages := r.listHistory(t, models.HistoryRequest{WalletID: walletID, Limit: 2})
+		if fmt.Sprint(transactions) != fmt.Sprint(all) || pages != 3 {
+			t.Fatalf("history by 2 = %v in %d pages, want %v in 3 pages", transactions, pages, all)
+		}
+
+		// время создания третьей транзакции делит историю на две части
+		resp, err := r.ListTransactions(ctx, &models.HistoryRequest{WalletID: walletID, Limit: 3})
+		if err != nil {
+			t.Fatalf("ListTransactions: %v", err)
+		}
+		middle := resp.Transactions[2].CreatedAt
+		failed := models.TransactionStatusError
+
+		for _, tc := range []struct {
+			name string
+			req  models.HistoryRequest
+			want []string
+		}{
+			{name: "ticker", req: models.HistoryRequest{Ticker: a}, want: []string{all[0], all[2], all[3], all[4]}},
+			{name: "status", req: models.HistoryRequest{Status: &failed}, want: all[:1]},
+			{name: "from", req: models.HistoryRequest{From: &middle}, want: all[:3]},
+			{name: "to", req: models.HistoryRequest{To: &middle}, want: all[3:]},
+			{name: "ticker and to", req: models.HistoryRequest{Ticker: b, To: &middle}, want: nil},
+		} {
+			tc.req.WalletID, tc.req.Limit = walletID, 1
+			if transactions, _ := r.listHistory(t, tc.req); fmt.Sprint(transactions) != fmt.Sprint(tc.want) {
+				t.Errorf("%s: history = %v, want %v", tc.name, transactions, tc.want)
+			}
+		}
+
+		_, err = r.ListTransactions(ctx, &models.HistoryRequest{WalletID: 1 << 30})
+		checkErrorCode(t, "ListTransactions", err, ErrCodeWalletNotFound)
+	})
+}
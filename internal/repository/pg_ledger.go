@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+)
+
+// postEntry записывает в журнал сбалансированную запись с проводками в рамках транзакции tx.
+// Несбалансированная запись отклоняется ещё до записи в бд, дополнительно баланс проверяет
+// отложенный триггер postings_balanced при подтверждении транзакции.
+func (p *PostgresRepo) postEntry(ctx context.Context, tx *sql.Tx, entry *models.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	if err := tx.QueryRowContext(ctx,
+		"INSERT INTO journal_entries (operation) VALUES ($1) RETURNING entry_id", entry.Operation).Scan(&entry.ID); err != nil {
+		return err
+	}
+
+	for _, posting := range entry.Postings {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO postings (entry_id, wallet_id, account, ticker_id, amount, transaction_id) VALUES ($1, $2, $3, $4, $5, $6)",
+			entry.ID, nullableID(posting.WalletID), nullableAccount(posting.Account), posting.TickerID, posting.Amount,
+			nullableID(posting.TransactionID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nullableAccount возвращает NULL для проводки по кошельку
+func nullableAccount(account string) any {
+	if account == "" {
+		return nil
+	}
+
+	return account
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"testing"
+)
+
+// accountBalance возвращает сумму проводок по счёту кошелька walletID или системному счёту account в тикере
+func (r *testRepo) accountBalance(t *testing.T, walletID int, account, ticker string) string {
+	t.Helper()
+	if r.pg == nil {
+		m := r.Repository.(*MemoryRepo)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		var sum models.Money
+		for _, entry := range m.journal {
+			for _, posting := range entry.Postings {
+				if posting.WalletID == walletID && posting.Account == account && posting.TickerID == m.tickers[ticker].TickerID {
+					sum = sum.Add(posting.Amount)
+				}
+			}
+		}
+		return sum.StringFixed(2)
+	}
+
+	var sum models.Money
+	if err := r.pg.db.QueryRow(`SELECT COALESCE(SUM(p.amount), 0)
+FROM postings p JOIN tickers tk ON tk.ticker_id = p.ticker_id
+WHERE p.wallet_id IS NOT DISTINCT FROM $1 AND p.account IS NOT DISTINCT FROM $2 AND tk.name = $3`,
+		nullableID(walletID), nullableAccount(account), ticker).Scan(&sum); err != nil {
+		t.Fatalf("account balance: %v", err)
+	}
+
+	return sum.StringFixed(2)
+}
+
+func TestLedgerPostings(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		walletID, otherID := r.wallet(t), r.wallet(t)
+		ticker, target := r.ticker(t, "LED"), r.ticker(t, "LEX")
+		if err := r.SetExchangeRate(ctx, &models.ExchangeRate{FromTicker: ticker, ToTicker: target, Rate: models.MustParseMoney("2")}); err != nil {
+			t.Fatalf("SetExchangeRate: %v", err)
+		}
+
+		r.invoice(t, walletID, ticker, "100")
+		for _, capture := range []bool{false, true} {
+			resp, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("10")})
+			if err != nil {
+				t.Fatalf("WithDraw: %v", err)
+			}
+			hold := &models.HoldRequest{TransactionID: resp.TransactionID}
+			if capture {
+				_, err = r.Capture(ctx, hold)
+			} else {
+				_, err = r.Release(ctx, hold)
+			}
+			if err != nil {
+				t.Fatalf("capture %v: %v", capture, err)
+			}
+		}
+		if _, err := r.Transfer(ctx, &models.TransferRequest{FromWalletID: walletID, ToWalletID: otherID, Ticker: ticker,
+			Amount: models.MustParseMoney("30")}); err != nil {
+			t.Fatalf("Transfer: %v", err)
+		}
+		if _, err := r.Exchange(ctx, &models.ExchangeRequest{WalletID: walletID, FromTicker: ticker, ToTicker: target,
+			Amount: models.MustParseMoney("15")}); err != nil {
+			t.Fatalf("Exchange: %v", err)
+		}
+
+		// остатки счетов кошельков совпадают с актуальными балансами, а сумма всех счетов по тикеру равна нулю
+		for _, tc := range []struct {
+			walletID int
+			account  string
+			ticker   string
+			want     string
+		}{
+			{walletID: walletID, ticker: ticker, want: "45.00"},
+			{walletID: otherID, ticker: ticker, want: "30.00"},
+			{walletID: walletID, ticker: target, want: "30.00"},
+			{account: models.AccountCashIn, ticker: ticker, want: "-100.00"},
+			{account: models.AccountCashOut, ticker: ticker, want: "10.00"},
+			{account: models.AccountHolds, ticker: ticker, want: "0.00"},
+			{account: models.AccountExchange, ticker: ticker, want: "15.00"},
+			{account: models.AccountExchange, ticker: target, want: "-30.00"},
+		} {
+			if got := r.accountBalance(t, tc.walletID, tc.account, tc.ticker); got != tc.want {
+				t.Errorf("account %d%s balance of %s = %s, want %s", tc.walletID, tc.account, tc.ticker, got, tc.want)
+			}
+		}
+		if actual, _ := r.balance(t, walletID, ticker); actual != "45.00" {
+			t.Errorf("wallet balance of %s = %s, want 45.00", ticker, actual)
+		}
+	})
+}
+
+func TestUnbalancedJournalEntryRejected(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		walletID := r.wallet(t)
+		ticker := r.ticker(t, "LUB")
+		transactionID := r.invoice(t, walletID, ticker, "1")
+		before := r.accountBalance(t, 0, models.AccountCashIn, ticker)
+
+		transaction := &models.Transaction{ID: transactionID, WalletID: walletID}
+		if r.pg == nil {
+			m := r.Repository.(*MemoryRepo)
+			transaction.TickerID = m.tickers[ticker].TickerID
+			entry := (&models.JournalEntry{Operation: models.OperationInvoice}).
+				WalletPosting(transaction, models.MustParseMoney("1")).
+				SystemPosting(models.AccountCashIn, transaction.TickerID, models.MustParseMoney("-0.99"))
+			m.mu.Lock()
+			err := m.postEntry(entry)
+			m.mu.Unlock()
+			if err == nil {
+				t.Fatalf("postEntry accepted an unbalanced entry")
+			}
+		} else {
+			ctx := context.Background()
+			if err := r.pg.db.QueryRow("SELECT ticker_id FROM tickers WHERE name = $1", ticker).Scan(&transaction.TickerID); err != nil {
+				t.Fatalf("ticker id: %v", err)
+			}
+			entry := (&models.JournalEntry{Operation: models.OperationInvoice}).
+				WalletPosting(transaction, models.MustParseMoney("1")).
+				SystemPosting(models.AccountCashIn, transaction.TickerID, models.MustParseMoney("-0.99"))
+
+			tx, err := r.pg.db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatalf("BeginTx: %v", err)
+			}
+			if err := r.pg.postEntry(ctx, tx, entry); err == nil {
+				_ = tx.Rollback()
+				t.Fatalf("postEntry accepted an unbalanced entry")
+			}
+			_ = tx.Rollback()
+
+			// запись в обход postEntry отклоняет отложенный триггер при подтверждении транзакции
+			tx, err = r.pg.db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatalf("BeginTx: %v", err)
+			}
+			var entryID int
+			if err := tx.QueryRow("INSERT INTO journal_entries (operation) VALUES ($1) RETURNING entry_id", entry.Operation).Scan(&entryID); err != nil {
+				_ = tx.Rollback()
+				t.Fatalf("insert journal entry: %v", err)
+			}
+			if _, err := tx.Exec("INSERT INTO postings (entry_id, account, ticker_id, amount) VALUES ($1, $2, $3, $4)",
+				entryID, models.AccountCashIn, transaction.TickerID, models.MustParseMoney("-0.99")); err != nil {
+				_ = tx.Rollback()
+				t.Fatalf("insert posting: %v", err)
+			}
+			if err := tx.Commit(); err == nil {
+				t.Fatalf("commit of an unbalanced entry succeeded")
+			}
+		}
+
+		if after := r.accountBalance(t, 0, models.AccountCashIn, ticker); after != before {
+			t.Fatalf("cash in balance after rejected entry = %s, want %s", after, before)
+		}
+	})
+}
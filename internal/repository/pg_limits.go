@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// effectiveLimitsQuery выбирает ограничения, которые действуют для кошелька $1: собственные ограничения кошелька
+// по тикеру, а если их нет, то общие
+const effectiveLimitsQuery = `SELECT DISTINCT ON (l.ticker_id) l.wallet_id, t.name, l.max_single, l.daily, l.monthly, l.updated_at
+FROM withdrawal_limits l JOIN tickers t ON t.ticker_id = l.ticker_id
+WHERE (l.wallet_id = $1 OR l.wallet_id IS NULL)`
+
+// scanLimits читает ограничения из результата запроса
+func scanLimits(rows *sql.Rows) ([]models.WithdrawalLimit, error) {
+	limits := make([]models.WithdrawalLimit, 0)
+	for rows.Next() {
+		var limit models.WithdrawalLimit
+		var walletID sql.NullInt64
+		if err := rows.Scan(&walletID, &limit.Ticker, &limit.MaxSingle, &limit.Daily, &limit.Monthly, &limit.UpdatedAt); err != nil {
+			return nil, err
+		}
+		limit.WalletID = int(walletID.Int64)
+		limits = append(limits, limit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over limit rows: %s", err)
+	}
+
+	return limits, nil
+}
+
+// SetWithdrawalLimit создаёт или заменяет ограничения на списания кошелька (или общие) по тикеру
+func (p *PostgresRepo) SetWithdrawalLimit(ctx context.Context, req *models.WithdrawalLimit) error {
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return err
+	}
+	if req.WalletID != 0 {
+		if _, err := p.GetWallet(ctx, &models.GetWalletRequest{WalletID: req.WalletID}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.db.ExecContext(ctx,
+		`INSERT INTO withdrawal_limits (wallet_id, ticker_id, max_single, daily, monthly, updated_at) VALUES ($1, $2, $3, $4, $5, now())
+ON CONFLICT (COALESCE(wallet_id, 0), ticker_id) DO UPDATE SET max_single = $3, daily = $4, monthly = $5, updated_at = now()`,
+		nullableID(req.WalletID), ticker.TickerID, req.MaxSingle, req.Daily, req.Monthly); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListWithdrawalLimits возвращает все ограничения или ограничения, которые действуют для кошелька
+func (p *PostgresRepo) ListWithdrawalLimits(ctx context.Context, req *models.WithdrawalLimitsRequest) (*models.WithdrawalLimitsResponse, error) {
+	var rows *sql.Rows
+	var err error
+	if req.WalletID == 0 {
+		rows, err = p.db.QueryContext(ctx,
+			`SELECT l.wallet_id, t.name, l.max_single, l.daily, l.monthly, l.updated_at
+FROM withdrawal_limits l JOIN tickers t ON t.ticker_id = l.ticker_id
+ORDER BY l.wallet_id NULLS FIRST, t.name`)
+	} else {
+		rows, err = p.db.QueryContext(ctx, effectiveLimitsQuery+" ORDER BY l.ticker_id, l.wallet_id NULLS LAST", req.WalletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits, err := scanLimits(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WithdrawalLimitsResponse{Limits: limits}, nil
+}
+
+// DeleteWithdrawalLimit удаляет ограничения на списания кошелька (или общие) по тикеру
+func (p *PostgresRepo) DeleteWithdrawalLimit(ctx context.Context, req *models.DeleteWithdrawalLimitRequest) error {
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.db.ExecContext(ctx,
+		"DELETE FROM withdrawal_limits WHERE COALESCE(wallet_id, 0) = $1 AND ticker_id = $2", req.WalletID, ticker.TickerID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return WithdrawalLimitDoesntExist(req.WalletID, req.Ticker)
+	}
+
+	return nil
+}
+
+/*
+1) Получаем ограничения, которые действуют для кошелька по тикеру. Если их нет, то списание разрешено
+
+2) Проверяем сумму одного списания
+
+3) Считаем сумму списаний за текущие сутки и месяц по таблице transactions. Учитываются подтверждённые
+и замороженные списания, отменённые через Release не учитываются
+
+4) Проверяем что с новым списанием суммы не превысят ограничения
+
+Вызывается внутри транзакции списания, поэтому видит те же данные, что и само списание.
+*/
+func (p *PostgresRepo) checkWithdrawalLimits(ctx context.Context, tx *sql.Tx, walletID int, ticker *models.Ticker, amount models.Money) error {
+	rows, err := tx.QueryContext(ctx,
+		effectiveLimitsQuery+" AND l.ticker_id = $2 ORDER BY l.ticker_id, l.wallet_id NULLS LAST", walletID, ticker.TickerID)
+	if err != nil {
+		return err
+	}
+	limits, err := scanLimits(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	limit := limits[0]
+
+	if limit.MaxSingle != nil && amount.Cmp(*limit.MaxSingle) > 0 {
+		return WithdrawalLimitExceeded(walletID, ticker.Name, "single", *limit.MaxSingle)
+	}
+	if limit.Daily == nil && limit.Monthly == nil {
+		return nil
+	}
+
+	// суммы списаний хранятся с минусом
+	var daily, monthly models.Money
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(-SUM(amount) FILTER (WHERE created_at >= date_trunc('day', now())), 0), COALESCE(-SUM(amount), 0)
+FROM transactions
+WHERE wallet_id = $1 AND ticker_id = $2 AND operation = $3 AND status IN ($4, $5) AND created_at >= date_trunc('month', now())`,
+		walletID, ticker.TickerID, models.OperationWithdraw,
+		models.TransactionStatusSuccess, models.TransactionStatusCreated).Scan(&daily, &monthly); err != nil {
+		return err
+	}
+
+	if limit.Daily != nil && daily.Add(amount).Cmp(*limit.Daily) > 0 {
+		return WithdrawalLimitExceeded(walletID, ticker.Name, "daily", *limit.Daily)
+	}
+	if limit.Monthly != nil && monthly.Add(amount).Cmp(*limit.Monthly) > 0 {
+		return WithdrawalLimitExceeded(walletID, ticker.Name, "monthly", *limit.Monthly)
+	}
+
+	return nil
+}
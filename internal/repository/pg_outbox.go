@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"sort"
+	"time"
+)
+
+/*
+recordTransactionEvents сохраняет в outbox события о транзакции transaction в рамках транзакции tx.
+
+Номер события кошелька здесь не назначается: счётчик в строке кошелька менялся бы каждой операцией с кошельком,
+и одновременные операции с разными тикерами одного кошелька или с кошельком комиссий откатывались бы
+из-за конфликта сериализации. Номера назначает ClaimOutboxEvents при выборе событий для публикации.
+Для событий финального статуса здесь же создаются доставки уведомлений на адреса кошелька и общие адреса.
+*/
+func (p *PostgresRepo) recordTransactionEvents(ctx context.Context, tx *sql.Tx, transaction *models.Transaction, created bool) error {
+	ticker, err := p.getTicker(ctx, transaction.TickerID)
+	if err != nil {
+		return err
+	}
+
+	for _, eventType := range models.TransactionEventTypes(transaction.Status, created) {
+		event := models.TransactionEvent{
+			Type:                eventType,
+			WalletID:            transaction.WalletID,
+			TransactionID:       transaction.ID,
+			Ticker:              ticker.Name,
+			Amount:              transaction.Amount,
+			Status:              transaction.Status,
+			Operation:           transaction.Operation,
+			LinkedTransactionID: transaction.LinkedTransactionID,
+			OccurredAt:          time.Now().UTC(),
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.QueryRowContext(ctx,
+			"INSERT INTO outbox (event_type, wallet_id, payload) VALUES ($1, $2, $3) RETURNING id",
+			eventType, transaction.WalletID, payload).Scan(&event.EventID); err != nil {
+			return err
+		}
+
+		if eventType != models.EventTransactionCreated {
+			if err := createWebhookDeliveries(ctx, tx, &event); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// createFailedTransaction сохраняет запись о неудачном списании вместе с её событиями и ошибкой outcome
+// по ключу идемпотентности key, чтобы повтор запроса не создал ещё одну запись.
+// Вызывается после отката транзакции операции, поэтому выполняется в отдельной транзакции.
+func (p *PostgresRepo) createFailedTransaction(ctx context.Context, failed *models.Transaction, key, operation string, outcome LogicErrors) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	failed.Status = models.TransactionStatusError
+	if err := p.createTransaction(ctx, tx, failed); err != nil {
+		return rollbackTx(tx, err)
+	}
+	if err := saveIdempotentError(ctx, tx, key, operation, outcome); err != nil {
+		return rollbackTx(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+ClaimOutboxEvents выбирает до limit самых ранних неопубликованных событий и блокирует их на lease.
+
+События выбираются только с начала очереди и только когда ни одно неопубликованное событие не заблокировано,
+так что одновременно публикует события только один экземпляр сервиса и порядок публикации совпадает с порядком id.
+Если события не были отмечены опубликованными до истечения блокировки, то они выбираются снова.
+
+Событиям, которые выбираются впервые, в той же транзакции назначаются следующие номера их кошельков
+в порядке id, поэтому номера событий кошелька идут без пропусков в порядке публикации, а при повторной
+публикации событие сохраняет свой номер. Операция, начатая после подтверждения другой, получает большие id
+событий, так что порядок номеров не противоречит порядку зависимых операций кошелька.
+*/
+func (p *PostgresRepo) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.TransactionEvent, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// выбор событий сериализуется между экземплярами сервиса, иначе оба могли бы не увидеть блокировку другого
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock(hashtext('outbox'))").Scan(&locked); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	if !locked {
+		return nil, rollbackTx(tx, nil)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+UPDATE outbox
+SET locked_until = now() + make_interval(secs => $2)
+WHERE id IN (SELECT id FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT $1)
+  AND NOT EXISTS (SELECT 1 FROM outbox WHERE published_at IS NULL AND locked_until > now())
+RETURNING id, sequence, payload`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	events, err := scanOutboxEvents(rows)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	if err := assignOutboxSequences(ctx, tx, events); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func scanOutboxEvents(rows *sql.Rows) ([]models.TransactionEvent, error) {
+	defer rows.Close()
+
+	events := make([]models.TransactionEvent, 0)
+	for rows.Next() {
+		var event models.TransactionEvent
+		var id int64
+		var sequence sql.NullInt64
+		var payload []byte
+		if err := rows.Scan(&id, &sequence, &payload); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("invalid payload of outbox event %d: %v", id, err)
+		}
+		event.EventID, event.Sequence = id, sequence.Int64
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over outbox rows: %s", err)
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].EventID < events[j].EventID
+	})
+
+	return events, nil
+}
+
+// assignOutboxSequences назначает номера событиям из events, у которых их ещё нет. events отсортированы по id.
+func assignOutboxSequences(ctx context.Context, tx *sql.Tx, events []models.TransactionEvent) error {
+	counts := make(map[int]int64)
+	wallets := make([]int, 0)
+	for _, event := range events {
+		if event.Sequence != 0 {
+			continue
+		}
+		if counts[event.WalletID] == 0 {
+			wallets = append(wallets, event.WalletID)
+		}
+		counts[event.WalletID]++
+	}
+	if len(wallets) == 0 {
+		return nil
+	}
+
+	// next номер следующего события кошелька, счётчики кошельков увеличиваются сразу на все выбранные события
+	next := make(map[int]int64, len(wallets))
+	for _, walletID := range wallets {
+		var last int64
+		if err := tx.QueryRowContext(ctx, `
+INSERT INTO outbox_sequences (wallet_id, sequence)
+VALUES ($1, $2)
+ON CONFLICT (wallet_id) DO UPDATE SET sequence = outbox_sequences.sequence + EXCLUDED.sequence
+RETURNING sequence`,
+			walletID, counts[walletID]).Scan(&last); err != nil {
+			return err
+		}
+		next[walletID] = last - counts[walletID] + 1
+	}
+
+	ids, sequences := make([]int64, 0), make([]int64, 0)
+	for i := range events {
+		if events[i].Sequence != 0 {
+			continue
+		}
+		events[i].Sequence = next[events[i].WalletID]
+		next[events[i].WalletID]++
+		ids, sequences = append(ids, events[i].EventID), append(sequences, events[i].Sequence)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+UPDATE outbox
+SET sequence = s.sequence
+FROM unnest($1::bigint[], $2::bigint[]) AS s(id, sequence)
+WHERE outbox.id = s.id`,
+		pq.Array(ids), pq.Array(sequences))
+
+	return err
+}
+
+// MarkOutboxPublished отмечает события с id из ids опубликованными, больше они не выбираются
+func (p *PostgresRepo) MarkOutboxPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := p.db.ExecContext(ctx,
+		"UPDATE outbox SET published_at = now(), locked_until = NULL WHERE id = ANY($1)", pq.Array(ids))
+
+	return err
+}
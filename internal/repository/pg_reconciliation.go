@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// reconciliationQuery сопоставляет каждый актуальный баланс из current_balances с суммой транзакций по тому же кошельку и тикеру.
+// FULL JOIN нужен, чтобы найти и транзакции, для которых нет баланса.
+const reconciliationQuery = `
+SELECT COALESCE(b.wallet_id, t.wallet_id), tk.name, COALESCE(b.amount, 0), COALESCE(t.amount, 0)
+FROM current_balances b
+         FULL JOIN (SELECT wallet_id, ticker_id, SUM(amount) AS amount
+                    FROM all_transactions
+                    WHERE status IN ($1, $2)
+                    GROUP BY wallet_id, ticker_id) t ON t.wallet_id = b.wallet_id AND t.ticker_id = b.ticker_id
+         JOIN tickers tk ON tk.ticker_id = COALESCE(b.ticker_id, t.ticker_id)
+ORDER BY 1, 2`
+
+/*
+Reconcile пересчитывает баланс каждого кошелька по каждому тикеру из журнала транзакций и сравнивает его с актуальным балансом.
+
+Ожидаемый баланс равен сумме транзакций в статусе models.TransactionStatusSuccess и замороженных списаний
+в статусе models.TransactionStatusCreated, потому что замороженные средства уже вычтены из актуального баланса.
+Чтение выполняется в одной транзакции REPEATABLE READ, поэтому параллельные операции не дают ложных расхождений.
+*/
+func (p *PostgresRepo) Reconcile(ctx context.Context) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{StartedAt: time.Now(), Mismatches: make([]models.BalanceMismatch, 0)}
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, reconciliationQuery, models.TransactionStatusSuccess, models.TransactionStatusCreated)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.BalanceMismatch
+		if err := rows.Scan(&m.WalletID, &m.Ticker, &m.Balance, &m.Expected); err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+		report.Checked++
+
+		if m.Balance.Cmp(m.Expected) != 0 {
+			m.Difference = m.Balance.Sub(m.Expected)
+			report.Mismatches = append(report.Mismatches, m)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, rollbackTx(tx, fmt.Errorf("error encountered while iterating over reconciliation rows: %s", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	report.FinishedAt = time.Now()
+
+	return report, nil
+}
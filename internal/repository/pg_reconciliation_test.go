@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"testing"
+)
+
+// corruptBalance меняет баланс кошелька в обход журнала транзакций
+func (r *testRepo) corruptBalance(t *testing.T, walletID int, ticker, amount string) {
+	t.Helper()
+	if r.pg == nil {
+		m := r.Repository.(*MemoryRepo)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.addBalance(walletID, m.tickers[ticker].TickerID, models.MustParseMoney(amount))
+		return
+	}
+
+	if _, err := r.pg.db.Exec(`UPDATE balances b SET amount = b.amount + $1
+FROM tickers tk WHERE tk.ticker_id = b.ticker_id AND b.wallet_id = $2 AND tk.name = $3`,
+		models.MustParseMoney(amount), walletID, ticker); err != nil {
+		t.Fatalf("corrupt balance: %v", err)
+	}
+}
+
+// mismatches возвращает расхождения сверки по кошельку walletID
+func (r *testRepo) mismatches(t *testing.T, walletID int) []models.BalanceMismatch {
+	t.Helper()
+	report, err := r.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if report.Checked == 0 {
+		t.Fatalf("Reconcile checked no balances")
+	}
+
+	var mismatches []models.BalanceMismatch
+	for _, mismatch := range report.Mismatches {
+		if mismatch.WalletID == walletID {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+
+	return mismatches
+}
+
+func TestReconcile(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		walletID, otherID := r.wallet(t), r.wallet(t)
+		ticker := r.ticker(t, "REC")
+
+		// замороженные, подтверждённые и неуспешные списания и переводы не дают расхождений
+		r.invoice(t, walletID, ticker, "100")
+		if _, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("10")}); err != nil {
+			t.Fatalf("WithDraw: %v", err)
+		}
+		captured, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("20")})
+		if err != nil {
+			t.Fatalf("WithDraw: %v", err)
+		}
+		if _, err := r.Capture(ctx, &models.HoldRequest{TransactionID: captured.TransactionID}); err != nil {
+			t.Fatalf("Capture: %v", err)
+		}
+		_, err = r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("1000")})
+		checkErrorCode(t, "WithDraw", err, ErrCodeNotEnoughCoins)
+		if _, err := r.Transfer(ctx, &models.TransferRequest{FromWalletID: walletID, ToWalletID: otherID, Ticker: ticker,
+			Amount: models.MustParseMoney("30")}); err != nil {
+			t.Fatalf("Transfer: %v", err)
+		}
+		if mismatches := r.mismatches(t, walletID); len(mismatches) != 0 {
+			t.Fatalf("mismatches = %+v, want none", mismatches)
+		}
+
+		// баланс, изменённый в обход журнала, попадает в отчёт
+		r.corruptBalance(t, walletID, ticker, "5")
+		mismatches := r.mismatches(t, walletID)
+		if len(mismatches) != 1 {
+			t.Fatalf("mismatches = %+v, want 1", mismatches)
+		}
+		mismatch := mismatches[0]
+		if mismatch.Ticker != ticker || mismatch.Balance.StringFixed(2) != "45.00" ||
+			mismatch.Expected.StringFixed(2) != "40.00" || mismatch.Difference.StringFixed(2) != "5.00" {
+			t.Fatalf("mismatch = %+v, want %s 45.00 against 40.00", mismatch, ticker)
+		}
+		if mismatches := r.mismatches(t, otherID); len(mismatches) != 0 {
+			t.Fatalf("mismatches of the other wallet = %+v, want none", mismatches)
+		}
+	})
+}
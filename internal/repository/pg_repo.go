@@ -2,13 +2,24 @@ package repository
 
 import (
 	"bwg_transactional_system/internal/models"
+	"bwg_transactional_system/migrations"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 )
 
 type PostgresRepo struct {
-	db *sql.DB
+	db       *sql.DB
+	migrator *Migrator
+	// tickers кэш тикеров, чтобы не читать тикер из бд в каждом запросе
+	tickers *tickerCache
+	// retry правило повтора транзакций при ошибках сериализации и взаимоблокировках
+	retry RetryPolicy
+	// feeWalletID кошелёк, на который зачисляются комиссии за операции
+	feeWalletID int
 }
 
 type Config struct {
@@ -18,38 +29,18 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// FeeWalletID кошелёк для зачисления комиссий, 0 если комиссии не настроены
+	FeeWalletID int
+	// TickerCacheSize максимальное количество тикеров в кэше, по умолчанию defaultTickerCacheSize
+	TickerCacheSize int
+	// TickerCacheTTL время, через которое тикер перечитывается из бд, по умолчанию defaultTickerCacheTTL
+	TickerCacheTTL time.Duration
+	// Retry правило повтора транзакций, по умолчанию DefaultRetryPolicy
+	Retry *RetryPolicy
+	// SkipMigrations не применять миграции при подключении, например для команды migrate
+	SkipMigrations bool
 }
 
-const createTableQuery = `
-CREATE TABLE IF NOT EXISTS wallets
-(
-    wallet_id serial primary key
-);
-
-CREATE TABLE IF NOT EXISTS tickers
-(
-    ticker_id   serial primary key,
-    name varchar(255) NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS balances
-(
-    wallet_id integer references wallets (wallet_id),
-    ticker_id integer references tickers (ticker_id),
-    amount    double precision CHECK (amount >= 0) NOT NULL,
-    PRIMARY KEY (wallet_id, ticker_id)
-);
-
-CREATE TABLE IF NOT EXISTS transactions
-(
-    id        serial primary key,
-    wallet_id integer references wallets (wallet_id),
-    ticker_id integer references tickers (ticker_id),
-    amount    double precision NOT NULL,
-    status    integer NOT NULL,
-    CONSTRAINT valid_status CHECK (0 <= status AND status <= 2)
-);`
-
 func NewPostgresRepo(cfg *Config) (*PostgresRepo, error) {
 	// postgresql://<username>:<password>@<hostname>:<port>/<dbname>
 	db, err := sql.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
@@ -58,347 +49,1004 @@ func NewPostgresRepo(cfg *Config) (*PostgresRepo, error) {
 		return nil, err
 	}
 
-	// driver, err := postgres.WithInstance(db, &postgres.Config{})
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// m, err := migrate.NewWithDatabaseInstance(
-	// 	"file://migrations",
-	// 	cfg.DBName, driver)
-	// if err != nil {
-	// 	return nil, err
-	// }
-
-	// err = m.Up()
-	// if err != nil {
-	// 	return nil, err
-	// }
-
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
 
-	if _, err := db.Exec(createTableQuery); err != nil {
-		return nil, fmt.Errorf("falied to create tables: %v", err)
+	migrator, err := NewMigrator(db, migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.SkipMigrations {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			return nil, fmt.Errorf("falied to migrate schema: %v", err)
+		}
 	}
 
-	return &PostgresRepo{db: db}, nil
+	retry := DefaultRetryPolicy
+	if cfg.Retry != nil {
+		retry = *cfg.Retry
+	}
+
+	return &PostgresRepo{
+		db:          db,
+		migrator:    migrator,
+		tickers:     newTickerCache(cfg.TickerCacheSize, cfg.TickerCacheTTL),
+		retry:       retry,
+		feeWalletID: cfg.FeeWalletID,
+	}, nil
+}
+
+// Migrator возвращает мигратор схемы бд, с которым работает репозиторий
+func (p *PostgresRepo) Migrator() *Migrator {
+	return p.migrator
 }
 
 func (p *PostgresRepo) CreateWallet(ctx context.Context) (*models.Wallet, error) {
-	var walletID int
+	wallet := &models.Wallet{Status: models.WalletStatusActive}
 	if err := p.db.QueryRowContext(ctx,
-		"INSERT INTO wallets DEFAULT VALUES RETURNING wallet_id").Scan(&walletID); err != nil {
+		"INSERT INTO wallets DEFAULT VALUES RETURNING wallet_id, status_changed_at").Scan(&wallet.WalletID, &wallet.StatusChangedAt); err != nil {
 		return nil, err
 	}
 
-	return &models.Wallet{WalletID: walletID}, nil
+	return wallet, nil
 }
 
-func (p *PostgresRepo) CreateTicker(ctx context.Context, ticker string) error {
-	if err := p.db.QueryRowContext(ctx,
-		"INSERT INTO tickers (ticker_id, name) VALUES (default, $1)", ticker).Err(); err != nil {
-		return err
+func rollbackTx(tx *sql.Tx, queryError error) error {
+	if err := tx.Rollback(); err != nil {
+		return fmt.Errorf("transaction rollback error: %v, query error: %v", err, queryError)
 	}
 
-	return nil
+	return queryError
 }
 
-func rollbackTx(tx *sql.Tx, queryError error) error {
+// insufficientFunds ошибка нехватки средств на существующем балансе. Вместе с ней возвращается запись
+// о неудачном списании failed, которую вызывающий сохраняет после отката транзакции операции.
+type insufficientFunds struct {
+	LogicErrors
+	failed *models.Transaction
+}
+
+func (e *insufficientFunds) Unwrap() error {
+	return e.LogicErrors
+}
+
+// rollbackOperation откатывает транзакцию операции после ошибки queryError. Если операции не хватило средств,
+// то после отката создаётся запись о неуспешной транзакции вместе с ошибкой по ключу идемпотентности key,
+// и клиент получает ошибку о причине неудачи.
+func (p *PostgresRepo) rollbackOperation(ctx context.Context, tx *sql.Tx, queryError error, key, operation string) error {
+	var insufficient *insufficientFunds
+	if !errors.As(queryError, &insufficient) {
+		return rollbackTx(tx, queryError)
+	}
+
+	// сначала отменяем транзакцию
 	if err := tx.Rollback(); err != nil {
 		return fmt.Errorf("transaction rollback error: %v, query error: %v", err, queryError)
 	}
 
-	return queryError
+	// теперь создаём запись о неуспешной транзакции. Если ключ уже сохранил параллельно обработанный дубликат запроса,
+	// то запись не создаётся, повтор запроса получит сохранённый ответ
+	if err := p.createFailedTransaction(ctx, insufficient.failed, key, operation, insufficient.LogicErrors); err != nil && !isUniqueViolation(err) {
+		return err
+	}
+
+	// после чего возвращаем ошибку о причине неудавшейся транзакции
+	return insufficient.LogicErrors
 }
 
 func (p *PostgresRepo) createTransaction(ctx context.Context, tx *sql.Tx, transaction *models.Transaction) error {
+	if transaction.LinkedTransactionID != 0 {
+		if err := checkLinkedTransaction(ctx, tx, transaction.LinkedTransactionID); err != nil {
+			return err
+		}
+	}
+
 	// создаём запись в таблице transactions
 	if err := tx.QueryRowContext(ctx,
-		"INSERT INTO transactions (id, wallet_id, ticker_id, amount, status) VALUES (default, $1, $2, $3, $4) RETURNING id",
-		transaction.WalletID, transaction.TickerID, transaction.Amount, int(transaction.Status)).Scan(&transaction.ID); err != nil {
+		"INSERT INTO transactions (id, wallet_id, ticker_id, amount, status, linked_transaction_id, operation) VALUES (default, $1, $2, $3, $4, $5, $6) RETURNING id",
+		transaction.WalletID, transaction.TickerID, transaction.Amount, int(transaction.Status),
+		nullableID(transaction.LinkedTransactionID), transaction.Operation).Scan(&transaction.ID); err != nil {
 		return err
 	}
 
-	return nil
+	return p.recordTransactionEvents(ctx, tx, transaction, true)
 }
 
 func (p *PostgresRepo) updateTransactionStatus(ctx context.Context, tx *sql.Tx, transaction *models.Transaction) error {
-	// меняем запись в таблице transactions
-	if _, err := tx.ExecContext(ctx,
-		"UPDATE transactions SET status = $1 where id = $2",
-		int(transaction.Status), transaction.ID); err != nil {
+	// меняем запись в таблице transactions, для события остальные поля берутся из самой записи
+	stored := &models.Transaction{ID: transaction.ID, Status: transaction.Status}
+	if err := tx.QueryRowContext(ctx,
+		"UPDATE transactions SET status = $1, updated_at = now() where id = $2 RETURNING wallet_id, ticker_id, amount, COALESCE(linked_transaction_id, 0), operation",
+		int(transaction.Status), transaction.ID).Scan(
+		&stored.WalletID, &stored.TickerID, &stored.Amount, &stored.LinkedTransactionID, &stored.Operation); err != nil {
 		return err
 	}
 
-	return nil
+	return p.recordTransactionEvents(ctx, tx, stored, false)
 }
 
-func (p *PostgresRepo) checkWalletAndTicker(ctx context.Context, walletID int, ticker string) (int, error) {
-	readTx, err := p.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+// linkTransaction связывает транзакцию с другой транзакцией той же операции
+func (p *PostgresRepo) linkTransaction(ctx context.Context, tx *sql.Tx, transaction *models.Transaction, linkedID int) error {
+	if err := checkLinkedTransaction(ctx, tx, linkedID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE transactions SET linked_transaction_id = $1 where id = $2", linkedID, transaction.ID)
 	if err != nil {
-		return 0, err
+		return err
 	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return fmt.Errorf("can't link transaction %d: it doesn't exist", transaction.ID)
+	}
+	transaction.LinkedTransactionID = linkedID
 
-	// проверяем то что нужный кошелёк существует
-	var wID int
-	if err := readTx.QueryRowContext(ctx,
-		"SELECT wallet_id FROM wallets WHERE wallet_id = $1", walletID).Scan(&wID); err != nil {
-		if err == sql.ErrNoRows {
-			return 0, rollbackTx(readTx, WalletDoesntExist(walletID))
-		}
+	return nil
+}
+
+/*
+checkLinkedTransaction проверяет, что связываемая транзакция linkedID существует.
 
-		return 0, rollbackTx(readTx, err)
+Внешний ключ на секционированную transactions должен включать ключ секционирования created_at, а транзакция
+со временем переносится в transactions_archive, поэтому linked_transaction_id и postings.transaction_id бд
+не проверяет. Ссылки на кошельки и тикеры проверяются внешними ключами transactions и transactions_archive.
+Транзакции не удаляются, а только переносятся в архив с тем же id, поэтому достаточно проверки при записи ссылки.
+Проводки создаются только для транзакций, записанных в той же транзакции бд, и отдельно не проверяются.
+*/
+func checkLinkedTransaction(ctx context.Context, tx *sql.Tx, linkedID int) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM all_transactions WHERE id = $1)", linkedID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("linked transaction %d doesn't exist", linkedID)
 	}
 
-	// проверяем что тикер существует
-	var tickerID int
-	if err := readTx.QueryRowContext(ctx,
-		"SELECT ticker_id FROM tickers WHERE name = $1", ticker).Scan(&tickerID); err != nil {
-		if err == sql.ErrNoRows {
-			return 0, rollbackTx(readTx, TickerDoesntExist(ticker))
-		}
+	return nil
+}
+
+// nullableID возвращает NULL для незаполненного id
+func nullableID(id int) any {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
 
-		return 0, rollbackTx(readTx, err)
+// checkAmountScale проверяет что в сумме не больше знаков после запятой, чем допускает тикер.
+// Суммы из запросов не округляются, чтобы клиент точно знал сколько средств будет зачислено или списано.
+func checkAmountScale(amount models.Money, ticker *models.Ticker) error {
+	if !amount.FitsScale(ticker.Scale) {
+		return AmountPrecisionExceeded(ticker.Name, ticker.Scale)
 	}
 
-	if err := readTx.Commit(); err != nil {
-		return 0, err
+	return nil
+}
+
+// checkTickerEnabled проверяет что операции по тикеру не отключены
+func checkTickerEnabled(ticker *models.Ticker) error {
+	if !ticker.Enabled {
+		return TickerDisabled(ticker.Name)
 	}
 
-	return tickerID, nil
+	return nil
+}
+
+// checkOperationAmount проверяет сумму операции по настройкам тикера: тикер включён,
+// точность суммы не больше точности тикера и сумма не выходит за границы MinAmount и MaxAmount
+func checkOperationAmount(amount models.Money, ticker *models.Ticker) error {
+	if err := checkTickerEnabled(ticker); err != nil {
+		return err
+	}
+	if err := checkAmountScale(amount, ticker); err != nil {
+		return err
+	}
+	if ticker.MinAmount != nil && amount.Cmp(*ticker.MinAmount) < 0 || ticker.MaxAmount != nil && amount.Cmp(*ticker.MaxAmount) > 0 {
+		return AmountOutOfRange(ticker.Name, ticker.MinAmount, ticker.MaxAmount)
+	}
+
+	return nil
+}
+
+// checkExchangeAmount проверяет зачисляемую при обмене сумму: она положительна после округления,
+// не точнее тикера зачисления to и помещается в колонки numeric(30, 8)
+func checkExchangeAmount(amount models.Money, from, to *models.Ticker) error {
+	if !amount.IsPositive() {
+		return ExchangeAmountTooSmall(from.Name, to.Name)
+	}
+	if err := checkAmountScale(amount, to); err != nil {
+		return err
+	}
+	if !amount.InRange() {
+		return ExchangeAmountTooLarge(from.Name, to.Name)
+	}
+
+	return nil
+}
+
+// balanceKey строка таблицы balances
+type balanceKey struct {
+	walletID int
+	tickerID int
 }
 
 /*
-1) Проверяем что существует кошелёк и тикер из операции, если нет, то сразу возвращаем ошибку
+lockBalances блокирует строки балансов до конца транзакции и возвращает их значения.
+Строки блокируются в порядке (wallet_id, ticker_id), чтобы операции над одними и теми же балансами
+во встречном порядке (перевод A -> B и B -> A) не приводили к взаимоблокировке.
+Перед блокировкой баланса кошелька комиссий на него переносятся накопленные зачисления из fee_credits,
+чтобы списание видело полный баланс. Отсутствующих строк в результате нет.
+*/
+func (p *PostgresRepo) lockBalances(ctx context.Context, tx *sql.Tx, keys ...balanceKey) (map[balanceKey]models.Money, error) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].walletID != keys[j].walletID {
+			return keys[i].walletID < keys[j].walletID
+		}
+		return keys[i].tickerID < keys[j].tickerID
+	})
+
+	balances := make(map[balanceKey]models.Money, len(keys))
+	for _, key := range keys {
+		if p.feeWalletID != 0 && key.walletID == p.feeWalletID {
+			if err := applyFeeCredits(ctx, tx, key); err != nil {
+				return nil, err
+			}
+		}
+
+		var amount models.Money
+		if err := tx.QueryRowContext(ctx,
+			"SELECT amount FROM balances WHERE wallet_id = $1 AND ticker_id = $2 FOR UPDATE", key.walletID, key.tickerID).Scan(&amount); err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+
+			return nil, err
+		}
+		balances[key] = amount
+	}
+
+	return balances, nil
+}
 
-2) Открываем транзакцию
+/*
+1) Проверяем что существует тикер из операции и сумма допустима для него, если нет, то сразу возвращаем ошибку
+
+2) Открываем транзакцию и проверяем что кошелёк существует и с ним разрешены операции
 
 3) Создаём запись в таблице транзакций со статусом models.TransactionStatusCreated
 
-4) Изменяем баланс на кошельке по нужному тикеру
+ 4. Считаем комиссию за зачисление. Если она есть, то создаём связанную запись о списании комиссии с кошелька
+    и запись о её зачислении на кошелёк комиссий
+
+5) Изменяем баланс на кошельке по нужному тикеру на сумму зачисления за вычетом комиссии
 
-5) Меняем статус транзакции на успешный
+6) Меняем статус транзакций на успешный
 
-6) Подтверждаем транзакцию
+7) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
+
+Если запрос с таким ключом идемпотентности уже был обработан, то возвращаем сохранённый ответ без повторного зачисления.
 
 В данной реализации никогда не возникнет ситуации с записью в таблице транзакций со статусом отличным от models.TransactionStatusSuccess.
 Потому что все записи делаются в рамках одной транзакции.
 */
-func (p *PostgresRepo) Invoice(ctx context.Context, req *models.InvoiceRequest) error {
-	tickerID, err := p.checkWalletAndTicker(ctx, req.WalletID, req.Ticker)
-	if err != nil {
-		return err
+func (p *PostgresRepo) invoice(ctx context.Context, req *models.InvoiceRequest) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpInvoice, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
 	}
 
 	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	resp, err = p.applyInvoice(ctx, tx, req)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	// сохраняем ответ, чтобы при повторной доставке сообщения не зачислить средства дважды
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpInvoice, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpInvoice, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	return resp, nil
+}
+
+// applyInvoice выполняет зачисление в открытой транзакции tx, откат транзакции при ошибке делает вызывающий
+func (p *PostgresRepo) applyInvoice(ctx context.Context, tx *sql.Tx, req *models.InvoiceRequest) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+
+	// проверяем то что нужный кошелёк существует и с ним разрешены операции
+	if err := checkWallet(ctx, tx, req.WalletID); err != nil {
+		return nil, err
 	}
 
 	// создаём запись в таблице transactions
 	transaction := &models.Transaction{
-		WalletID: req.WalletID,
-		TickerID: tickerID,
-		Amount:   req.Amount,
-		Status:   models.TransactionStatusCreated,
+		WalletID:  req.WalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount,
+		Status:    models.TransactionStatusCreated,
+		Operation: models.OperationInvoice,
 	}
 	if err := p.createTransaction(ctx, tx, transaction); err != nil {
-		return rollbackTx(tx, err)
+		return nil, err
+	}
+
+	// считаем комиссию, она удерживается из зачисляемой суммы
+	fee, err := p.calculateFee(ctx, tx, models.OperationInvoice, ticker, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if fee.Cmp(req.Amount) >= 0 {
+		return nil, FeeExceedsAmount(req.Ticker, fee)
+	}
+	updated := []*models.Transaction{transaction}
+	// средства поступают с внешнего счёта, комиссия переходит с кошелька на кошелёк комиссий
+	entry := (&models.JournalEntry{Operation: models.OperationInvoice}).
+		SystemPosting(models.AccountCashIn, ticker.TickerID, req.Amount.Neg()).
+		WalletPosting(transaction, req.Amount)
+	if fee.IsPositive() {
+		feeTransaction, err := p.createFeeTransaction(ctx, tx, transaction, fee)
+		if err != nil {
+			return nil, err
+		}
+		feeCredit, err := p.creditFeeWallet(ctx, tx, feeTransaction)
+		if err != nil {
+			return nil, err
+		}
+		entry.WalletPosting(feeTransaction, fee.Neg()).WalletPosting(feeCredit, fee)
+		updated = append(updated, feeTransaction)
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
 	}
 
 	// добавляем запись в таблицу balance
 	if _, err := tx.ExecContext(ctx,
 		"INSERT INTO balances (wallet_id, ticker_id, amount) VALUES ($1, $2, $3) ON CONFLICT (wallet_id, ticker_id) DO UPDATE SET amount = balances.amount + $3",
-		req.WalletID, tickerID, req.Amount); err != nil {
-		return rollbackTx(tx, err)
+		req.WalletID, ticker.TickerID, req.Amount.Sub(fee)); err != nil {
+		return nil, err
 	}
 
-	// меняем статус транзакции на успешный
-	transaction.Status = models.TransactionStatusSuccess
-	if err := p.updateTransactionStatus(ctx, tx, transaction); err != nil {
-		return rollbackTx(tx, err)
+	// меняем статус транзакций на успешный
+	for _, t := range updated {
+		t.Status = models.TransactionStatusSuccess
+		if err := p.updateTransactionStatus(ctx, tx, t); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return rollbackTx(tx, err)
+	if err := p.postEntry(ctx, tx, entry); err != nil {
+		return nil, err
 	}
 
-	return nil
+	resp.TransactionID = transaction.ID
+
+	return resp, nil
+}
+
+func (p *PostgresRepo) Invoice(ctx context.Context, req *models.InvoiceRequest) (*models.OperationResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.OperationResponse, error) {
+		return p.invoice(ctx, req)
+	})
+	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpInvoice)
+	}
+
+	return resp, nil
 }
 
 /*
-1) Проверяем что существует кошелёк и тикер из операции, если нет, то сразу возвращаем ошибку
+1) Проверяем что существует тикер из операции и сумма допустима для него, если нет, то сразу возвращаем ошибку
 
-2) Открываем транзакцию
+2) Открываем транзакцию, проверяем кошелёк и ограничения на списания, при их превышении возвращаем ошибку
 
- 3. Проверяем баланс на кошельке по данному тикеру. И в случае если его недостаточно для списания, то отменяем транзакцию,
-    создаём запись о неудачной транзакции по списанию средств и возвращаем ошибку
+ 3. Считаем комиссию за списание и проверяем баланс на кошельке по данному тикеру. И в случае если его недостаточно
+    для списания вместе с комиссией, то отменяем транзакцию, создаём запись о неудачной транзакции по списанию средств
+    и возвращаем ошибку
 
-4) Если баланса хватает для списания, то создаём запись в таблице транзакций со статусом models.TransactionStatusCreated
+ 4. Если баланса хватает для списания, то создаём запись в таблице транзакций со статусом models.TransactionStatusCreated
+    и связанную с ней запись о списании комиссии в том же статусе
 
-4) Изменяем баланс на кошельке по нужному тикеру
+5) Уменьшаем актуальный баланс на кошельке по нужному тикеру на сумму списания и комиссии, средства переходят в замороженный баланс
 
-5) Меняем статус транзакции на успешный
+6) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
 
-6) Подтверждаем транзакцию
+Транзакция остаётся в статусе models.TransactionStatusCreated до вызова Capture или Release.
 */
-func (p *PostgresRepo) WithDraw(ctx context.Context, req *models.WithdrawRequest) error {
-	tickerID, err := p.checkWalletAndTicker(ctx, req.WalletID, req.Ticker)
-	if err != nil {
-		return err
+func (p *PostgresRepo) withDraw(ctx context.Context, req *models.WithdrawRequest) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpWithdraw, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
 	}
 
 	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// проверяем баланс на кошельке
-	var balance float32
-	if err := tx.QueryRowContext(ctx,
-		"SELECT amount FROM balances WHERE wallet_id = $1 AND ticker_id = $2", req.WalletID, tickerID).Scan(&balance); err != nil {
-		if err == sql.ErrNoRows {
-			return rollbackTx(tx, NotEnoughCoins(req.WalletID, req.Ticker))
-		}
-		return rollbackTx(tx, err)
+	resp, err = p.applyWithdraw(ctx, tx, req)
+	if err != nil {
+		return nil, p.rollbackOperation(ctx, tx, err, req.IdempotencyKey, idempotencyOpWithdraw)
 	}
-	// случай когда на счету недостаточно денег
-	if balance < req.Amount {
-		queryError := NotEnoughCoins(req.WalletID, req.Ticker)
-		// сначала отменяем транзакцию
-		if err := tx.Rollback(); err != nil {
-			return fmt.Errorf("transaction rollback error: %v, query error: %v", err, queryError)
-		}
 
-		// Теперь создаём запись о неуспешной транзакции
-		if _, err := p.db.ExecContext(ctx,
-			"INSERT INTO transactions (id, wallet_id, ticker_id, amount, status) VALUES (default, $1, $2, $3, $4)",
-			req.WalletID, tickerID, -req.Amount, models.TransactionStatusError); err != nil {
-			return err
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpWithdraw, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpWithdraw, resp); err != nil {
+			return nil, err
 		}
+		return resp, nil
+	}
 
-		// после чего возвращаем ошибку о причине неудавшейся транзакции
-		return queryError
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// applyWithdraw замораживает списание в открытой транзакции tx. При нехватке средств возвращает *insufficientFunds.
+func (p *PostgresRepo) applyWithdraw(ctx context.Context, tx *sql.Tx, req *models.WithdrawRequest) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+
+	// проверяем то что нужный кошелёк существует и с ним разрешены операции
+	if err := checkWallet(ctx, tx, req.WalletID); err != nil {
+		return nil, err
+	}
+
+	// проверяем ограничения на списания
+	if err := p.checkWithdrawalLimits(ctx, tx, req.WalletID, ticker, req.Amount); err != nil {
+		return nil, err
+	}
+
+	// комиссия списывается сверх запрошенной суммы, ограничения на списания проверяются без неё
+	fee, err := p.calculateFee(ctx, tx, models.OperationWithdraw, ticker, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	// блокируем и проверяем баланс на кошельке, параллельные списания с него ждут завершения транзакции
+	key := balanceKey{walletID: req.WalletID, tickerID: ticker.TickerID}
+	balances, err := p.lockBalances(ctx, tx, key)
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := balances[key]
+	if !ok {
+		return nil, NotEnoughCoins(req.WalletID, req.Ticker)
+	}
+	// случай когда на счету недостаточно денег
+	if balance.Cmp(req.Amount.Add(fee)) < 0 {
+		return nil, &insufficientFunds{
+			LogicErrors: NotEnoughCoins(req.WalletID, req.Ticker),
+			failed: &models.Transaction{
+				WalletID:  req.WalletID,
+				TickerID:  ticker.TickerID,
+				Amount:    req.Amount.Neg(),
+				Operation: models.OperationWithdraw,
+			},
+		}
 	}
 
 	// создаём запись в таблице transactions
 	transaction := &models.Transaction{
-		WalletID: req.WalletID,
-		TickerID: tickerID,
-		Amount:   -req.Amount,
-		Status:   models.TransactionStatusCreated,
+		WalletID:  req.WalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount.Neg(),
+		Status:    models.TransactionStatusCreated,
+		Operation: models.OperationWithdraw,
 	}
 	if err := p.createTransaction(ctx, tx, transaction); err != nil {
-		return rollbackTx(tx, err)
+		return nil, err
+	}
+	// списание и комиссия переходят с кошелька на счёт замороженных средств
+	entry := (&models.JournalEntry{Operation: models.OperationWithdraw}).
+		WalletPosting(transaction, req.Amount.Neg()).
+		SystemPosting(models.AccountHolds, ticker.TickerID, req.Amount)
+	if fee.IsPositive() {
+		feeTransaction, err := p.createFeeTransaction(ctx, tx, transaction, fee)
+		if err != nil {
+			return nil, err
+		}
+		entry.WalletPosting(feeTransaction, fee.Neg()).SystemPosting(models.AccountHolds, ticker.TickerID, fee)
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
 	}
 
-	// устанавливаем новый баланс
+	// устанавливаем новый баланс, списанная сумма и комиссия остаются замороженными до подтверждения
 	if _, err := tx.ExecContext(ctx,
-		"UPDATE balances SET amount = amount - $1 WHERE wallet_id = $2 AND ticker_id = $3", req.Amount, req.WalletID, tickerID); err != nil {
-		return rollbackTx(tx, err)
+		"UPDATE balances SET amount = amount - $1 WHERE wallet_id = $2 AND ticker_id = $3", req.Amount.Add(fee), req.WalletID, ticker.TickerID); err != nil {
+		return nil, err
+	}
+
+	if err := p.postEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	resp.TransactionID = transaction.ID
+
+	return resp, nil
+}
+
+func (p *PostgresRepo) WithDraw(ctx context.Context, req *models.WithdrawRequest) (*models.OperationResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.OperationResponse, error) {
+		return p.withDraw(ctx, req)
+	})
+	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpWithdraw)
+	}
+
+	return resp, nil
+}
+
+// getHeldTransaction блокирует строку транзакции и проверяет что она является незавершённым списанием
+func (p *PostgresRepo) getHeldTransaction(ctx context.Context, tx *sql.Tx, transactionID int) (*models.Transaction, error) {
+	transaction := &models.Transaction{ID: transactionID}
+	var status int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT wallet_id, ticker_id, amount, status, operation FROM transactions WHERE id = $1 FOR UPDATE", transactionID).Scan(
+		&transaction.WalletID, &transaction.TickerID, &transaction.Amount, &status, &transaction.Operation); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, TransactionDoesntExist(transactionID)
+		}
+
+		return nil, err
+	}
+	transaction.Status = models.TransactionStatus(status)
+
+	// заморожены могут быть только списания, которые ещё не перешли в финальный статус.
+	// Комиссия подтверждается и отменяется только вместе со своим списанием.
+	if transaction.Status != models.TransactionStatusCreated || transaction.Amount.Sign() >= 0 || transaction.Operation == models.OperationFee {
+		return nil, TransactionNotHeld(transactionID)
+	}
+
+	return transaction, nil
+}
+
+/*
+1) Открываем транзакцию и блокируем запись о списании
+
+2) Проверяем что списание находится в статусе models.TransactionStatusCreated
+
+3) Меняем статус на models.TransactionStatusSuccess, замороженные средства окончательно списываются
+
+4) Если к списанию привязана комиссия, то подтверждаем её и зачисляем на кошелёк комиссий
+
+5) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
+*/
+func (p *PostgresRepo) capture(ctx context.Context, req *models.HoldRequest) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpCapture, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := p.getHeldTransaction(ctx, tx, req.TransactionID)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
 	}
 
-	// меняем статус записи в таблице transactions
 	transaction.Status = models.TransactionStatusSuccess
 	if err := p.updateTransactionStatus(ctx, tx, transaction); err != nil {
-		return rollbackTx(tx, err)
+		return nil, rollbackTx(tx, err)
+	}
+
+	// замороженные средства уходят на внешний счёт, замороженная комиссия на кошелёк комиссий
+	entry := (&models.JournalEntry{Operation: models.OperationWithdraw}).
+		SystemPosting(models.AccountHolds, transaction.TickerID, transaction.Amount).
+		SystemPosting(models.AccountCashOut, transaction.TickerID, transaction.Amount.Neg())
+
+	feeTransaction, err := p.getHeldFee(ctx, tx, transaction)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	if feeTransaction != nil {
+		feeTransaction.Status = models.TransactionStatusSuccess
+		if err := p.updateTransactionStatus(ctx, tx, feeTransaction); err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+		feeCredit, err := p.creditFeeWallet(ctx, tx, feeTransaction)
+		if err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+		fee := feeTransaction.Amount.Neg()
+		entry.SystemPosting(models.AccountHolds, transaction.TickerID, feeTransaction.Amount).WalletPosting(feeCredit, fee)
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
+	}
+
+	if err := p.postEntry(ctx, tx, entry); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	resp.TransactionID = transaction.ID
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpCapture, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpCapture, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
 	}
 
 	if err := tx.Commit(); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return resp, nil
+}
+
+func (p *PostgresRepo) Capture(ctx context.Context, req *models.HoldRequest) (*models.OperationResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.OperationResponse, error) {
+		return p.capture(ctx, req)
+	})
+	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpCapture)
+	}
+
+	return resp, nil
 }
 
 /*
-1) Проверяем то что нужный кошелёк существует
+1) Открываем транзакцию и блокируем запись о списании
 
-2) Получаем актуальный баланс кошелька из таблицы balances
+2) Проверяем что списание находится в статусе models.TransactionStatusCreated
 
-3) Получаем список замороженных транзакцией из таблицы transactions (со статусом models.TransactionStatusCreated)
+3) Возвращаем замороженную сумму вместе с привязанной к списанию комиссией в актуальный баланс кошелька
 
-4) Формируем вывод из ответа бд
+4) Меняем статус списания и комиссии на models.TransactionStatusError
+
+5) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
 */
-func (p *PostgresRepo) GetBalance(ctx context.Context, req *models.GetBalanceRequest) (*models.GetBalanceResponse, error) {
-	// проверяем то что нужный кошелёк существует
-	var wID int
-	if err := p.db.QueryRowContext(ctx, "SELECT wallet_id FROM wallets WHERE wallet_id = $1", req.WalletID).Scan(&wID); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, WalletDoesntExist(req.WalletID)
-		}
+func (p *PostgresRepo) release(ctx context.Context, req *models.HoldRequest) (*models.OperationResponse, error) {
+	resp := &models.OperationResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpRelease, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
 
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
 		return nil, err
 	}
 
-	// получаем актуальный баланс кошелька из таблицы balances
-	balanceRows, err := p.db.QueryContext(ctx, "SELECT ticker_id, amount FROM balances WHERE wallet_id = $1", req.WalletID)
+	transaction, err := p.getHeldTransaction(ctx, tx, req.TransactionID)
 	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	feeTransaction, err := p.getHeldFee(ctx, tx, transaction)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	released := []*models.Transaction{transaction}
+	refund := transaction.Amount
+	// замороженные средства возвращаются со счёта замороженных средств на кошелёк
+	entry := &models.JournalEntry{Operation: models.OperationWithdraw}
+	for _, t := range []*models.Transaction{transaction, feeTransaction} {
+		if t != nil {
+			entry.SystemPosting(models.AccountHolds, t.TickerID, t.Amount).WalletPosting(t, t.Amount.Neg())
+		}
+	}
+	if feeTransaction != nil {
+		released = append(released, feeTransaction)
+		refund = refund.Add(feeTransaction.Amount)
+		fee := feeTransaction.Amount.Neg()
+		resp.Fee = &fee
+		resp.FeeTransactionID = feeTransaction.ID
+	}
+
+	// суммы списания и комиссии хранятся с минусом, поэтому вычитаем их
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE balances SET amount = amount - $1 WHERE wallet_id = $2 AND ticker_id = $3",
+		refund, transaction.WalletID, transaction.TickerID); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	for _, t := range released {
+		t.Status = models.TransactionStatusError
+		if err := p.updateTransactionStatus(ctx, tx, t); err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+	}
+
+	if err := p.postEntry(ctx, tx, entry); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	resp.TransactionID = transaction.ID
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpRelease, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpRelease, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	defer balanceRows.Close()
 
-	actualBalance, err := p.readRows(ctx, balanceRows)
+	return resp, nil
+}
+
+func (p *PostgresRepo) Release(ctx context.Context, req *models.HoldRequest) (*models.OperationResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.OperationResponse, error) {
+		return p.release(ctx, req)
+	})
 	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpRelease)
+	}
+
+	return resp, nil
+}
+
+/*
+1) Проверяем что существует тикер из операции и сумма допустима для него, если нет, то сразу возвращаем ошибку
+
+2) Открываем транзакцию и проверяем что оба кошелька существуют и с ними разрешены операции
+
+ 3. Проверяем баланс на кошельке отправителя. Если его недостаточно, то отменяем транзакцию,
+    создаём запись о неудачной транзакции по списанию средств и возвращаем ошибку
+
+4) Создаём записи в таблице транзакций для списания и зачисления со статусом models.TransactionStatusCreated
+
+5) Изменяем балансы обоих кошельков
+
+6) Меняем статусы транзакций на успешные
+
+7) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
+
+Списание и зачисление делаются в рамках одной транзакции, поэтому средства не могут пропасть между кошельками.
+*/
+func (p *PostgresRepo) transfer(ctx context.Context, req *models.TransferRequest) (*models.TransferResponse, error) {
+	resp := &models.TransferResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpTransfer, resp); err != nil {
 		return nil, err
+	} else if found {
+		return resp, nil
 	}
 
-	// получаем список замороженных транзакцией из таблицы transactions
-	frozenRows, err := p.db.QueryContext(ctx,
-		"SELECT ticker_id, amount FROM transactions WHERE wallet_id = $1 AND status = $2", req.WalletID, models.TransactionStatusCreated)
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
 	if err != nil {
 		return nil, err
 	}
-	defer frozenRows.Close()
 
-	// Теперь заполняем замороженные средства
-	frozenBalance, err := p.readRows(ctx, frozenRows)
+	resp, err = p.applyTransfer(ctx, tx, req)
 	if err != nil {
+		return nil, p.rollbackOperation(ctx, tx, err, req.IdempotencyKey, idempotencyOpTransfer)
+	}
+
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpTransfer, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpTransfer, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return &models.GetBalanceResponse{
-		ActualBalance: actualBalance,
-		FrozenBalance: frozenBalance,
-	}, nil
+	return resp, nil
 }
 
-func (p *PostgresRepo) readRows(ctx context.Context, rows *sql.Rows) (map[string]float32, error) {
-	balance := make(map[string]float32)
-	for rows.Next() {
-		var tickerID int
-		var amount float32
-		if err := rows.Scan(&tickerID, &amount); err != nil {
+// applyTransfer выполняет перевод в открытой транзакции tx. При нехватке средств возвращает *insufficientFunds.
+func (p *PostgresRepo) applyTransfer(ctx context.Context, tx *sql.Tx, req *models.TransferRequest) (*models.TransferResponse, error) {
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOperationAmount(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+
+	// проверяем то что оба кошелька существуют и с ними разрешены операции
+	for _, walletID := range []int{req.FromWalletID, req.ToWalletID} {
+		if err := checkWallet(ctx, tx, walletID); err != nil {
 			return nil, err
 		}
+	}
+
+	// блокируем балансы обоих кошельков и проверяем баланс на кошельке отправителя
+	from := balanceKey{walletID: req.FromWalletID, tickerID: ticker.TickerID}
+	balances, err := p.lockBalances(ctx, tx, from, balanceKey{walletID: req.ToWalletID, tickerID: ticker.TickerID})
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := balances[from]
+	if !ok {
+		return nil, NotEnoughCoins(req.FromWalletID, req.Ticker)
+	}
+	// случай когда на счету недостаточно денег, сохраняется запись о неуспешном списании
+	if balance.Cmp(req.Amount) < 0 {
+		return nil, &insufficientFunds{
+			LogicErrors: NotEnoughCoins(req.FromWalletID, req.Ticker),
+			failed: &models.Transaction{
+				WalletID:  req.FromWalletID,
+				TickerID:  ticker.TickerID,
+				Amount:    req.Amount.Neg(),
+				Operation: models.OperationTransfer,
+			},
+		}
+	}
 
-		// Представим что тикеров много и мы не может сохранить их все в словарь в оперативной памяти чтобы сконвертировать ID в название
-		if ticker, err := p.getTickerName(ctx, tickerID); err != nil {
+	// создаём записи в таблице transactions
+	debit := &models.Transaction{
+		WalletID:  req.FromWalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount.Neg(),
+		Status:    models.TransactionStatusCreated,
+		Operation: models.OperationTransfer,
+	}
+	credit := &models.Transaction{
+		WalletID:  req.ToWalletID,
+		TickerID:  ticker.TickerID,
+		Amount:    req.Amount,
+		Status:    models.TransactionStatusCreated,
+		Operation: models.OperationTransfer,
+	}
+	for _, transaction := range []*models.Transaction{debit, credit} {
+		if err := p.createTransaction(ctx, tx, transaction); err != nil {
 			return nil, err
-		} else {
-			if v, ok := balance[ticker]; ok {
-				balance[ticker] = v + amount
-			} else {
-				balance[ticker] = amount
-			}
 		}
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error encountered while iterating over balance rows: %s", err)
+	// списываем средства с кошелька отправителя
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE balances SET amount = amount - $1 WHERE wallet_id = $2 AND ticker_id = $3", req.Amount, req.FromWalletID, ticker.TickerID); err != nil {
+		return nil, err
 	}
 
-	return balance, nil
+	// зачисляем средства на кошелёк получателя
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO balances (wallet_id, ticker_id, amount) VALUES ($1, $2, $3) ON CONFLICT (wallet_id, ticker_id) DO UPDATE SET amount = balances.amount + $3",
+		req.ToWalletID, ticker.TickerID, req.Amount); err != nil {
+		return nil, err
+	}
+
+	// меняем статусы транзакций на успешные
+	for _, transaction := range []*models.Transaction{debit, credit} {
+		transaction.Status = models.TransactionStatusSuccess
+		if err := p.updateTransactionStatus(ctx, tx, transaction); err != nil {
+			return nil, err
+		}
+	}
+
+	// перевод проводится только по счетам кошельков
+	entry := (&models.JournalEntry{Operation: models.OperationTransfer}).
+		WalletPosting(debit, debit.Amount).
+		WalletPosting(credit, credit.Amount)
+	if err := p.postEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	return &models.TransferResponse{DebitTransactionID: debit.ID, CreditTransactionID: credit.ID}, nil
+}
+
+func (p *PostgresRepo) Transfer(ctx context.Context, req *models.TransferRequest) (*models.TransferResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.TransferResponse, error) {
+		return p.transfer(ctx, req)
+	})
+	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpTransfer)
+	}
+
+	return resp, nil
+}
+
+// balanceQuery актуальный баланс кошелька из current_balances и сумма замороженных списаний по каждому тикеру.
+// Тикер есть в актуальном балансе, если для него есть запись в balances или зачисление комиссии, и в замороженном, если есть незавершённые списания.
+const balanceQuery = `
+SELECT t.name, t.scale, b.amount, f.amount
+FROM (SELECT ticker_id, amount FROM current_balances WHERE wallet_id = $1) b
+         FULL JOIN (SELECT ticker_id, -SUM(amount) AS amount
+                    FROM transactions
+                    WHERE wallet_id = $1
+                      AND status = $2
+                    GROUP BY ticker_id) f ON f.ticker_id = b.ticker_id
+         JOIN tickers t ON t.ticker_id = COALESCE(b.ticker_id, f.ticker_id)`
+
+/*
+1) Проверяем то что нужный кошелёк существует
+
+2) Получаем актуальный баланс кошелька из current_balances, с учётом ещё не перенесённых зачислений комиссий
+
+3) Получаем список замороженных транзакцией из таблицы transactions (со статусом models.TransactionStatusCreated),
+это списания, которые ещё не были подтверждены через Capture или отменены через Release
+
+4) Формируем вывод из ответа бд
+*/
+func (p *PostgresRepo) GetBalance(ctx context.Context, req *models.GetBalanceRequest) (*models.GetBalanceResponse, error) {
+	// проверяем то что нужный кошелёк существует
+	var wID int
+	if err := p.db.QueryRowContext(ctx, "SELECT wallet_id FROM wallets WHERE wallet_id = $1", req.WalletID).Scan(&wID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, WalletDoesntExist(req.WalletID)
+		}
+
+		return nil, err
+	}
+
+	// баланс на момент в прошлом считается по журналу транзакций
+	if req.BalanceAt != nil {
+		return p.getBalanceAt(ctx, req.WalletID, *req.BalanceAt)
+	}
+
+	// получаем актуальный и замороженный баланс одним запросом, суммы списаний хранятся с минусом
+	rows, err := p.db.QueryContext(ctx, balanceQuery, req.WalletID, models.TransactionStatusCreated)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actual, frozen, err := scanBalances(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GetBalanceResponse{ActualBalance: actual, FrozenBalance: frozen}, nil
 }
 
-func (p *PostgresRepo) getTickerName(ctx context.Context, tickerID int) (string, error) {
-	var ticker string
-	if err := p.db.QueryRowContext(ctx, "SELECT name FROM tickers WHERE ticker_id = $1", tickerID).Scan(&ticker); err != nil {
-		return "", err
+// scanBalances читает актуальный и замороженный баланс по тикерам из результата balanceQuery
+func scanBalances(rows *sql.Rows) (map[string]models.Money, map[string]models.Money, error) {
+	actualBalance := make(map[string]models.Money)
+	frozenBalance := make(map[string]models.Money)
+	for rows.Next() {
+		var ticker models.Ticker
+		var actual, frozen *models.Money
+		if err := rows.Scan(&ticker.Name, &ticker.Scale, &actual, &frozen); err != nil {
+			return nil, nil, err
+		}
+
+		// суммы в бд хранятся с точностью MoneyScale, выводим их с точностью тикера
+		if actual != nil {
+			actualBalance[ticker.Name] = actual.Round(ticker.Scale, models.RoundDown)
+		}
+		if frozen != nil {
+			frozenBalance[ticker.Name] = frozen.Round(ticker.Scale, models.RoundDown)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error encountered while iterating over balance rows: %s", err)
 	}
 
-	return ticker, nil
+	return actualBalance, frozenBalance, nil
 }
 
 func (p *PostgresRepo) TruncateBalances(ctx context.Context) error {
@@ -407,7 +1055,7 @@ func (p *PostgresRepo) TruncateBalances(ctx context.Context) error {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, "TRUNCATE balances")
+	_, err = tx.ExecContext(ctx, "TRUNCATE balances, fee_credits")
 	if err != nil {
 		return rollbackTx(tx, err)
 	}
@@ -425,7 +1073,12 @@ func (p *PostgresRepo) TruncateTransactions(ctx context.Context) error {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, "TRUNCATE transactions")
+	// проводки журнала, доставки уведомлений и сохранённые ответы идемпотентных запросов ссылаются на транзакции,
+	// а снимки балансов посчитаны по ним, поэтому всё это очищается вместе с транзакциями. Архив транзакций
+	// и outbox не очищаются: архив хранится для аудита, а события могли быть ещё не опубликованы
+	_, err = tx.ExecContext(ctx, `
+TRUNCATE transactions, postings, journal_entries, webhook_deliveries, idempotency_keys, balance_snapshots,
+    balance_snapshot_items`)
 	if err != nil {
 		return rollbackTx(tx, err)
 	}
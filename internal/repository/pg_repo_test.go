@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestTransactionReferences проверяет ссылки транзакций: кошелёк и тикер проверяет внешний ключ секционированной
+// таблицы, а связанную транзакцию createTransaction и linkTransaction
+func TestTransactionReferences(t *testing.T) {
+	p := newTestPostgresRepo(t)
+	ctx := context.Background()
+	r := &testRepo{Repository: p, pg: p, feeWalletID: p.feeWalletID, suffix: fmt.Sprint(time.Now().UnixNano() % 1e9)}
+	walletID := r.wallet(t)
+	ticker, err := p.getTickerByName(ctx, r.ticker(t, "REF"))
+	if err != nil {
+		t.Fatalf("getTickerByName: %v", err)
+	}
+
+	// каждая проверка выполняется в своей транзакции, которая затем откатывается
+	inTx := func(name string, fn func(tx *sql.Tx) error) error {
+		tx, err := p.db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("%s: BeginTx: %v", name, err)
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		return fn(tx)
+	}
+	transaction := func(walletID, linkedID int) *models.Transaction {
+		return &models.Transaction{WalletID: walletID, TickerID: ticker.TickerID, Amount: models.MustParseMoney("1"),
+			Status: models.TransactionStatusSuccess, Operation: models.OperationInvoice, LinkedTransactionID: linkedID}
+	}
+
+	if err := inTx("missing wallet", func(tx *sql.Tx) error {
+		return p.createTransaction(ctx, tx, transaction(-1, 0))
+	}); err == nil {
+		t.Fatal("transaction of a missing wallet was created")
+	}
+	if err := inTx("missing linked transaction", func(tx *sql.Tx) error {
+		return p.createTransaction(ctx, tx, transaction(walletID, -1))
+	}); err == nil {
+		t.Fatal("transaction linked to a missing transaction was created")
+	}
+	if err := inTx("link to missing transaction", func(tx *sql.Tx) error {
+		created := transaction(walletID, 0)
+		if err := p.createTransaction(ctx, tx, created); err != nil {
+			t.Fatalf("createTransaction: %v", err)
+		}
+		return p.linkTransaction(ctx, tx, created, -1)
+	}); err == nil {
+		t.Fatal("transaction was linked to a missing transaction")
+	}
+
+	if err := inTx("linked transaction", func(tx *sql.Tx) error {
+		first := transaction(walletID, 0)
+		if err := p.createTransaction(ctx, tx, first); err != nil {
+			return err
+		}
+		second := transaction(walletID, first.ID)
+		if err := p.createTransaction(ctx, tx, second); err != nil {
+			return err
+		}
+		return p.linkTransaction(ctx, tx, first, second.ID)
+	}); err != nil {
+		t.Fatalf("linking existing transactions: %v", err)
+	}
+}
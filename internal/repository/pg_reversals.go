@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+)
+
+// isReversible отменить можно только успешное зачисление или подтверждённое списание.
+// Переводы и обмены затрагивают два баланса и отменяются встречной операцией, а комиссии отменяются вместе со своей операцией.
+func isReversible(transaction *models.Transaction) bool {
+	return transaction.Status == models.TransactionStatusSuccess &&
+		(transaction.Operation == models.OperationInvoice || transaction.Operation == models.OperationWithdraw)
+}
+
+// reversalAccount системный счёт, через который прошла исходная операция и через который возвращаются средства
+func reversalAccount(operation string) string {
+	if operation == models.OperationInvoice {
+		return models.AccountCashIn
+	}
+
+	return models.AccountCashOut
+}
+
+// archivedTransactionError возвращает ошибку для транзакции, которой нет в transactions: архивную транзакцию
+// отменить уже нельзя, иначе транзакции не существует
+func archivedTransactionError(ctx context.Context, tx *sql.Tx, transactionID int) error {
+	var archived bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM transactions_archive WHERE id = $1)", transactionID).Scan(&archived); err != nil {
+		return err
+	}
+	if archived {
+		return TransactionNotReversible(transactionID)
+	}
+
+	return TransactionDoesntExist(transactionID)
+}
+
+// getReversibleTransaction блокирует строку исходной транзакции до конца tx, чтобы параллельные отмены
+// одной и той же транзакции выполнялись по очереди и не превысили её сумму
+func getReversibleTransaction(ctx context.Context, tx *sql.Tx, transactionID int) (*models.Transaction, error) {
+	transaction := &models.Transaction{ID: transactionID}
+	var status int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT wallet_id, ticker_id, amount, status, operation FROM transactions WHERE id = $1 FOR UPDATE", transactionID).Scan(
+		&transaction.WalletID, &transaction.TickerID, &transaction.Amount, &status, &transaction.Operation); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, archivedTransactionError(ctx, tx, transactionID)
+		}
+
+		return nil, err
+	}
+	transaction.Status = models.TransactionStatus(status)
+
+	if !isReversible(transaction) {
+		return nil, TransactionNotReversible(transactionID)
+	}
+
+	return transaction, nil
+}
+
+/*
+reversibleRemaining возвращает сумму исходной транзакции, которую ещё можно отменить.
+
+Для зачисления это сумма, которая фактически поступила на кошелёк, то есть за вычетом удержанной комиссии.
+Для списания это списанная сумма без комиссии, комиссия за списание не возвращается.
+Из неё вычитаются суммы уже выполненных отмен.
+*/
+func reversibleRemaining(ctx context.Context, tx *sql.Tx, transaction *models.Transaction) (models.Money, error) {
+	var fee, reversed models.Money
+	if err := tx.QueryRowContext(ctx, `
+SELECT COALESCE(SUM(amount) FILTER (WHERE operation = $2), 0), COALESCE(SUM(ABS(amount)) FILTER (WHERE operation = $3), 0)
+FROM transactions
+WHERE linked_transaction_id = $1 AND status = $4`,
+		transaction.ID, models.OperationFee, models.OperationReversal, models.TransactionStatusSuccess).Scan(&fee, &reversed); err != nil {
+		return models.Money{}, err
+	}
+
+	if transaction.Operation == models.OperationInvoice {
+		// комиссия за зачисление хранится с минусом
+		return transaction.Amount.Add(fee).Sub(reversed), nil
+	}
+
+	return transaction.Amount.Neg().Sub(reversed), nil
+}
+
+/*
+1) Открываем транзакцию и блокируем исходную транзакцию, проверяем что её можно отменить
+
+2) Считаем не отменённый остаток исходной транзакции. Если он нулевой, то транзакция уже отменена полностью,
+а если запрошенная сумма больше остатка, то возвращаем ошибку. Без суммы в запросе отменяется весь остаток.
+
+3) Проверяем что кошелёк не закрыт. Приостановленный кошелёк не мешает отмене, она исправляет уже проведённую операцию.
+
+ 4. Отмена зачисления списывает средства с кошелька. Если баланса недостаточно, то отменяем транзакцию,
+    создаём запись о неудачной отмене и возвращаем ошибку, баланс не может стать отрицательным
+
+5) Создаём успешную компенсирующую транзакцию, связанную с исходной, и меняем баланс на кошельке
+
+6) Сохраняем ответ по ключу идемпотентности и подтверждаем транзакцию
+*/
+func (p *PostgresRepo) reverse(ctx context.Context, req *models.ReverseRequest) (*models.ReverseResponse, error) {
+	resp := &models.ReverseResponse{}
+	if found, err := p.findIdempotentResponse(ctx, req.IdempotencyKey, idempotencyOpReverse, resp); err != nil {
+		return nil, err
+	} else if found {
+		return resp, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := getReversibleTransaction(ctx, tx, req.TransactionID)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	ticker, err := p.getTicker(ctx, original.TickerID)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	remaining, err := reversibleRemaining(ctx, tx, original)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	if !remaining.IsPositive() {
+		return nil, rollbackTx(tx, TransactionAlreadyReversed(original.ID))
+	}
+	amount := remaining
+	if req.Amount != nil {
+		amount = *req.Amount
+		if err := checkAmountScale(amount, ticker); err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+		if amount.Cmp(remaining) > 0 {
+			return nil, rollbackTx(tx, ReversalExceedsAmount(original.ID, ticker.Name, remaining))
+		}
+	}
+
+	var status int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT status FROM wallets WHERE wallet_id = $1 FOR SHARE", original.WalletID).Scan(&status); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	if models.WalletStatus(status) == models.WalletStatusClosed {
+		return nil, rollbackTx(tx, WalletClosed(original.WalletID))
+	}
+
+	// отмена меняет баланс в сторону, противоположную исходной транзакции
+	delta := amount
+	if original.Amount.IsPositive() {
+		delta = amount.Neg()
+
+		key := balanceKey{walletID: original.WalletID, tickerID: original.TickerID}
+		balances, err := p.lockBalances(ctx, tx, key)
+		if err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+		if balance, ok := balances[key]; !ok || balance.Cmp(amount) < 0 {
+			// запись о неуспешной отмене сохраняется вне отменённой транзакции
+			return nil, p.rollbackOperation(ctx, tx, &insufficientFunds{
+				LogicErrors: NotEnoughCoins(original.WalletID, ticker.Name),
+				failed: &models.Transaction{
+					WalletID:            original.WalletID,
+					TickerID:            original.TickerID,
+					Amount:              delta,
+					LinkedTransactionID: original.ID,
+					Operation:           models.OperationReversal,
+				},
+			}, req.IdempotencyKey, idempotencyOpReverse)
+		}
+	}
+
+	transaction := &models.Transaction{
+		WalletID:            original.WalletID,
+		TickerID:            original.TickerID,
+		Amount:              delta,
+		Status:              models.TransactionStatusSuccess,
+		LinkedTransactionID: original.ID,
+		Operation:           models.OperationReversal,
+	}
+	if err := p.createTransaction(ctx, tx, transaction); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO balances (wallet_id, ticker_id, amount) VALUES ($1, $2, $3) ON CONFLICT (wallet_id, ticker_id) DO UPDATE SET amount = balances.amount + $3",
+		original.WalletID, original.TickerID, delta); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	// средства возвращаются через тот же внешний счёт, через который прошла исходная операция
+	entry := (&models.JournalEntry{Operation: models.OperationReversal}).
+		WalletPosting(transaction, delta).
+		SystemPosting(reversalAccount(original.Operation), original.TickerID, delta.Neg())
+	if err := p.postEntry(ctx, tx, entry); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	resp.TransactionID = transaction.ID
+	resp.ReversedTransactionID = original.ID
+	resp.Amount = amount
+	resp.Remaining = remaining.Sub(amount)
+	if err := saveIdempotentResponse(ctx, tx, req.IdempotencyKey, idempotencyOpReverse, resp); err != nil {
+		if err := p.replayIdempotentResponse(ctx, rollbackTx(tx, err), req.IdempotencyKey, idempotencyOpReverse, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (p *PostgresRepo) Reverse(ctx context.Context, req *models.ReverseRequest) (*models.ReverseResponse, error) {
+	resp, err := withRetry(ctx, p.retry, func() (*models.ReverseResponse, error) {
+		return p.reverse(ctx, req)
+	})
+	if err != nil {
+		return nil, p.recordIdempotentError(ctx, err, req.IdempotencyKey, idempotencyOpReverse)
+	}
+
+	return resp, nil
+}
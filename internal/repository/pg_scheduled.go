@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// scheduledPaymentColumns колонки таблицы scheduled_payments в порядке полей, которые читает scanScheduledPayment
+const scheduledPaymentColumns = `id, operation, wallet_id, COALESCE(to_wallet_id, 0), ticker_id, amount, repeat, start_at, end_at,
+next_run_at, runs, status, last_run_at, COALESCE(last_transaction_id, 0), last_error, created_at`
+
+// scanScheduledPayment читает запланированный платёж, название тикера берётся из кэша тикеров
+func (p *PostgresRepo) scanScheduledPayment(ctx context.Context, row rowScanner) (*models.ScheduledPayment, error) {
+	payment := &models.ScheduledPayment{}
+	var tickerID, status int
+	if err := row.Scan(&payment.ScheduledPaymentID, &payment.Operation, &payment.WalletID, &payment.ToWalletID, &tickerID,
+		&payment.Amount, &payment.Repeat, &payment.StartAt, &payment.EndAt, &payment.NextRunAt, &payment.Runs, &status,
+		&payment.LastRunAt, &payment.LastTransactionID, &payment.LastError, &payment.CreatedAt); err != nil {
+		return nil, err
+	}
+	payment.Status = models.ScheduledPaymentStatus(status)
+
+	ticker, err := p.getTicker(ctx, tickerID)
+	if err != nil {
+		return nil, err
+	}
+	payment.Ticker = ticker.Name
+
+	return payment, nil
+}
+
+func (p *PostgresRepo) scanScheduledPayments(ctx context.Context, rows *sql.Rows) ([]models.ScheduledPayment, error) {
+	defer rows.Close()
+
+	payments := make([]models.ScheduledPayment, 0)
+	for rows.Next() {
+		payment, err := p.scanScheduledPayment(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over scheduled payment rows: %s", err)
+	}
+
+	return payments, nil
+}
+
+/*
+CreateScheduledPayment сохраняет платёж, который планировщик выполнит в start_at (или сразу) и затем по расписанию.
+
+Кошельки проверяются при планировании, а сумма только на точность тикера: границы суммы, включение тикера,
+баланс и ограничения на списания проверяются при каждом запуске, так как к тому времени они могут измениться.
+*/
+func (p *PostgresRepo) CreateScheduledPayment(ctx context.Context, req *models.CreateScheduledPaymentRequest) (*models.ScheduledPayment, error) {
+	ticker, err := p.getTickerByName(ctx, req.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAmountScale(req.Amount, ticker); err != nil {
+		return nil, err
+	}
+
+	payment := &models.ScheduledPayment{Repeat: req.RepeatOrOnce(), StartAt: time.Now(), EndAt: req.EndAt}
+	if req.StartAt != nil {
+		payment.StartAt = *req.StartAt
+	}
+	next, _ := payment.NextRun(0)
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, walletID := range []int{req.WalletID, req.ToWalletID} {
+		if walletID == 0 {
+			continue
+		}
+		if err := checkWallet(ctx, tx, walletID); err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+	}
+
+	payment, err = p.scanScheduledPayment(ctx, tx.QueryRowContext(ctx,
+		`INSERT INTO scheduled_payments (operation, wallet_id, to_wallet_id, ticker_id, amount, repeat, start_at, end_at, next_run_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING `+scheduledPaymentColumns,
+		req.Operation, req.WalletID, nullableID(req.ToWalletID), ticker.TickerID, req.Amount, payment.Repeat,
+		payment.StartAt, payment.EndAt, next))
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (p *PostgresRepo) ListScheduledPayments(ctx context.Context, req *models.ScheduledPaymentsRequest) (*models.ScheduledPaymentsResponse, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT "+scheduledPaymentColumns+" FROM scheduled_payments WHERE wallet_id = $1 OR to_wallet_id = $1 ORDER BY id", req.WalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := p.scanScheduledPayments(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ScheduledPaymentsResponse{ScheduledPayments: payments}, nil
+}
+
+// CancelScheduledPayment отменяет будущие запуски активного платежа. Запуск, который планировщик уже начал,
+// выполняется до конца и сохраняет свой результат.
+func (p *PostgresRepo) CancelScheduledPayment(ctx context.Context, req *models.CancelScheduledPaymentRequest) (*models.ScheduledPayment, error) {
+	payment, err := p.scanScheduledPayment(ctx, p.db.QueryRowContext(ctx,
+		"UPDATE scheduled_payments SET status = $2, next_run_at = NULL WHERE id = $1 AND status = $3 RETURNING "+scheduledPaymentColumns,
+		req.ScheduledPaymentID, models.ScheduledPaymentCancelled, models.ScheduledPaymentActive))
+	if err == nil {
+		return payment, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// платёж не изменился: либо его нет, либо он уже не активен
+	var status int
+	if err := p.db.QueryRowContext(ctx,
+		"SELECT status FROM scheduled_payments WHERE id = $1", req.ScheduledPaymentID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ScheduledPaymentDoesntExist(req.ScheduledPaymentID)
+		}
+
+		return nil, err
+	}
+
+	return nil, ScheduledPaymentNotActive(req.ScheduledPaymentID, models.ScheduledPaymentStatus(status))
+}
+
+/*
+ClaimDueScheduledPayments выбирает до limit активных платежей, время запуска которых наступило, и блокирует их на lease.
+
+Пока блокировка не истекла, платёж не выбирается повторно, поэтому несколько экземпляров сервиса не запускают
+один платёж одновременно. Если запуск не был завершён через CompleteScheduledRun (например, сервис остановился),
+то после истечения блокировки платёж выбирается снова с тем же номером запуска и ключом идемпотентности.
+*/
+func (p *PostgresRepo) ClaimDueScheduledPayments(ctx context.Context, limit int, lease time.Duration) ([]models.ScheduledPayment, error) {
+	rows, err := p.db.QueryContext(ctx, `
+UPDATE scheduled_payments
+SET locked_until = now() + make_interval(secs => $2)
+WHERE id IN (SELECT id
+             FROM scheduled_payments
+             WHERE status = $3
+               AND next_run_at <= now()
+               AND (locked_until IS NULL OR locked_until < now())
+             ORDER BY next_run_at
+             LIMIT $1 FOR UPDATE SKIP LOCKED)
+RETURNING `+scheduledPaymentColumns,
+		limit, lease.Seconds(), models.ScheduledPaymentActive)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := p.scanScheduledPayments(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].NextRunAt.Before(*payments[j].NextRunAt)
+	})
+
+	return payments, nil
+}
+
+/*
+1) Открываем транзакцию и блокируем платёж. Если номер запуска уже не совпадает, то результат этого запуска
+уже сохранён и ничего не меняем
+
+2) Увеличиваем количество запусков, сохраняем результат и снимаем блокировку планировщика
+
+3) Считаем время следующего запуска, если запусков больше нет, то активный платёж завершается
+*/
+func (p *PostgresRepo) completeScheduledRun(ctx context.Context, result *models.ScheduledRunResult) error {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return err
+	}
+
+	payment, err := p.scanScheduledPayment(ctx, tx.QueryRowContext(ctx,
+		"SELECT "+scheduledPaymentColumns+" FROM scheduled_payments WHERE id = $1 FOR UPDATE", result.ScheduledPaymentID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return rollbackTx(tx, ScheduledPaymentDoesntExist(result.ScheduledPaymentID))
+		}
+
+		return rollbackTx(tx, err)
+	}
+	if payment.Runs != result.Run {
+		return rollbackTx(tx, nil)
+	}
+
+	runs := payment.Runs + 1
+	status := payment.Status
+	next, ok := payment.NextRun(runs)
+	if status != models.ScheduledPaymentActive || !ok {
+		next = time.Time{}
+		if status == models.ScheduledPaymentActive {
+			status = models.ScheduledPaymentCompleted
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+UPDATE scheduled_payments
+SET runs = $2, status = $3, next_run_at = $4, locked_until = NULL,
+    last_run_at = now(), last_transaction_id = $5, last_error = $6
+WHERE id = $1`,
+		payment.ScheduledPaymentID, runs, status, nullableTime(next), nullableID(result.TransactionID), result.Error); err != nil {
+		return rollbackTx(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CompleteScheduledRun сохраняет результат запуска платежа и переводит его на следующий запуск
+func (p *PostgresRepo) CompleteScheduledRun(ctx context.Context, result *models.ScheduledRunResult) error {
+	_, err := withRetry(ctx, p.retry, func() (struct{}, error) {
+		return struct{}{}, p.completeScheduledRun(ctx, result)
+	})
+
+	return err
+}
+
+// nullableTime возвращает NULL для нулевого времени
+func nullableTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
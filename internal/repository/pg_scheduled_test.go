@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// claim выбирает платежи, время которых наступило, и возвращает сколько раз среди них был платёж id
+func (r *testRepo) claim(t *testing.T, id int, lease time.Duration) int {
+	t.Helper()
+	payments, err := r.ClaimDueScheduledPayments(context.Background(), 1000, lease)
+	if err != nil {
+		t.Errorf("ClaimDueScheduledPayments: %v", err)
+		return 0
+	}
+
+	claimed := 0
+	for _, payment := range payments {
+		if payment.ScheduledPaymentID == id {
+			claimed++
+		}
+	}
+
+	return claimed
+}
+
+func TestScheduledPaymentClaimedOnce(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		walletID := r.wallet(t)
+		ticker := r.ticker(t, "SCH")
+		payment, err := r.CreateScheduledPayment(ctx, &models.CreateScheduledPaymentRequest{
+			Operation: models.OperationInvoice, WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("1"),
+		})
+		if err != nil {
+			t.Fatalf("CreateScheduledPayment: %v", err)
+		}
+
+		// несколько экземпляров планировщика выбирают платёж одновременно, достаётся он только одному
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		claimed := 0
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				n := r.claim(t, payment.ScheduledPaymentID, time.Minute)
+				mu.Lock()
+				claimed += n
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		if claimed != 1 {
+			t.Fatalf("payment claimed %d times, want 1", claimed)
+		}
+		if n := r.claim(t, payment.ScheduledPaymentID, time.Minute); n != 0 {
+			t.Fatalf("locked payment claimed again")
+		}
+
+		// результат одного запуска сохраняется один раз, даже если его прислали повторно
+		for i := 0; i < 2; i++ {
+			if err := r.CompleteScheduledRun(ctx, &models.ScheduledRunResult{ScheduledPaymentID: payment.ScheduledPaymentID, Run: 0}); err != nil {
+				t.Fatalf("CompleteScheduledRun: %v", err)
+			}
+		}
+		resp, err := r.ListScheduledPayments(ctx, &models.ScheduledPaymentsRequest{WalletID: walletID})
+		if err != nil {
+			t.Fatalf("ListScheduledPayments: %v", err)
+		}
+		if len(resp.ScheduledPayments) != 1 {
+			t.Fatalf("scheduled payments = %+v, want 1", resp.ScheduledPayments)
+		}
+		if got := resp.ScheduledPayments[0]; got.Runs != 1 || got.Status != models.ScheduledPaymentCompleted || got.NextRunAt != nil {
+			t.Fatalf("payment after run = %+v, want 1 run and completed", got)
+		}
+		if n := r.claim(t, payment.ScheduledPaymentID, time.Minute); n != 0 {
+			t.Fatalf("completed payment claimed again")
+		}
+	})
+}
+
+func TestScheduledPaymentReclaimedAfterLease(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		walletID := r.wallet(t)
+		ticker := r.ticker(t, "SCL")
+		payment, err := r.CreateScheduledPayment(ctx, &models.CreateScheduledPaymentRequest{
+			Operation: models.OperationInvoice, WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney("1"),
+			Repeat: models.RepeatDaily,
+		})
+		if err != nil {
+			t.Fatalf("CreateScheduledPayment: %v", err)
+		}
+
+		// незавершённый запуск выбирается снова после истечения блокировки с тем же номером запуска
+		if n := r.claim(t, payment.ScheduledPaymentID, time.Millisecond); n != 1 {
+			t.Fatalf("payment claimed %d times, want 1", n)
+		}
+		time.Sleep(50 * time.Millisecond)
+		payments, err := r.ClaimDueScheduledPayments(ctx, 1000, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimDueScheduledPayments: %v", err)
+		}
+		found := false
+		for _, claimed := range payments {
+			if claimed.ScheduledPaymentID == payment.ScheduledPaymentID {
+				found = claimed.Runs == 0 && claimed.IdempotencyKey() == payment.IdempotencyKey()
+			}
+		}
+		if !found {
+			t.Fatalf("payment with expired lease was not claimed again with the same run")
+		}
+
+		// ежедневный платёж после запуска переносится на следующий день
+		if err := r.CompleteScheduledRun(ctx, &models.ScheduledRunResult{ScheduledPaymentID: payment.ScheduledPaymentID, Run: 0}); err != nil {
+			t.Fatalf("CompleteScheduledRun: %v", err)
+		}
+		if n := r.claim(t, payment.ScheduledPaymentID, time.Minute); n != 0 {
+			t.Fatalf("payment claimed again before its next run")
+		}
+	})
+}
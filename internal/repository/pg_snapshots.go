@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+/*
+Баланс на момент времени X восстанавливается по действующим и архивным транзакциям (all_transactions):
+
+  - транзакция учитывается, если она создана не позже X (created_at <= X);
+  - статус транзакции меняется только при переходе из models.TransactionStatusCreated в финальный статус,
+    поэтому если updated_at > X, то на момент X транзакция находилась в статусе Created;
+  - в актуальный баланс входят все учтённые транзакции, кроме отменённых к моменту X,
+    в замороженный баланс входят списания, находившиеся на момент X в статусе Created.
+
+Операции, которые проходят через статус Created внутри одной транзакции бд, получают одинаковые created_at
+и updated_at (now() возвращает время начала транзакции), поэтому не попадают в замороженный баланс.
+*/
+
+// actualAtExpr вклад транзакции в актуальный баланс на момент time
+func actualAtExpr(time string) string {
+	return fmt.Sprintf("CASE WHEN created_at <= %[1]s AND (status <> %[2]d OR updated_at > %[1]s) THEN amount ELSE 0 END",
+		time, models.TransactionStatusError)
+}
+
+// frozenAtExpr вклад транзакции в замороженный баланс на момент time
+func frozenAtExpr(time string) string {
+	return fmt.Sprintf("CASE WHEN created_at <= %[1]s AND (status = %[2]d OR updated_at > %[1]s) THEN -amount ELSE 0 END",
+		time, models.TransactionStatusCreated)
+}
+
+// snapshotDeltaQuery балансы на момент $1 по снимку на момент $2 и изменениям после него.
+// Изменения после снимка есть только у транзакций с updated_at > $2, остальные уже учтены в снимке.
+var snapshotDeltaQuery = fmt.Sprintf(`
+SELECT wallet_id, ticker_id, SUM(actual), SUM(frozen)
+FROM (SELECT wallet_id, ticker_id, actual, frozen
+      FROM balance_snapshot_items
+      WHERE snapshot_at = $2
+        AND ($3 = 0 OR wallet_id = $3)
+      UNION ALL
+      SELECT wallet_id, ticker_id, %s - %s, %s - %s
+      FROM all_transactions
+      WHERE updated_at > $2
+        AND created_at <= $1
+        AND ($3 = 0 OR wallet_id = $3)) s
+GROUP BY wallet_id, ticker_id
+HAVING SUM(actual) <> 0 OR SUM(frozen) <> 0`,
+	actualAtExpr("$1"), actualAtExpr("$2"), frozenAtExpr("$1"), frozenAtExpr("$2"))
+
+// latestSnapshot возвращает время последнего снимка балансов не позже at, или нулевое время если снимков нет
+func latestSnapshot(ctx context.Context, tx *sql.Tx, at time.Time) (time.Time, error) {
+	var snapshotAt sql.NullTime
+	if err := tx.QueryRowContext(ctx,
+		"SELECT MAX(snapshot_at) FROM balance_snapshots WHERE snapshot_at <= $1", at).Scan(&snapshotAt); err != nil {
+		return time.Time{}, err
+	}
+
+	return snapshotAt.Time, nil
+}
+
+// getBalanceAt считает актуальный и замороженный баланс кошелька на момент at
+// по ближайшему предшествующему снимку и транзакциям, изменившимся после него
+func (p *PostgresRepo) getBalanceAt(ctx context.Context, walletID int, at time.Time) (*models.GetBalanceResponse, error) {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotAt, err := latestSnapshot(ctx, tx, at)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, snapshotDeltaQuery, at, snapshotAt, walletID)
+	if err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	defer rows.Close()
+
+	resp := &models.GetBalanceResponse{
+		ActualBalance: make(map[string]models.Money),
+		FrozenBalance: make(map[string]models.Money),
+		BalanceAt:     &at,
+	}
+	for rows.Next() {
+		var wID, tickerID int
+		var actual, frozen models.Money
+		if err := rows.Scan(&wID, &tickerID, &actual, &frozen); err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+
+		ticker, err := p.getTicker(ctx, tickerID)
+		if err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+		if !actual.IsZero() {
+			resp.ActualBalance[ticker.Name] = actual.Round(ticker.Scale, models.RoundDown)
+		}
+		if !frozen.IsZero() {
+			resp.FrozenBalance[ticker.Name] = frozen.Round(ticker.Scale, models.RoundDown)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, rollbackTx(tx, fmt.Errorf("error encountered while iterating over balance rows: %s", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+/*
+CreateBalanceSnapshot сохраняет балансы всех кошельков на момент at.
+
+1) Открываем транзакцию и создаём заголовок снимка. Если снимок на этот момент уже есть, то ничего не делаем
+
+2) Берём предыдущий снимок и добавляем к нему изменения транзакций между снимками
+
+Момент at должен быть в прошлом с запасом на длительность транзакций: транзакция бд, начатая до at,
+но подтверждённая после создания снимка, в него не попадёт.
+*/
+func (p *PostgresRepo) createBalanceSnapshot(ctx context.Context, at time.Time) error {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO balance_snapshots (snapshot_at) VALUES ($1) ON CONFLICT (snapshot_at) DO NOTHING", at)
+	if err != nil {
+		return rollbackTx(tx, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return rollbackTx(tx, err)
+	} else if n == 0 {
+		return tx.Rollback()
+	}
+
+	// предыдущий снимок строго раньше at, сам новый снимок ещё пустой
+	previous, err := latestSnapshot(ctx, tx, at.Add(-time.Microsecond))
+	if err != nil {
+		return rollbackTx(tx, err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO balance_snapshot_items (snapshot_at, wallet_id, ticker_id, actual, frozen) SELECT $1, * FROM ("+
+			snapshotDeltaQuery+") d", at, previous, 0); err != nil {
+		return rollbackTx(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresRepo) CreateBalanceSnapshot(ctx context.Context, at time.Time) error {
+	_, err := withRetry(ctx, p.retry, func() (struct{}, error) {
+		return struct{}{}, p.createBalanceSnapshot(ctx, at)
+	})
+
+	return err
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+// balanceAt возвращает актуальный и замороженный баланс кошелька по тикеру на момент at
+func (r *testRepo) balanceAt(t *testing.T, walletID int, ticker string, at time.Time) (string, string) {
+	t.Helper()
+	resp, err := r.GetBalance(context.Background(), &models.GetBalanceRequest{WalletID: walletID, BalanceAt: &at})
+	if err != nil {
+		t.Fatalf("GetBalance(%d, %s): %v", walletID, at, err)
+	}
+
+	return resp.ActualBalance[ticker].StringFixed(2), resp.FrozenBalance[ticker].StringFixed(2)
+}
+
+func TestGetBalanceAt(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		walletID := r.wallet(t)
+		ticker := r.ticker(t, "PIT")
+
+		invoiceID := r.invoice(t, walletID, ticker, "100")
+		withdraw := func(amount string) int {
+			resp, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney(amount)})
+			if err != nil {
+				t.Fatalf("WithDraw: %v", err)
+			}
+			return resp.TransactionID
+		}
+		capturedID := withdraw("10")
+		if _, err := r.Capture(ctx, &models.HoldRequest{TransactionID: capturedID}); err != nil {
+			t.Fatalf("Capture: %v", err)
+		}
+		releasedID := withdraw("5")
+		if _, err := r.Release(ctx, &models.HoldRequest{TransactionID: releasedID}); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		heldID := withdraw("20")
+
+		resp, err := r.ListTransactions(ctx, &models.HistoryRequest{WalletID: walletID, Limit: models.MaxHistoryLimit})
+		if err != nil {
+			t.Fatalf("ListTransactions: %v", err)
+		}
+		transactions := make(map[int]models.TransactionInfo)
+		for _, transaction := range resp.Transactions {
+			transactions[transaction.ID] = transaction
+		}
+
+		// моменты между операциями берутся из времени создания и изменения транзакций
+		points := []struct {
+			name           string
+			at             time.Time
+			actual, frozen string
+		}{
+			{name: "before invoice", at: transactions[invoiceID].CreatedAt.Add(-time.Microsecond), actual: "0.00", frozen: "0.00"},
+			{name: "invoice", at: transactions[invoiceID].CreatedAt, actual: "100.00", frozen: "0.00"},
+			{name: "held", at: transactions[capturedID].CreatedAt, actual: "90.00", frozen: "10.00"},
+			{name: "captured", at: transactions[capturedID].UpdatedAt, actual: "90.00", frozen: "0.00"},
+			{name: "released", at: transactions[releasedID].UpdatedAt, actual: "90.00", frozen: "0.00"},
+			{name: "held again", at: transactions[heldID].CreatedAt, actual: "70.00", frozen: "20.00"},
+		}
+		check := func(snapshot string) {
+			t.Helper()
+			for _, point := range points {
+				if actual, frozen := r.balanceAt(t, walletID, ticker, point.at); actual != point.actual || frozen != point.frozen {
+					t.Errorf("%s%s: balance = %s/%s, want %s/%s", point.name, snapshot, actual, frozen, point.actual, point.frozen)
+				}
+			}
+		}
+		check("")
+
+		// снимок между операциями не меняет результат ни до, ни после него
+		if err := r.CreateBalanceSnapshot(ctx, transactions[capturedID].CreatedAt); err != nil {
+			t.Fatalf("CreateBalanceSnapshot: %v", err)
+		}
+		check(" with snapshot")
+
+		if actual, frozen := r.balance(t, walletID, ticker); actual != "70.00" || frozen != "20.00" {
+			t.Fatalf("current balance = %s/%s, want 70.00/20.00", actual, frozen)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// tickerColumns колонки таблицы tickers в порядке полей, которые читает scanTicker
+const tickerColumns = "ticker_id, name, display_name, scale, min_amount, max_amount, enabled, updated_at"
+
+// rowScanner общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTicker(row rowScanner) (*models.Ticker, error) {
+	ticker := &models.Ticker{}
+	if err := row.Scan(&ticker.TickerID, &ticker.Name, &ticker.DisplayName, &ticker.Scale,
+		&ticker.MinAmount, &ticker.MaxAmount, &ticker.Enabled, &ticker.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return ticker, nil
+}
+
+// getTicker возвращает тикер по id из кэша, а при промахе читает его из бд
+func (p *PostgresRepo) getTicker(ctx context.Context, tickerID int) (*models.Ticker, error) {
+	if ticker, ok := p.tickers.getByID(tickerID); ok {
+		return ticker, nil
+	}
+
+	ticker, err := scanTicker(p.db.QueryRowContext(ctx,
+		"SELECT "+tickerColumns+" FROM tickers WHERE ticker_id = $1", tickerID))
+	if err != nil {
+		return nil, err
+	}
+	p.tickers.put(ticker)
+
+	return ticker, nil
+}
+
+// getTickerByName возвращает тикер по названию из кэша, а при промахе читает его из бд
+func (p *PostgresRepo) getTickerByName(ctx context.Context, name string) (*models.Ticker, error) {
+	if ticker, ok := p.tickers.getByName(name); ok {
+		return ticker, nil
+	}
+
+	ticker, err := scanTicker(p.db.QueryRowContext(ctx,
+		"SELECT "+tickerColumns+" FROM tickers WHERE name = $1", name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, TickerDoesntExist(name)
+		}
+
+		return nil, err
+	}
+	p.tickers.put(ticker)
+
+	return ticker, nil
+}
+
+// CreateTicker добавляет новый включённый тикер, код тикера уникален
+func (p *PostgresRepo) CreateTicker(ctx context.Context, req *models.CreateTickerRequest) (*models.Ticker, error) {
+	ticker, err := scanTicker(p.db.QueryRowContext(ctx,
+		`INSERT INTO tickers (name, display_name, scale, min_amount, max_amount) VALUES ($1, $2, $3, $4, $5)
+RETURNING `+tickerColumns,
+		req.Name, req.DisplayName, req.Scale, req.MinAmount, req.MaxAmount))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, TickerAlreadyExists(req.Name)
+		}
+
+		return nil, err
+	}
+	p.tickers.invalidate(req.Name)
+
+	return ticker, nil
+}
+
+// UpdateTicker заменяет отображаемое название и границы суммы операции тикера
+func (p *PostgresRepo) UpdateTicker(ctx context.Context, req *models.UpdateTickerRequest) (*models.Ticker, error) {
+	current, err := p.getTickerByName(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, amount := range []*models.Money{req.MinAmount, req.MaxAmount} {
+		if amount != nil {
+			if err := checkAmountScale(*amount, current); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return p.updateTicker(ctx, req.Name,
+		"display_name = $2, min_amount = $3, max_amount = $4", req.DisplayName, req.MinAmount, req.MaxAmount)
+}
+
+// SetTickerStatus включает или отключает операции по тикеру
+func (p *PostgresRepo) SetTickerStatus(ctx context.Context, req *models.SetTickerStatusRequest) (*models.Ticker, error) {
+	return p.updateTicker(ctx, req.Name, "enabled = $2", req.Enabled)
+}
+
+// updateTicker меняет колонки тикера name выражением set и сбрасывает его из кэша
+func (p *PostgresRepo) updateTicker(ctx context.Context, name, set string, args ...any) (*models.Ticker, error) {
+	ticker, err := scanTicker(p.db.QueryRowContext(ctx,
+		fmt.Sprintf("UPDATE tickers SET %s, updated_at = now() WHERE name = $1 RETURNING %s", set, tickerColumns),
+		append([]any{name}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, TickerDoesntExist(name)
+		}
+
+		return nil, err
+	}
+	p.tickers.invalidate(name)
+
+	return ticker, nil
+}
+
+// ListTickers возвращает все тикеры, включая отключённые
+func (p *PostgresRepo) ListTickers(ctx context.Context) (*models.TickersResponse, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+tickerColumns+" FROM tickers ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.TickersResponse{Tickers: make([]models.Ticker, 0)}
+	for rows.Next() {
+		ticker, err := scanTicker(rows)
+		if err != nil {
+			return nil, err
+		}
+		resp.Tickers = append(resp.Tickers, *ticker)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over ticker rows: %s", err)
+	}
+
+	return resp, nil
+}
+
+// InvalidateTickerCache сбрасывает кэш тикеров. Нужен, если тикеры были изменены в бд в обход репозитория.
+func (p *PostgresRepo) InvalidateTickerCache() {
+	p.tickers.invalidateAll()
+}
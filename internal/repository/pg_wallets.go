@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"database/sql"
+)
+
+// checkWalletActive возвращает ошибку, если кошелёк заблокирован или закрыт
+func checkWalletActive(walletID int, status models.WalletStatus, reason string) error {
+	switch status {
+	case models.WalletStatusSuspended:
+		return WalletSuspended(walletID, reason)
+	case models.WalletStatusClosed:
+		return WalletClosed(walletID)
+	default:
+		return nil
+	}
+}
+
+// checkWallet проверяет в рамках транзакции tx что кошелёк существует и с ним разрешены операции.
+// Строка кошелька блокируется FOR SHARE до конца транзакции: операции с кошельком друг другу не мешают,
+// но одновременное изменение состояния в setWalletStatus ждёт их или откатывает их по конфликту сериализации,
+// и при повторе операция видит новое состояние. Поэтому на закрываемый кошелёк не зачисляются средства.
+func checkWallet(ctx context.Context, tx *sql.Tx, walletID int) error {
+	var status int
+	var reason string
+	if err := tx.QueryRowContext(ctx,
+		"SELECT status, status_reason FROM wallets WHERE wallet_id = $1 FOR SHARE", walletID).Scan(&status, &reason); err != nil {
+		if err == sql.ErrNoRows {
+			return WalletDoesntExist(walletID)
+		}
+
+		return err
+	}
+
+	return checkWalletActive(walletID, models.WalletStatus(status), reason)
+}
+
+func (p *PostgresRepo) GetWallet(ctx context.Context, req *models.GetWalletRequest) (*models.Wallet, error) {
+	wallet := &models.Wallet{WalletID: req.WalletID}
+	var status int
+	var customerID sql.NullInt64
+	if err := p.db.QueryRowContext(ctx,
+		"SELECT status, status_reason, status_changed_at, customer_id FROM wallets WHERE wallet_id = $1", req.WalletID).Scan(
+		&status, &wallet.StatusReason, &wallet.StatusChangedAt, &customerID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, WalletDoesntExist(req.WalletID)
+		}
+
+		return nil, err
+	}
+	wallet.Status = models.WalletStatus(status)
+	wallet.CustomerID = int(customerID.Int64)
+
+	return wallet, nil
+}
+
+/*
+1) Открываем транзакцию и блокируем строку кошелька
+
+2) Проверяем что кошелёк не закрыт, закрытый кошелёк нельзя открыть заново
+
+3) При закрытии проверяем что на кошельке нет ни актуального, ни замороженного баланса
+
+4) Меняем состояние кошелька, сохраняем причину и время изменения
+
+5) Подтверждаем транзакцию
+*/
+func (p *PostgresRepo) setWalletStatus(ctx context.Context, req *models.SetWalletStatusRequest) (*models.Wallet, error) {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	var status int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT status FROM wallets WHERE wallet_id = $1 FOR UPDATE", req.WalletID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, rollbackTx(tx, WalletDoesntExist(req.WalletID))
+		}
+
+		return nil, rollbackTx(tx, err)
+	}
+	if models.WalletStatus(status) == models.WalletStatusClosed {
+		return nil, rollbackTx(tx, WalletClosed(req.WalletID))
+	}
+
+	// закрыть можно только пустой кошелёк, иначе средства клиента станут недоступны
+	if req.Status == models.WalletStatusClosed {
+		var hasFunds bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM current_balances WHERE wallet_id = $1 AND amount > 0)
+    OR EXISTS (SELECT 1 FROM transactions WHERE wallet_id = $1 AND status = $2)`,
+			req.WalletID, models.TransactionStatusCreated).Scan(&hasFunds); err != nil {
+			return nil, rollbackTx(tx, err)
+		}
+		if hasFunds {
+			return nil, rollbackTx(tx, WalletNotEmpty(req.WalletID))
+		}
+	}
+
+	wallet := &models.Wallet{WalletID: req.WalletID, Status: req.Status, StatusReason: req.Reason}
+	var customerID sql.NullInt64
+	if err := tx.QueryRowContext(ctx,
+		"UPDATE wallets SET status = $1, status_reason = $2, status_changed_at = now() WHERE wallet_id = $3 RETURNING status_changed_at, customer_id",
+		int(req.Status), req.Reason, req.WalletID).Scan(&wallet.StatusChangedAt, &customerID); err != nil {
+		return nil, rollbackTx(tx, err)
+	}
+	wallet.CustomerID = int(customerID.Int64)
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+func (p *PostgresRepo) SetWalletStatus(ctx context.Context, req *models.SetWalletStatusRequest) (*models.Wallet, error) {
+	return withRetry(ctx, p.retry, func() (*models.Wallet, error) {
+		return p.setWalletStatus(ctx, req)
+	})
+}
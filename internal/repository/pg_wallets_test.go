@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"testing"
+)
+
+func TestWalletStatusRejectsOperations(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		usd := r.ticker(t, "USD")
+		wallet, other := r.wallet(t), r.wallet(t)
+		r.invoice(t, other, usd, "10")
+
+		if _, err := r.SetWalletStatus(ctx, &models.SetWalletStatusRequest{
+			WalletID: wallet, Status: models.WalletStatusSuspended, Reason: "fraud check",
+		}); err != nil {
+			t.Fatalf("SetWalletStatus(suspended): %v", err)
+		}
+		_, err := r.Invoice(ctx, &models.InvoiceRequest{WalletID: wallet, Ticker: usd, Amount: models.MustParseMoney("1")})
+		checkErrorCode(t, "Invoice to suspended wallet", err, ErrCodeWalletSuspended)
+		_, err = r.Transfer(ctx, &models.TransferRequest{FromWalletID: other, ToWalletID: wallet, Ticker: usd, Amount: models.MustParseMoney("1")})
+		checkErrorCode(t, "Transfer to suspended wallet", err, ErrCodeWalletSuspended)
+
+		if _, err := r.SetWalletStatus(ctx, &models.SetWalletStatusRequest{WalletID: wallet, Status: models.WalletStatusClosed}); err != nil {
+			t.Fatalf("SetWalletStatus(closed): %v", err)
+		}
+		_, err = r.Invoice(ctx, &models.InvoiceRequest{WalletID: wallet, Ticker: usd, Amount: models.MustParseMoney("1")})
+		checkErrorCode(t, "Invoice to closed wallet", err, ErrCodeWalletClosed)
+		_, err = r.WithDraw(ctx, &models.WithdrawRequest{WalletID: wallet, Ticker: usd, Amount: models.MustParseMoney("1")})
+		checkErrorCode(t, "Withdraw from closed wallet", err, ErrCodeWalletClosed)
+		_, err = r.SetWalletStatus(ctx, &models.SetWalletStatusRequest{WalletID: wallet, Status: models.WalletStatusActive})
+		checkErrorCode(t, "reopen closed wallet", err, ErrCodeWalletClosed)
+
+		if actual, _ := r.balance(t, other, usd); actual != "10.00" {
+			t.Errorf("balance of sender = %s, want 10.00", actual)
+		}
+	})
+}
+
+func TestCloseWalletRequiresEmptyWallet(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		usd := r.ticker(t, "USD")
+		wallet := r.wallet(t)
+		r.invoice(t, wallet, usd, "10")
+
+		closeWallet := &models.SetWalletStatusRequest{WalletID: wallet, Status: models.WalletStatusClosed}
+		_, err := r.SetWalletStatus(ctx, closeWallet)
+		checkErrorCode(t, "close wallet with balance", err, ErrCodeWalletNotEmpty)
+
+		// замороженное списание тоже не даёт закрыть кошелёк
+		hold, err := r.WithDraw(ctx, &models.WithdrawRequest{WalletID: wallet, Ticker: usd, Amount: models.MustParseMoney("10")})
+		if err != nil {
+			t.Fatalf("WithDraw: %v", err)
+		}
+		_, err = r.SetWalletStatus(ctx, closeWallet)
+		checkErrorCode(t, "close wallet with hold", err, ErrCodeWalletNotEmpty)
+
+		if _, err := r.Capture(ctx, &models.HoldRequest{TransactionID: hold.TransactionID}); err != nil {
+			t.Fatalf("Capture: %v", err)
+		}
+		closed, err := r.SetWalletStatus(ctx, closeWallet)
+		if err != nil {
+			t.Fatalf("close empty wallet: %v", err)
+		}
+		if closed.Status != models.WalletStatusClosed {
+			t.Errorf("status = %v, want closed", closed.Status)
+		}
+	})
+}
+
+func TestCloseWalletConcurrentWithInvoice(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r *testRepo) {
+		ctx := context.Background()
+		usd := r.ticker(t, "USD")
+		for i := 0; i < 20; i++ {
+			wallet := r.wallet(t)
+			done := make(chan error, 1)
+			go func() {
+				_, err := r.Invoice(ctx, &models.InvoiceRequest{WalletID: wallet, Ticker: usd, Amount: models.MustParseMoney("1")})
+				done <- err
+			}()
+			_, closeErr := r.SetWalletStatus(ctx, &models.SetWalletStatusRequest{WalletID: wallet, Status: models.WalletStatusClosed})
+			invoiceErr := <-done
+
+			// зачисление и закрытие не могут выполниться оба: закрытый кошелёк не должен хранить средства
+			if closeErr == nil && invoiceErr == nil {
+				actual, _ := r.balance(t, wallet, usd)
+				t.Fatalf("wallet %d was closed and credited concurrently, balance %s", wallet, actual)
+			}
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// webhookDeliveryColumns колонки таблицы webhook_deliveries в порядке полей, которые читает scanWebhookDelivery
+const webhookDeliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+d.last_attempt_at, COALESCE(d.last_status_code, 0), d.last_error, d.created_at`
+
+// newWebhookSecret генерирует случайный секрет подписи уведомлений
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// webhookAttemptOutcome возвращает статус доставки после попытки result и время следующей попытки,
+// нулевое если попыток больше не будет
+func webhookAttemptOutcome(result *models.WebhookAttemptResult, now time.Time) (models.WebhookDeliveryStatus, time.Time) {
+	switch {
+	case result.Succeeded():
+		return models.WebhookDeliverySucceeded, time.Time{}
+	case result.Attempt >= models.WebhookMaxAttempts:
+		return models.WebhookDeliveryFailed, time.Time{}
+	default:
+		return models.WebhookDeliveryPending, now.Add(models.WebhookRetryDelay(result.Attempt))
+	}
+}
+
+func scanWebhookDelivery(row rowScanner, extra ...any) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	var status int
+	var payload []byte
+	if err := row.Scan(append([]any{&delivery.DeliveryID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload,
+		&status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastAttemptAt, &delivery.LastStatusCode,
+		&delivery.LastError, &delivery.CreatedAt}, extra...)...); err != nil {
+		return nil, err
+	}
+	delivery.Status = models.WebhookDeliveryStatus(status)
+	delivery.Payload = payload
+
+	return delivery, nil
+}
+
+// createWebhookDeliveries создаёт в рамках транзакции tx доставки события event на все адреса его кошелька и общие адреса
+func createWebhookDeliveries(ctx context.Context, tx *sql.Tx, event *models.TransactionEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+SELECT id, $2, $3, $4 FROM webhooks WHERE wallet_id IS NULL OR wallet_id = $1`,
+		event.WalletID, event.EventID, event.Type, payload); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateWebhook регистрирует адрес для уведомлений. Уведомления отправляются только о транзакциях,
+// которые перешли в финальный статус после регистрации.
+func (p *PostgresRepo) CreateWebhook(ctx context.Context, req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	if req.WalletID != 0 {
+		var wID int
+		if err := p.db.QueryRowContext(ctx, "SELECT wallet_id FROM wallets WHERE wallet_id = $1", req.WalletID).Scan(&wID); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, WalletDoesntExist(req.WalletID)
+			}
+
+			return nil, err
+		}
+	}
+
+	webhook := &models.Webhook{WalletID: req.WalletID, URL: req.URL, Secret: req.Secret}
+	if webhook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		webhook.Secret = secret
+	}
+
+	if err := p.db.QueryRowContext(ctx,
+		"INSERT INTO webhooks (wallet_id, url, secret) VALUES ($1, $2, $3) RETURNING id, created_at",
+		nullableID(webhook.WalletID), webhook.URL, webhook.Secret).Scan(&webhook.WebhookID, &webhook.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (p *PostgresRepo) ListWebhooks(ctx context.Context, req *models.WebhooksRequest) (*models.WebhooksResponse, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT id, COALESCE(wallet_id, 0), url, created_at FROM webhooks WHERE $1 = 0 OR wallet_id = $1 ORDER BY id", req.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.WebhooksResponse{Webhooks: make([]models.Webhook, 0)}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := rows.Scan(&webhook.WebhookID, &webhook.WalletID, &webhook.URL, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		resp.Webhooks = append(resp.Webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over webhook rows: %s", err)
+	}
+
+	return resp, nil
+}
+
+// DeleteWebhook удаляет адрес вместе с журналом доставок на него
+func (p *PostgresRepo) DeleteWebhook(ctx context.Context, req *models.DeleteWebhookRequest) error {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", req.WebhookID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return WebhookDoesntExist(req.WebhookID)
+	}
+
+	return nil
+}
+
+func (p *PostgresRepo) ListWebhookDeliveries(ctx context.Context, req *models.WebhookDeliveriesRequest) (*models.WebhookDeliveriesResponse, error) {
+	var id int
+	if err := p.db.QueryRowContext(ctx, "SELECT id FROM webhooks WHERE id = $1", req.WebhookID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, WebhookDoesntExist(req.WebhookID)
+		}
+
+		return nil, err
+	}
+
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries d WHERE d.webhook_id = $1 ORDER BY d.id DESC LIMIT $2",
+		req.WebhookID, req.LimitOrDefault())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.WebhookDeliveriesResponse{Deliveries: make([]models.WebhookDelivery, 0)}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		resp.Deliveries = append(resp.Deliveries, *delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over webhook delivery rows: %s", err)
+	}
+
+	return resp, nil
+}
+
+/*
+ClaimWebhookDeliveries выбирает до limit доставок, время попытки которых наступило, и блокирует их на lease
+вместе с адресом и секретом подписи.
+
+Пока блокировка не истекла, доставка не выбирается повторно другими экземплярами сервиса. Если результат попытки
+не был сохранён через CompleteWebhookAttempt, то после истечения блокировки попытка повторяется с тем же номером.
+*/
+func (p *PostgresRepo) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	rows, err := p.db.QueryContext(ctx, `
+UPDATE webhook_deliveries d
+SET locked_until = now() + make_interval(secs => $2)
+FROM webhooks w
+WHERE w.id = d.webhook_id
+  AND d.id IN (SELECT id
+               FROM webhook_deliveries
+               WHERE status = $3
+                 AND next_attempt_at <= now()
+                 AND (locked_until IS NULL OR locked_until < now())
+               ORDER BY next_attempt_at
+               LIMIT $1 FOR UPDATE SKIP LOCKED)
+RETURNING `+webhookDeliveryColumns+`, w.url, w.secret`,
+		limit, lease.Seconds(), models.WebhookDeliveryPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		var url, secret string
+		delivery, err := scanWebhookDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		delivery.URL, delivery.Secret = url, secret
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered while iterating over webhook delivery rows: %s", err)
+	}
+
+	return deliveries, nil
+}
+
+// CompleteWebhookAttempt сохраняет результат попытки доставки и назначает следующую попытку с экспоненциальной задержкой.
+// Результат уже сохранённой попытки или попытки удалённого адреса не меняет ничего.
+func (p *PostgresRepo) CompleteWebhookAttempt(ctx context.Context, result *models.WebhookAttemptResult) error {
+	status, next := webhookAttemptOutcome(result, time.Now())
+	_, err := p.db.ExecContext(ctx, `
+UPDATE webhook_deliveries
+SET attempts = $2, status = $3, next_attempt_at = $4, locked_until = NULL,
+    last_attempt_at = now(), last_status_code = $5, last_error = $6
+WHERE id = $1 AND attempts = $2 - 1 AND status = $7`,
+		result.DeliveryID, result.Attempt, status, nullableTime(next), nullableID(result.StatusCode), result.Error,
+		models.WebhookDeliveryPending)
+
+	return err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"testing"
+	"time"
+)
+
+func TestWebhookAttemptOutcome(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		result models.WebhookAttemptResult
+		status models.WebhookDeliveryStatus
+		next   time.Time
+	}{
+		{
+			name:   "success",
+			result: models.WebhookAttemptResult{Attempt: 1, StatusCode: 204},
+			status: models.WebhookDeliverySucceeded,
+		},
+		{
+			name:   "first failure",
+			result: models.WebhookAttemptResult{Attempt: 1, StatusCode: 500},
+			status: models.WebhookDeliveryPending,
+			next:   now.Add(30 * time.Second),
+		},
+		{
+			name:   "connection error doubles delay",
+			result: models.WebhookAttemptResult{Attempt: 3, Error: "connection refused"},
+			status: models.WebhookDeliveryPending,
+			next:   now.Add(2 * time.Minute),
+		},
+		{
+			name:   "redirect is a failure",
+			result: models.WebhookAttemptResult{Attempt: 2, StatusCode: 302},
+			status: models.WebhookDeliveryPending,
+			next:   now.Add(time.Minute),
+		},
+		{
+			name:   "last retry",
+			result: models.WebhookAttemptResult{Attempt: models.WebhookMaxAttempts - 1, StatusCode: 503},
+			status: models.WebhookDeliveryPending,
+			next:   now.Add(128 * time.Minute),
+		},
+		{
+			name:   "last attempt",
+			result: models.WebhookAttemptResult{Attempt: models.WebhookMaxAttempts, StatusCode: 503},
+			status: models.WebhookDeliveryFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, next := webhookAttemptOutcome(&tt.result, now)
+			if status != tt.status || !next.Equal(tt.next) {
+				t.Errorf("webhookAttemptOutcome = %d, %v; want %d, %v", status, next, tt.status, tt.next)
+			}
+		})
+	}
+}
@@ -4,27 +4,235 @@ import (
 	"bwg_transactional_system/internal/models"
 	"context"
 	"fmt"
+	"time"
 )
 
 type Repository interface {
 	CreateWallet(ctx context.Context) (*models.Wallet, error)
-	CreateTicker(ctx context.Context, ticker string) error
-	Invoice(ctx context.Context, req *models.InvoiceRequest) error
-	WithDraw(ctx context.Context, req *models.WithdrawRequest) error
+	GetWallet(ctx context.Context, req *models.GetWalletRequest) (*models.Wallet, error)
+	SetWalletStatus(ctx context.Context, req *models.SetWalletStatusRequest) (*models.Wallet, error)
+	CreateCustomer(ctx context.Context, req *models.CreateCustomerRequest) (*models.Customer, error)
+	GetCustomer(ctx context.Context, req *models.GetCustomerRequest) (*models.Customer, error)
+	AttachWallet(ctx context.Context, req *models.AttachWalletRequest) (*models.Customer, error)
+	GetCustomerBalance(ctx context.Context, req *models.GetCustomerRequest) (*models.CustomerBalanceResponse, error)
+	CreateTicker(ctx context.Context, req *models.CreateTickerRequest) (*models.Ticker, error)
+	UpdateTicker(ctx context.Context, req *models.UpdateTickerRequest) (*models.Ticker, error)
+	SetTickerStatus(ctx context.Context, req *models.SetTickerStatusRequest) (*models.Ticker, error)
+	ListTickers(ctx context.Context) (*models.TickersResponse, error)
+	Invoice(ctx context.Context, req *models.InvoiceRequest) (*models.OperationResponse, error)
+	WithDraw(ctx context.Context, req *models.WithdrawRequest) (*models.OperationResponse, error)
+	Capture(ctx context.Context, req *models.HoldRequest) (*models.OperationResponse, error)
+	Release(ctx context.Context, req *models.HoldRequest) (*models.OperationResponse, error)
+	Transfer(ctx context.Context, req *models.TransferRequest) (*models.TransferResponse, error)
+	Reverse(ctx context.Context, req *models.ReverseRequest) (*models.ReverseResponse, error)
+	Exchange(ctx context.Context, req *models.ExchangeRequest) (*models.ExchangeResponse, error)
+	Batch(ctx context.Context, req *models.BatchRequest) (*models.BatchResponse, error)
+	CreateScheduledPayment(ctx context.Context, req *models.CreateScheduledPaymentRequest) (*models.ScheduledPayment, error)
+	ListScheduledPayments(ctx context.Context, req *models.ScheduledPaymentsRequest) (*models.ScheduledPaymentsResponse, error)
+	CancelScheduledPayment(ctx context.Context, req *models.CancelScheduledPaymentRequest) (*models.ScheduledPayment, error)
+	ClaimDueScheduledPayments(ctx context.Context, limit int, lease time.Duration) ([]models.ScheduledPayment, error)
+	CompleteScheduledRun(ctx context.Context, result *models.ScheduledRunResult) error
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.TransactionEvent, error)
+	MarkOutboxPublished(ctx context.Context, ids []int64) error
+	CreateWebhook(ctx context.Context, req *models.CreateWebhookRequest) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context, req *models.WebhooksRequest) (*models.WebhooksResponse, error)
+	DeleteWebhook(ctx context.Context, req *models.DeleteWebhookRequest) error
+	ListWebhookDeliveries(ctx context.Context, req *models.WebhookDeliveriesRequest) (*models.WebhookDeliveriesResponse, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	CompleteWebhookAttempt(ctx context.Context, result *models.WebhookAttemptResult) error
+	RecordAudit(ctx context.Context, record *models.AuditRecord) error
+	ListAuditLog(ctx context.Context, req *models.AuditLogRequest) (*models.AuditLogResponse, error)
+	SetExchangeRate(ctx context.Context, req *models.ExchangeRate) error
+	GetExchangeRates(ctx context.Context) (*models.ExchangeRatesResponse, error)
+	SetWithdrawalLimit(ctx context.Context, req *models.WithdrawalLimit) error
+	ListWithdrawalLimits(ctx context.Context, req *models.WithdrawalLimitsRequest) (*models.WithdrawalLimitsResponse, error)
+	DeleteWithdrawalLimit(ctx context.Context, req *models.DeleteWithdrawalLimitRequest) error
+	SetFee(ctx context.Context, req *models.FeeRule) error
+	ListFees(ctx context.Context) (*models.FeesResponse, error)
+	DeleteFee(ctx context.Context, req *models.DeleteFeeRequest) error
 	GetBalance(ctx context.Context, req *models.GetBalanceRequest) (*models.GetBalanceResponse, error)
+	ListTransactions(ctx context.Context, req *models.HistoryRequest) (*models.HistoryResponse, error)
+	Reconcile(ctx context.Context) (*models.ReconciliationReport, error)
+	CreateBalanceSnapshot(ctx context.Context, at time.Time) error
+	ArchiveTransactions(ctx context.Context, before time.Time, limit int) (int, error)
+	MaintainTransactionPartitions(ctx context.Context, before time.Time) (int, error)
+	ApplyFeeCredits(ctx context.Context, limit int) (int, error)
 	Close() error
 }
 
-type LogicErrors error
+// Коды ошибок бизнес-логики, по которым клиент может отличить причину отказа
+const (
+	ErrCodeWalletNotFound         = "wallet_not_found"
+	ErrCodeTickerNotFound         = "ticker_not_found"
+	ErrCodeTickerExists           = "ticker_already_exists"
+	ErrCodeTickerDisabled         = "ticker_disabled"
+	ErrCodeAmountOutOfRange       = "amount_out_of_range"
+	ErrCodeNotEnoughCoins         = "not_enough_coins"
+	ErrCodeTransactionNotFound    = "transaction_not_found"
+	ErrCodeTransactionNotHeld     = "transaction_not_held"
+	ErrCodeAmountPrecision        = "amount_precision_exceeded"
+	ErrCodeExchangeRateNotFound   = "exchange_rate_not_found"
+	ErrCodeExchangeAmountTooSmall = "exchange_amount_too_small"
+	ErrCodeExchangeAmountTooLarge = "exchange_amount_too_large"
+	ErrCodeWalletSuspended        = "wallet_suspended"
+	ErrCodeWalletClosed           = "wallet_closed"
+	ErrCodeWalletNotEmpty         = "wallet_not_empty"
+	ErrCodeLimitExceeded          = "withdrawal_limit_exceeded"
+	ErrCodeLimitNotFound          = "withdrawal_limit_not_found"
+	ErrCodeFeeExceedsAmount       = "fee_exceeds_amount"
+	ErrCodeFeeNotFound            = "fee_not_found"
+	ErrCodeCustomerNotFound       = "customer_not_found"
+	ErrCodeCustomerExists         = "customer_already_exists"
+	ErrCodeWalletOwned            = "wallet_owned_by_other_customer"
+	ErrCodeNotReversible          = "transaction_not_reversible"
+	ErrCodeAlreadyReversed        = "transaction_already_reversed"
+	ErrCodeReversalExceeds        = "reversal_exceeds_amount"
+	ErrCodeScheduledNotFound      = "scheduled_payment_not_found"
+	ErrCodeScheduledNotActive     = "scheduled_payment_not_active"
+	ErrCodeWebhookNotFound        = "webhook_not_found"
+)
+
+// LogicErrors ошибка бизнес-логики, которая возвращается клиенту как ошибка в запросе вместе с кодом Code
+type LogicErrors struct {
+	Code   string
+	Reason string
+}
+
+func (e LogicErrors) Error() string {
+	return e.Reason
+}
+
+func newLogicError(code, format string, args ...any) LogicErrors {
+	return LogicErrors{Code: code, Reason: fmt.Sprintf(format, args...)}
+}
 
 func WalletDoesntExist(walletID int) LogicErrors {
-	return fmt.Errorf("the requested wallet with id = %d, doesn't exist", walletID)
+	return newLogicError(ErrCodeWalletNotFound, "the requested wallet with id = %d, doesn't exist", walletID)
 }
 
 func TickerDoesntExist(ticker string) LogicErrors {
-	return fmt.Errorf("the requested ticker with name = %s, doesn't exist", ticker)
+	return newLogicError(ErrCodeTickerNotFound, "the requested ticker with name = %s, doesn't exist", ticker)
+}
+
+func TickerAlreadyExists(ticker string) LogicErrors {
+	return newLogicError(ErrCodeTickerExists, "the ticker with name = %s already exists", ticker)
+}
+
+func TickerDisabled(ticker string) LogicErrors {
+	return newLogicError(ErrCodeTickerDisabled, "operations with %s are disabled", ticker)
+}
+
+func AmountOutOfRange(ticker string, minAmount, maxAmount *models.Money) LogicErrors {
+	switch {
+	case minAmount != nil && maxAmount != nil:
+		return newLogicError(ErrCodeAmountOutOfRange, "the amount of %s must be between %s and %s", ticker, minAmount, maxAmount)
+	case minAmount != nil:
+		return newLogicError(ErrCodeAmountOutOfRange, "the amount of %s must be at least %s", ticker, minAmount)
+	default:
+		return newLogicError(ErrCodeAmountOutOfRange, "the amount of %s must be at most %s", ticker, maxAmount)
+	}
 }
 
 func NotEnoughCoins(walletID int, ticker string) LogicErrors {
-	return fmt.Errorf("there are not enough %s's on the wallet with id = %d to be debited", ticker, walletID)
+	return newLogicError(ErrCodeNotEnoughCoins, "there are not enough %s's on the wallet with id = %d to be debited", ticker, walletID)
+}
+
+func TransactionDoesntExist(transactionID int) LogicErrors {
+	return newLogicError(ErrCodeTransactionNotFound, "the requested transaction with id = %d, doesn't exist", transactionID)
+}
+
+func TransactionNotHeld(transactionID int) LogicErrors {
+	return newLogicError(ErrCodeTransactionNotHeld, "the transaction with id = %d is not a pending withdrawal", transactionID)
+}
+
+func AmountPrecisionExceeded(ticker string, scale int) LogicErrors {
+	return newLogicError(ErrCodeAmountPrecision, "the amount of %s can't have more then %d digits after the decimal point", ticker, scale)
+}
+
+func ExchangeRateDoesntExist(fromTicker, toTicker string) LogicErrors {
+	return newLogicError(ErrCodeExchangeRateNotFound, "the exchange rate from %s to %s doesn't exist", fromTicker, toTicker)
+}
+
+func ExchangeAmountTooSmall(fromTicker, toTicker string) LogicErrors {
+	return newLogicError(ErrCodeExchangeAmountTooSmall, "the amount of %s is too small to be exchanged to %s", fromTicker, toTicker)
+}
+
+func ExchangeAmountTooLarge(fromTicker, toTicker string) LogicErrors {
+	return newLogicError(ErrCodeExchangeAmountTooLarge, "the amount of %s is too large to be exchanged to %s", fromTicker, toTicker)
+}
+
+func WalletSuspended(walletID int, reason string) LogicErrors {
+	return newLogicError(ErrCodeWalletSuspended, "the wallet with id = %d is suspended: %s", walletID, reason)
+}
+
+func WalletClosed(walletID int) LogicErrors {
+	return newLogicError(ErrCodeWalletClosed, "the wallet with id = %d is closed", walletID)
+}
+
+func WalletNotEmpty(walletID int) LogicErrors {
+	return newLogicError(ErrCodeWalletNotEmpty, "the wallet with id = %d has funds and can't be closed", walletID)
+}
+
+func WithdrawalLimitExceeded(walletID int, ticker, period string, limit models.Money) LogicErrors {
+	return newLogicError(ErrCodeLimitExceeded, "the %s withdrawal limit of %s %s is exceeded for the wallet with id = %d",
+		period, limit, ticker, walletID)
+}
+
+func WithdrawalLimitDoesntExist(walletID int, ticker string) LogicErrors {
+	if walletID == 0 {
+		return newLogicError(ErrCodeLimitNotFound, "the default withdrawal limit for %s doesn't exist", ticker)
+	}
+
+	return newLogicError(ErrCodeLimitNotFound, "the withdrawal limit for %s on the wallet with id = %d doesn't exist", ticker, walletID)
+}
+
+func FeeExceedsAmount(ticker string, fee models.Money) LogicErrors {
+	return newLogicError(ErrCodeFeeExceedsAmount, "the fee of %s %s is not less then the invoice amount", fee, ticker)
+}
+
+func FeeDoesntExist(operation, ticker string) LogicErrors {
+	return newLogicError(ErrCodeFeeNotFound, "the fee for the %s operation with %s doesn't exist", operation, ticker)
+}
+
+func CustomerDoesntExist(customerID int) LogicErrors {
+	return newLogicError(ErrCodeCustomerNotFound, "the requested customer with id = %d, doesn't exist", customerID)
+}
+
+func CustomerAlreadyExists(externalID string) LogicErrors {
+	return newLogicError(ErrCodeCustomerExists, "the customer with external id = %s already exists", externalID)
+}
+
+func WalletOwnedByOtherCustomer(walletID int) LogicErrors {
+	return newLogicError(ErrCodeWalletOwned, "the wallet with id = %d belongs to another customer", walletID)
+}
+
+func TransactionNotReversible(transactionID int) LogicErrors {
+	return newLogicError(ErrCodeNotReversible,
+		"the transaction with id = %d is not a successful invoice or captured withdrawal and can't be reversed", transactionID)
+}
+
+func TransactionAlreadyReversed(transactionID int) LogicErrors {
+	return newLogicError(ErrCodeAlreadyReversed, "the transaction with id = %d is already fully reversed", transactionID)
+}
+
+func ReversalExceedsAmount(transactionID int, ticker string, remaining models.Money) LogicErrors {
+	return newLogicError(ErrCodeReversalExceeds,
+		"only %s %s of the transaction with id = %d can still be reversed", remaining, ticker, transactionID)
+}
+
+func ScheduledPaymentDoesntExist(id int) LogicErrors {
+	return newLogicError(ErrCodeScheduledNotFound, "the scheduled payment with id = %d doesn't exist", id)
+}
+
+func ScheduledPaymentNotActive(id int, status models.ScheduledPaymentStatus) LogicErrors {
+	return newLogicError(ErrCodeScheduledNotActive, "the scheduled payment with id = %d is already %s", id, status)
+}
+
+func WebhookDoesntExist(id int) LogicErrors {
+	return newLogicError(ErrCodeWebhookNotFound, "the webhook with id = %d doesn't exist", id)
+}
+
+// BatchLegFailed ошибка операции пакета с номером leg (с нуля), код ошибки остаётся кодом этой операции
+func BatchLegFailed(leg int, err LogicErrors) LogicErrors {
+	return newLogicError(err.Code, "batch leg %d failed, no legs were applied: %s", leg, err.Reason)
 }
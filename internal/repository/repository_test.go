@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRepo проверяемый репозиторий вместе с его кошельком комиссий. suffix добавляется к названиям тикеров,
+// чтобы тесты с PostgresRepo в общей бд не влияли друг на друга.
+type testRepo struct {
+	Repository
+	pg          *PostgresRepo
+	feeWalletID int
+	suffix      string
+}
+
+var testTickers atomic.Int64
+
+/*
+forEachRepository выполняет test с MemoryRepo и, если задан TEST_DB_HOST, с PostgresRepo. Остальные параметры
+подключения берутся из TEST_DB_PORT, TEST_DB_USER, TEST_DB_PASSWORD и TEST_DB_NAME. Бд не очищается, тесты
+создают свои кошельки и тикеры.
+*/
+func forEachRepository(t *testing.T, test func(t *testing.T, r *testRepo)) {
+	t.Run("memory", func(t *testing.T) {
+		repo := NewMemoryRepo(1)
+		if _, err := repo.CreateWallet(context.Background()); err != nil {
+			t.Fatalf("CreateWallet: %v", err)
+		}
+		test(t, &testRepo{Repository: repo, feeWalletID: 1})
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		pg := newTestPostgresRepo(t)
+		test(t, &testRepo{Repository: pg, pg: pg, feeWalletID: pg.feeWalletID, suffix: fmt.Sprint(time.Now().UnixNano() % 1e9)})
+	})
+}
+
+// testConfig возвращает настройки подключения к тестовой бд или пропускает тест
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg := &Config{
+		Host:     os.Getenv("TEST_DB_HOST"),
+		Port:     os.Getenv("TEST_DB_PORT"),
+		Username: os.Getenv("TEST_DB_USER"),
+		Password: os.Getenv("TEST_DB_PASSWORD"),
+		DBName:   os.Getenv("TEST_DB_NAME"),
+		SSLMode:  "disable",
+	}
+	if cfg.Host == "" {
+		t.Skip("TEST_DB_HOST is not set")
+	}
+
+	return cfg
+}
+
+// newTestPostgresRepo подключается к тестовой бд с новым кошельком комиссий или пропускает тест
+func newTestPostgresRepo(t *testing.T) *PostgresRepo {
+	t.Helper()
+	cfg := testConfig(t)
+
+	// кошелёк комиссий создаётся до подключения, в котором он указан в настройках
+	setup, err := NewPostgresRepo(cfg)
+	if err != nil {
+		t.Fatalf("NewPostgresRepo: %v", err)
+	}
+	feeWallet, err := setup.CreateWallet(context.Background())
+	_ = setup.Close()
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	cfg.FeeWalletID = feeWallet.WalletID
+	repo, err := NewPostgresRepo(cfg)
+	if err != nil {
+		t.Fatalf("NewPostgresRepo: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+
+	return repo
+}
+
+// wallet создаёт кошелёк и возвращает его id
+func (r *testRepo) wallet(t *testing.T) int {
+	t.Helper()
+	wallet, err := r.CreateWallet(context.Background())
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+
+	return wallet.WalletID
+}
+
+// ticker создаёт тикер с точностью 2 знака и возвращает его название
+func (r *testRepo) ticker(t *testing.T, name string) string {
+	t.Helper()
+	name = fmt.Sprintf("%s%s%d", name, r.suffix, testTickers.Add(1))
+	if _, err := r.CreateTicker(context.Background(), &models.CreateTickerRequest{Name: name, Scale: 2}); err != nil {
+		t.Fatalf("CreateTicker(%s): %v", name, err)
+	}
+
+	return name
+}
+
+// invoice зачисляет amount на кошелёк и возвращает id транзакции
+func (r *testRepo) invoice(t *testing.T, walletID int, ticker, amount string) int {
+	t.Helper()
+	resp, err := r.Invoice(context.Background(), &models.InvoiceRequest{WalletID: walletID, Ticker: ticker, Amount: models.MustParseMoney(amount)})
+	if err != nil {
+		t.Fatalf("Invoice(%d, %s %s): %v", walletID, amount, ticker, err)
+	}
+
+	return resp.TransactionID
+}
+
+// balance возвращает актуальный и замороженный баланс кошелька по тикеру с точностью 2 знака
+func (r *testRepo) balance(t *testing.T, walletID int, ticker string) (string, string) {
+	t.Helper()
+	resp, err := r.GetBalance(context.Background(), &models.GetBalanceRequest{WalletID: walletID})
+	if err != nil {
+		t.Fatalf("GetBalance(%d): %v", walletID, err)
+	}
+
+	return resp.ActualBalance[ticker].StringFixed(2), resp.FrozenBalance[ticker].StringFixed(2)
+}
+
+// checkErrorCode проверяет что err это ошибка бизнес-логики с кодом code
+func checkErrorCode(t *testing.T, op string, err error, code string) {
+	t.Helper()
+	var e LogicErrors
+	if !errors.As(err, &e) || e.Code != code {
+		t.Fatalf("%s: error %v, want error code %s", op, err, code)
+	}
+}
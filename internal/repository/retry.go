@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/lib/pq"
+	"math/rand"
+	"time"
+)
+
+// Коды ошибок PostgreSQL, после которых транзакцию можно безопасно повторить целиком
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy правило повтора транзакций, отменённых бд из-за конкурентного доступа к тем же строкам.
+// Задержка между попытками растёт экспоненциально от BaseDelay до MaxDelay со случайным разбросом,
+// чтобы конкурирующие запросы не повторялись одновременно.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy правило повтора, если оно не задано в конфиге
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    500 * time.Millisecond,
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected)
+}
+
+// delay возвращает задержку перед попыткой с номером attempt (начиная с 1)
+func (r RetryPolicy) delay(attempt int) time.Duration {
+	d := r.BaseDelay << (attempt - 1)
+	if d <= 0 || d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+
+	// случайная задержка в диапазоне [d/2, d]
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// withRetry выполняет fn и повторяет её при ошибках сериализации и взаимоблокировках, не более MaxAttempts раз.
+// fn должна открывать и завершать транзакцию сама, чтобы каждая попытка начиналась с нового снимка данных.
+func withRetry[T any](ctx context.Context, policy RetryPolicy, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || !isRetryable(err) || attempt >= policy.MaxAttempts {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(policy.delay(attempt)):
+		}
+	}
+}
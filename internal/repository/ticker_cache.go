@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTickerCacheSize размер кэша тикеров, если он не задан в конфиге
+	defaultTickerCacheSize = 1024
+	// defaultTickerCacheTTL время жизни тикера в кэше, если оно не задано в конфиге
+	defaultTickerCacheTTL = 5 * time.Second
+)
+
+// tickerCache ограниченный по размеру LRU кэш тикеров с поиском по id и по названию.
+// Изменение тикера сбрасывает его только из кэша своего экземпляра сервиса, поэтому тикер хранится не дольше ttl:
+// остальные экземпляры увидят отключение тикера или новые границы суммы не позже чем через ttl.
+type tickerCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+	// order тикеры от недавно использованных к давно использованным
+	order  *list.List
+	byID   map[int]*list.Element
+	byName map[string]*list.Element
+}
+
+// cachedTicker тикер вместе со временем, когда он был прочитан из бд
+type cachedTicker struct {
+	ticker   models.Ticker
+	loadedAt time.Time
+}
+
+func newTickerCache(capacity int, ttl time.Duration) *tickerCache {
+	if capacity <= 0 {
+		capacity = defaultTickerCacheSize
+	}
+	if ttl <= 0 {
+		ttl = defaultTickerCacheTTL
+	}
+
+	return &tickerCache{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		byID:     make(map[int]*list.Element),
+		byName:   make(map[string]*list.Element),
+	}
+}
+
+func (c *tickerCache) getByID(tickerID int) (*models.Ticker, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(c.byID[tickerID])
+}
+
+func (c *tickerCache) getByName(name string) (*models.Ticker, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(c.byName[name])
+}
+
+// get возвращает копию тикера, чтобы вызывающий код не мог изменить закэшированное значение.
+// Устаревший тикер удаляется из кэша и считается промахом.
+func (c *tickerCache) get(elem *list.Element) (*models.Ticker, bool) {
+	if elem == nil {
+		return nil, false
+	}
+	cached := elem.Value.(*cachedTicker)
+	if c.now().Sub(cached.loadedAt) >= c.ttl {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	ticker := cached.ticker
+
+	return &ticker, true
+}
+
+func (c *tickerCache) put(ticker *models.Ticker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.byID[ticker.TickerID]; ok {
+		c.remove(elem)
+	}
+	if elem, ok := c.byName[ticker.Name]; ok {
+		c.remove(elem)
+	}
+
+	elem := c.order.PushFront(&cachedTicker{ticker: *ticker, loadedAt: c.now()})
+	c.byID[ticker.TickerID] = elem
+	c.byName[ticker.Name] = elem
+
+	// вытесняем давно не использованные тикеры
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// invalidate удаляет тикер с названием name из кэша
+func (c *tickerCache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.byName[name]; ok {
+		c.remove(elem)
+	}
+}
+
+// invalidateAll очищает кэш
+func (c *tickerCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.byID = make(map[int]*list.Element)
+	c.byName = make(map[string]*list.Element)
+}
+
+func (c *tickerCache) remove(elem *list.Element) {
+	ticker := c.order.Remove(elem).(*cachedTicker).ticker
+	delete(c.byID, ticker.TickerID)
+	delete(c.byName, ticker.Name)
+}
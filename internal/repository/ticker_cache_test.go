@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"bwg_transactional_system/internal/models"
+	"testing"
+	"time"
+)
+
+func TestTickerCacheExpiresEntries(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	cache := newTickerCache(10, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put(&models.Ticker{TickerID: 1, Name: "USD", Enabled: true})
+
+	if ticker, ok := cache.getByName("USD"); !ok || !ticker.Enabled {
+		t.Fatalf("getByName before ttl = %v, %v; want cached enabled ticker", ticker, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.getByID(1); ok {
+		t.Fatal("getByID after ttl: ticker is still cached")
+	}
+	if _, ok := cache.getByName("USD"); ok {
+		t.Fatal("getByName after ttl: expired ticker was not removed by name")
+	}
+}
+
+func TestTickerCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newTickerCache(2, time.Hour)
+	cache.put(&models.Ticker{TickerID: 1, Name: "USD"})
+	cache.put(&models.Ticker{TickerID: 2, Name: "EUR"})
+	cache.getByID(1)
+	cache.put(&models.Ticker{TickerID: 3, Name: "BTC"})
+
+	if _, ok := cache.getByName("EUR"); ok {
+		t.Error("EUR is still cached, want it evicted as least recently used")
+	}
+	for _, name := range []string{"USD", "BTC"} {
+		if _, ok := cache.getByName(name); !ok {
+			t.Errorf("%s is not cached", name)
+		}
+	}
+}
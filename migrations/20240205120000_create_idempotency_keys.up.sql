@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    key        varchar(255) primary key,
+    operation  varchar(64) NOT NULL,
+    response   jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE double precision;
+
+ALTER TABLE balances
+    ALTER COLUMN amount TYPE double precision;
+
+ALTER TABLE tickers
+    DROP COLUMN IF EXISTS scale;
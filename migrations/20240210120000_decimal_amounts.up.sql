@@ -0,0 +1,8 @@
+ALTER TABLE tickers
+    ADD COLUMN IF NOT EXISTS scale smallint NOT NULL DEFAULT 2 CHECK (0 <= scale AND scale <= 8);
+
+ALTER TABLE balances
+    ALTER COLUMN amount TYPE numeric(30, 8);
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE numeric(30, 8);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS transactions_wallet_id_idx;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,5 @@
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS transactions_wallet_id_idx ON transactions (wallet_id, id DESC);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS exchange_rates;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS linked_transaction_id;
@@ -0,0 +1,11 @@
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS linked_transaction_id integer references transactions (id);
+
+CREATE TABLE IF NOT EXISTS exchange_rates
+(
+    from_ticker_id integer references tickers (ticker_id),
+    to_ticker_id   integer references tickers (ticker_id),
+    rate           numeric(30, 8) CHECK (rate > 0) NOT NULL,
+    updated_at     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (from_ticker_id, to_ticker_id)
+);
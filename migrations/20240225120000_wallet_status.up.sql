@@ -0,0 +1,4 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS status            smallint NOT NULL DEFAULT 0 CHECK (0 <= status AND status <= 2),
+    ADD COLUMN IF NOT EXISTS status_reason     text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS status_changed_at timestamptz NOT NULL DEFAULT now();
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS withdrawal_limits;
+
+DROP INDEX IF EXISTS transactions_wallet_ticker_created_idx;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS operation;
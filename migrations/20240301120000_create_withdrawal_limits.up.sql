@@ -0,0 +1,16 @@
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS operation varchar(32) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS transactions_wallet_ticker_created_idx ON transactions (wallet_id, ticker_id, created_at);
+
+CREATE TABLE IF NOT EXISTS withdrawal_limits
+(
+    wallet_id  integer references wallets (wallet_id),
+    ticker_id  integer NOT NULL references tickers (ticker_id),
+    max_single numeric(30, 8) CHECK (max_single > 0),
+    daily      numeric(30, 8) CHECK (daily > 0),
+    monthly    numeric(30, 8) CHECK (monthly > 0),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS withdrawal_limits_wallet_ticker_idx ON withdrawal_limits (COALESCE(wallet_id, 0), ticker_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fees;
+
+DROP INDEX IF EXISTS transactions_linked_transaction_id_idx;
@@ -0,0 +1,13 @@
+CREATE INDEX IF NOT EXISTS transactions_linked_transaction_id_idx ON transactions (linked_transaction_id);
+
+CREATE TABLE IF NOT EXISTS fees
+(
+    operation  varchar(32) NOT NULL CHECK (operation IN ('invoice', 'withdraw')),
+    ticker_id  integer NOT NULL references tickers (ticker_id),
+    fixed      numeric(30, 8) NOT NULL DEFAULT 0 CHECK (fixed >= 0),
+    rate       numeric(30, 8) NOT NULL DEFAULT 0 CHECK (0 <= rate AND rate < 1),
+    min_fee    numeric(30, 8) CHECK (min_fee >= 0),
+    max_fee    numeric(30, 8) CHECK (max_fee >= 0),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (operation, ticker_id)
+);
@@ -0,0 +1,80 @@
+CREATE TABLE IF NOT EXISTS journal_entries
+(
+    entry_id   serial primary key,
+    operation  varchar(32) NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS postings
+(
+    posting_id     serial primary key,
+    entry_id       integer NOT NULL references journal_entries (entry_id),
+    wallet_id      integer references wallets (wallet_id),
+    account        varchar(32),
+    ticker_id      integer NOT NULL references tickers (ticker_id),
+    amount         numeric(30, 8) NOT NULL,
+    transaction_id integer references transactions (id),
+    CONSTRAINT posting_account CHECK ((wallet_id IS NULL) <> (account IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS postings_entry_id_idx ON postings (entry_id);
+CREATE INDEX IF NOT EXISTS postings_wallet_ticker_idx ON postings (wallet_id, ticker_id);
+
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS trigger AS
+$$
+BEGIN
+    IF EXISTS (SELECT 1
+               FROM postings
+               WHERE entry_id = COALESCE(NEW.entry_id, OLD.entry_id)
+               GROUP BY ticker_id
+               HAVING SUM(amount) <> 0) THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', COALESCE(NEW.entry_id, OLD.entry_id)
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DO
+$$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'postings_balanced') THEN
+        CREATE CONSTRAINT TRIGGER postings_balanced
+            AFTER INSERT OR UPDATE OR DELETE
+            ON postings
+            DEFERRABLE INITIALLY DEFERRED
+            FOR EACH ROW
+        EXECUTE FUNCTION check_journal_entry_balanced();
+    END IF;
+END;
+$$;
+
+-- переносим в журнал остатки, накопленные до его появления: балансы кошельков и замороженные списания
+-- проводятся одной записью против счёта opening_balance, если журнал ещё пуст
+WITH opening AS (
+    SELECT wallet_id, NULL::varchar(32) AS account, ticker_id, amount
+    FROM balances
+    WHERE amount <> 0
+    UNION ALL
+    SELECT NULL, 'holds', ticker_id, -SUM(amount)
+    FROM transactions
+    WHERE status = 2
+    GROUP BY ticker_id
+),
+     entry AS (
+         INSERT INTO journal_entries (operation)
+             SELECT 'opening_balance'
+             WHERE EXISTS (SELECT 1 FROM opening)
+               AND NOT EXISTS (SELECT 1 FROM journal_entries)
+             RETURNING entry_id
+     )
+INSERT
+INTO postings (entry_id, wallet_id, account, ticker_id, amount)
+SELECT entry.entry_id, o.wallet_id, o.account, o.ticker_id, o.amount
+FROM entry,
+     opening o
+UNION ALL
+SELECT entry.entry_id, NULL, 'opening_balance', o.ticker_id, -SUM(o.amount)
+FROM entry,
+     opening o
+GROUP BY entry.entry_id, o.ticker_id;
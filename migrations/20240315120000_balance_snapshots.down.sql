@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS balance_snapshot_items;
+DROP TABLE IF EXISTS balance_snapshots;
+
+DROP INDEX IF EXISTS transactions_updated_at_idx;
+DROP INDEX IF EXISTS transactions_wallet_updated_idx;
@@ -0,0 +1,18 @@
+CREATE INDEX IF NOT EXISTS transactions_wallet_updated_idx ON transactions (wallet_id, updated_at);
+CREATE INDEX IF NOT EXISTS transactions_updated_at_idx ON transactions (updated_at);
+
+CREATE TABLE IF NOT EXISTS balance_snapshots
+(
+    snapshot_at timestamptz primary key,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS balance_snapshot_items
+(
+    snapshot_at timestamptz references balance_snapshots (snapshot_at),
+    wallet_id   integer references wallets (wallet_id),
+    ticker_id   integer references tickers (ticker_id),
+    actual      numeric(30, 8) NOT NULL,
+    frozen      numeric(30, 8) NOT NULL,
+    PRIMARY KEY (snapshot_at, wallet_id, ticker_id)
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS tickers_name_idx;
+
+ALTER TABLE tickers
+    DROP CONSTRAINT IF EXISTS tickers_amount_range,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS enabled,
+    DROP COLUMN IF EXISTS max_amount,
+    DROP COLUMN IF EXISTS min_amount,
+    DROP COLUMN IF EXISTS display_name;
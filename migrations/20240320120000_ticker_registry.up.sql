@@ -0,0 +1,19 @@
+-- раньше название тикера не было уникальным: повторяющимся тикерам к коду добавляется их id,
+-- код сохраняет тикер с наименьшим id
+UPDATE tickers t
+SET name = t.name || '_' || t.ticker_id
+WHERE EXISTS (SELECT 1 FROM tickers d WHERE d.name = t.name AND d.ticker_id < t.ticker_id);
+
+ALTER TABLE tickers
+    ADD COLUMN IF NOT EXISTS display_name varchar(64)    NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS min_amount   numeric(30, 8) CHECK (min_amount > 0),
+    ADD COLUMN IF NOT EXISTS max_amount   numeric(30, 8) CHECK (max_amount > 0),
+    ADD COLUMN IF NOT EXISTS enabled      boolean        NOT NULL DEFAULT true,
+    ADD COLUMN IF NOT EXISTS updated_at   timestamptz    NOT NULL DEFAULT now();
+
+ALTER TABLE tickers
+    DROP CONSTRAINT IF EXISTS tickers_amount_range;
+ALTER TABLE tickers
+    ADD CONSTRAINT tickers_amount_range CHECK (min_amount <= max_amount);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tickers_name_idx ON tickers (name);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS wallets_customer_idx;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS customer_id;
+
+DROP TABLE IF EXISTS customers;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS customers
+(
+    customer_id serial primary key,
+    external_id varchar(255) UNIQUE,
+    name        varchar(255) NOT NULL DEFAULT '',
+    created_at  timestamptz  NOT NULL DEFAULT now()
+);
+
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS customer_id integer references customers (customer_id);
+
+CREATE INDEX IF NOT EXISTS wallets_customer_idx ON wallets (customer_id);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS scheduled_payments
+(
+    id                  serial primary key,
+    operation           varchar(32)    NOT NULL CHECK (operation IN ('invoice', 'withdraw', 'transfer')),
+    wallet_id           integer        NOT NULL references wallets (wallet_id),
+    to_wallet_id        integer references wallets (wallet_id),
+    ticker_id           integer        NOT NULL references tickers (ticker_id),
+    amount              numeric(30, 8) NOT NULL CHECK (amount > 0),
+    repeat              varchar(16)    NOT NULL CHECK (repeat IN ('once', 'daily', 'weekly', 'monthly')),
+    start_at            timestamptz    NOT NULL,
+    end_at              timestamptz,
+    next_run_at         timestamptz,
+    runs                integer        NOT NULL DEFAULT 0,
+    status              smallint       NOT NULL DEFAULT 0,
+    locked_until        timestamptz,
+    last_run_at         timestamptz,
+    last_transaction_id integer,
+    last_error          text           NOT NULL DEFAULT '',
+    created_at          timestamptz    NOT NULL DEFAULT now(),
+    CHECK ((operation = 'transfer') = (to_wallet_id IS NOT NULL))
+);
+
+-- планировщик выбирает только активные платежи, время выполнения которых наступило
+CREATE INDEX IF NOT EXISTS scheduled_payments_due_idx ON scheduled_payments (next_run_at) WHERE status = 0;
+CREATE INDEX IF NOT EXISTS scheduled_payments_wallet_idx ON scheduled_payments (wallet_id);
+CREATE INDEX IF NOT EXISTS scheduled_payments_to_wallet_idx ON scheduled_payments (to_wallet_id);
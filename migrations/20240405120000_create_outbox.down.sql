@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS outbox;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS event_sequence;
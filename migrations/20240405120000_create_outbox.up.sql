@@ -0,0 +1,20 @@
+-- номер последнего события кошелька, строка кошелька блокируется при записи события до конца транзакции,
+-- поэтому события одного кошелька попадают в outbox в порядке подтверждения транзакций
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS event_sequence bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id           bigserial primary key,
+    event_type   varchar(64) NOT NULL,
+    wallet_id    integer     NOT NULL references wallets (wallet_id),
+    sequence     bigint      NOT NULL,
+    payload      jsonb       NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    locked_until timestamptz,
+    published_at timestamptz,
+    UNIQUE (wallet_id, sequence)
+);
+
+-- публикация читает неопубликованные события с начала очереди
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         serial primary key,
+    -- NULL для адресов, на которые отправляются уведомления о транзакциях всех кошельков
+    wallet_id  integer references wallets (wallet_id),
+    url        text         NOT NULL,
+    secret     varchar(255) NOT NULL,
+    created_at timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_wallet_idx ON webhooks (wallet_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               serial primary key,
+    webhook_id       integer     NOT NULL references webhooks (id) ON DELETE CASCADE,
+    event_id         bigint      NOT NULL,
+    event_type       varchar(64) NOT NULL,
+    payload          jsonb       NOT NULL,
+    status           smallint    NOT NULL DEFAULT 0,
+    attempts         integer     NOT NULL DEFAULT 0,
+    next_attempt_at  timestamptz DEFAULT now(),
+    locked_until     timestamptz,
+    last_attempt_at  timestamptz,
+    last_status_code integer,
+    last_error       text        NOT NULL DEFAULT '',
+    created_at       timestamptz NOT NULL DEFAULT now()
+);
+
+-- отправка выбирает только доставки, время следующей попытки которых наступило
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 0;
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
@@ -0,0 +1,47 @@
+-- журнал аудита: по записи на каждое обработанное сообщение, записи только добавляются
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id             bigserial primary key,
+    app_id         varchar(255) NOT NULL DEFAULT '',
+    user_id        varchar(255) NOT NULL DEFAULT '',
+    message_id     varchar(255) NOT NULL DEFAULT '',
+    correlation_id varchar(255) NOT NULL DEFAULT '',
+    routing_key    varchar(255) NOT NULL,
+    redelivered    boolean      NOT NULL DEFAULT false,
+    body_sha256    char(64)     NOT NULL,
+    code           integer      NOT NULL,
+    error_code     varchar(64)  NOT NULL DEFAULT '',
+    transaction_id integer,
+    created_at     timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS audit_log_transaction_idx ON audit_log (transaction_id) WHERE transaction_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION forbid_audit_log_change() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only, % is not allowed', TG_OP
+        USING ERRCODE = 'insufficient_privilege';
+END;
+$$ LANGUAGE plpgsql;
+
+DO
+$$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_log_append_only') THEN
+        CREATE TRIGGER audit_log_append_only
+            BEFORE UPDATE OR DELETE
+            ON audit_log
+            FOR EACH ROW
+        EXECUTE FUNCTION forbid_audit_log_change();
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_log_no_truncate') THEN
+        CREATE TRIGGER audit_log_no_truncate
+            BEFORE TRUNCATE
+            ON audit_log
+            FOR EACH STATEMENT
+        EXECUTE FUNCTION forbid_audit_log_change();
+    END IF;
+END;
+$$;
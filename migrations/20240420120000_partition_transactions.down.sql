@@ -0,0 +1,45 @@
+DROP VIEW IF EXISTS all_transactions;
+
+CREATE TABLE transactions_unpartitioned
+(
+    id                    integer        NOT NULL DEFAULT nextval('transactions_id_seq'),
+    wallet_id             integer references wallets (wallet_id),
+    ticker_id             integer references tickers (ticker_id),
+    amount                numeric(30, 8) NOT NULL,
+    status                integer        NOT NULL,
+    created_at            timestamptz    NOT NULL DEFAULT now(),
+    updated_at            timestamptz    NOT NULL DEFAULT now(),
+    linked_transaction_id integer,
+    operation             varchar(32)    NOT NULL DEFAULT '',
+    CONSTRAINT valid_status CHECK (0 <= status AND status <= 2)
+);
+
+-- архивные транзакции возвращаются в общую таблицу
+INSERT INTO transactions_unpartitioned (id, wallet_id, ticker_id, amount, status, created_at, updated_at,
+                                        linked_transaction_id, operation)
+SELECT id, wallet_id, ticker_id, amount, status, created_at, updated_at, linked_transaction_id, operation
+FROM transactions
+UNION ALL
+SELECT id, wallet_id, ticker_id, amount, status, created_at, updated_at, linked_transaction_id, operation
+FROM transactions_archive;
+
+ALTER SEQUENCE transactions_id_seq OWNED BY transactions_unpartitioned.id;
+DROP TABLE transactions;
+DROP TABLE IF EXISTS transactions_archive;
+DROP FUNCTION IF EXISTS create_transactions_partition(date);
+DROP FUNCTION IF EXISTS drop_empty_transactions_partitions(timestamptz);
+
+ALTER TABLE transactions_unpartitioned
+    RENAME TO transactions;
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_pkey PRIMARY KEY (id);
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_linked_transaction_id_fkey FOREIGN KEY (linked_transaction_id) REFERENCES transactions (id);
+ALTER TABLE postings
+    ADD CONSTRAINT postings_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions (id);
+
+CREATE INDEX IF NOT EXISTS transactions_wallet_id_idx ON transactions (wallet_id, id DESC);
+CREATE INDEX IF NOT EXISTS transactions_wallet_ticker_created_idx ON transactions (wallet_id, ticker_id, created_at);
+CREATE INDEX IF NOT EXISTS transactions_linked_transaction_id_idx ON transactions (linked_transaction_id);
+CREATE INDEX IF NOT EXISTS transactions_wallet_updated_idx ON transactions (wallet_id, updated_at);
+CREATE INDEX IF NOT EXISTS transactions_updated_at_idx ON transactions (updated_at);
@@ -0,0 +1,129 @@
+-- внешний ключ на секционированную таблицу должен включать ключ секционирования, поэтому ссылки на transactions
+-- по одному id больше не проверяются бд. Транзакции не удаляются, а переносятся в transactions_archive с тем же id.
+ALTER TABLE postings
+    DROP CONSTRAINT IF EXISTS postings_transaction_id_fkey;
+ALTER TABLE transactions
+    DROP CONSTRAINT IF EXISTS transactions_linked_transaction_id_fkey;
+
+ALTER TABLE transactions
+    RENAME TO transactions_unpartitioned;
+
+-- транзакции секционируются по месяцу создания (UTC), разделы называются transactions_pYYYYMM
+CREATE TABLE transactions
+(
+    id                    integer        NOT NULL DEFAULT nextval('transactions_id_seq'),
+    wallet_id             integer references wallets (wallet_id),
+    ticker_id             integer references tickers (ticker_id),
+    amount                numeric(30, 8) NOT NULL,
+    status                integer        NOT NULL,
+    created_at            timestamptz    NOT NULL DEFAULT now(),
+    updated_at            timestamptz    NOT NULL DEFAULT now(),
+    linked_transaction_id integer,
+    operation             varchar(32)    NOT NULL DEFAULT '',
+    CONSTRAINT valid_status CHECK (0 <= status AND status <= 2)
+) PARTITION BY RANGE (created_at);
+
+-- сюда попадают транзакции месяцев, для которых раздел ещё не создан
+CREATE TABLE transactions_default PARTITION OF transactions DEFAULT;
+
+CREATE OR REPLACE FUNCTION create_transactions_partition(month date) RETURNS void AS
+$$
+DECLARE
+    partition_name text      := format('transactions_p%s', to_char(month, 'YYYYMM'));
+    month_start    timestamp := date_trunc('month', month::timestamp);
+    range_from     timestamptz := month_start AT TIME ZONE 'UTC';
+    range_to       timestamptz := (month_start + interval '1 month') AT TIME ZONE 'UTC';
+BEGIN
+    IF to_regclass(partition_name) IS NOT NULL THEN
+        RETURN;
+    END IF;
+
+    EXECUTE format('CREATE TABLE %I (LIKE transactions INCLUDING DEFAULTS INCLUDING CONSTRAINTS)', partition_name);
+    -- раздел нельзя подключить, пока в разделе по умолчанию есть строки из его диапазона, поэтому они переносятся
+    EXECUTE format('WITH moved AS (DELETE FROM transactions_default WHERE created_at >= $1 AND created_at < $2 RETURNING *)
+                    INSERT INTO %I SELECT * FROM moved', partition_name) USING range_from, range_to;
+    EXECUTE format('ALTER TABLE transactions ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+                   partition_name, range_from, range_to);
+END;
+$$ LANGUAGE plpgsql;
+
+-- drop_empty_transactions_partitions удаляет пустые разделы месяцев, закончившихся до before,
+-- и возвращает количество удалённых разделов
+CREATE OR REPLACE FUNCTION drop_empty_transactions_partitions(before timestamptz) RETURNS integer AS
+$$
+DECLARE
+    partition_name text;
+    is_empty       boolean;
+    dropped        integer := 0;
+BEGIN
+    FOR partition_name IN SELECT c.relname
+                          FROM pg_inherits i
+                                   JOIN pg_class c ON c.oid = i.inhrelid
+                          WHERE i.inhparent = 'transactions'::regclass
+                            AND c.relname ~ '^transactions_p[0-9]{6}$'
+        LOOP
+            IF ((to_date(substr(partition_name, 15), 'YYYYMM') + interval '1 month') AT TIME ZONE 'UTC') > before THEN
+                CONTINUE;
+            END IF;
+
+            EXECUTE format('SELECT NOT EXISTS (SELECT 1 FROM %I)', partition_name) INTO is_empty;
+            IF is_empty THEN
+                EXECUTE format('DROP TABLE %I', partition_name);
+                dropped := dropped + 1;
+            END IF;
+        END LOOP;
+
+    RETURN dropped;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_transactions_partition(m::date)
+FROM generate_series(date_trunc('month', COALESCE((SELECT MIN(created_at) FROM transactions_unpartitioned), now())
+                                             AT TIME ZONE 'UTC'),
+                     date_trunc('month', now() AT TIME ZONE 'UTC') + interval '1 month',
+                     interval '1 month') m;
+
+INSERT INTO transactions (id, wallet_id, ticker_id, amount, status, created_at, updated_at, linked_transaction_id, operation)
+SELECT id, wallet_id, ticker_id, amount, status, created_at, updated_at, linked_transaction_id, operation
+FROM transactions_unpartitioned;
+
+ALTER SEQUENCE transactions_id_seq OWNED BY transactions.id;
+DROP TABLE transactions_unpartitioned;
+
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_pkey PRIMARY KEY (id, created_at);
+CREATE INDEX IF NOT EXISTS transactions_wallet_id_idx ON transactions (wallet_id, id DESC);
+CREATE INDEX IF NOT EXISTS transactions_wallet_ticker_created_idx ON transactions (wallet_id, ticker_id, created_at);
+CREATE INDEX IF NOT EXISTS transactions_linked_transaction_id_idx ON transactions (linked_transaction_id);
+CREATE INDEX IF NOT EXISTS transactions_wallet_updated_idx ON transactions (wallet_id, updated_at);
+CREATE INDEX IF NOT EXISTS transactions_updated_at_idx ON transactions (updated_at);
+-- замороженные списания, по ним считается замороженный баланс без чтения завершённых транзакций
+CREATE INDEX IF NOT EXISTS transactions_frozen_idx ON transactions (wallet_id, ticker_id) WHERE status = 2;
+
+-- завершённые транзакции, перенесённые из transactions фоновой архивацией
+CREATE TABLE IF NOT EXISTS transactions_archive
+(
+    id                    integer primary key,
+    wallet_id             integer references wallets (wallet_id),
+    ticker_id             integer references tickers (ticker_id),
+    amount                numeric(30, 8) NOT NULL,
+    status                integer        NOT NULL,
+    created_at            timestamptz    NOT NULL,
+    updated_at            timestamptz    NOT NULL,
+    linked_transaction_id integer,
+    operation             varchar(32)    NOT NULL DEFAULT '',
+    archived_at           timestamptz    NOT NULL DEFAULT now(),
+    CONSTRAINT archived_status CHECK (status IN (0, 1))
+);
+
+CREATE INDEX IF NOT EXISTS transactions_archive_wallet_id_idx ON transactions_archive (wallet_id, id DESC);
+CREATE INDEX IF NOT EXISTS transactions_archive_linked_transaction_id_idx ON transactions_archive (linked_transaction_id);
+CREATE INDEX IF NOT EXISTS transactions_archive_updated_at_idx ON transactions_archive (updated_at);
+
+-- все транзакции, действующие и архивные, для истории, сверки и балансов на момент в прошлом
+CREATE OR REPLACE VIEW all_transactions AS
+SELECT id, wallet_id, ticker_id, amount, status, created_at, updated_at, linked_transaction_id, operation
+FROM transactions
+UNION ALL
+SELECT id, wallet_id, ticker_id, amount, status, created_at, updated_at, linked_transaction_id, operation
+FROM transactions_archive;
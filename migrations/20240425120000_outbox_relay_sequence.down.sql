@@ -0,0 +1,28 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS event_sequence bigint NOT NULL DEFAULT 0;
+
+UPDATE wallets w
+SET event_sequence = s.sequence
+FROM outbox_sequences s
+WHERE s.wallet_id = w.wallet_id;
+
+-- события без номера получают следующие номера своего кошелька в порядке id
+WITH numbered AS (SELECT id, wallet_id, row_number() OVER (PARTITION BY wallet_id ORDER BY id) AS n
+                  FROM outbox
+                  WHERE sequence IS NULL)
+UPDATE outbox o
+SET sequence = w.event_sequence + numbered.n
+FROM numbered
+         JOIN wallets w ON w.wallet_id = numbered.wallet_id
+WHERE o.id = numbered.id;
+
+UPDATE wallets w
+SET event_sequence = m.sequence
+FROM (SELECT wallet_id, MAX(sequence) AS sequence FROM outbox GROUP BY wallet_id) m
+WHERE m.wallet_id = w.wallet_id
+  AND m.sequence > w.event_sequence;
+
+ALTER TABLE outbox
+    ALTER COLUMN sequence SET NOT NULL;
+
+DROP TABLE IF EXISTS outbox_sequences;
@@ -0,0 +1,19 @@
+-- номера событий кошельков назначает публикация событий, а не транзакция операции: счётчик в строке кошелька
+-- приводил к конфликтам сериализации между операциями с разными тикерами одного кошелька и с кошельком комиссий
+CREATE TABLE IF NOT EXISTS outbox_sequences
+(
+    wallet_id integer primary key references wallets (wallet_id),
+    sequence  bigint NOT NULL
+);
+
+INSERT INTO outbox_sequences (wallet_id, sequence)
+SELECT wallet_id, event_sequence
+FROM wallets
+WHERE event_sequence > 0;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS event_sequence;
+
+-- у событий, которые ещё не выбирались для публикации, номера нет
+ALTER TABLE outbox
+    ALTER COLUMN sequence DROP NOT NULL;
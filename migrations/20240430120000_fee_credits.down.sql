@@ -0,0 +1,9 @@
+DROP VIEW IF EXISTS current_balances;
+
+INSERT INTO balances (wallet_id, ticker_id, amount)
+SELECT wallet_id, ticker_id, SUM(amount)
+FROM fee_credits
+GROUP BY wallet_id, ticker_id
+ON CONFLICT (wallet_id, ticker_id) DO UPDATE SET amount = balances.amount + EXCLUDED.amount;
+
+DROP TABLE IF EXISTS fee_credits;
@@ -0,0 +1,22 @@
+-- зачисления комиссий не меняют строку баланса кошелька комиссий в транзакции операции: одна строка на тикер,
+-- которую обновляет каждая операция с комиссией, приводила к конфликтам сериализации между несвязанными операциями.
+-- Зачисления копятся здесь и переносятся в balances фоновой задачей или перед списанием с кошелька комиссий.
+CREATE TABLE IF NOT EXISTS fee_credits
+(
+    transaction_id integer primary key,
+    wallet_id      integer        NOT NULL references wallets (wallet_id),
+    ticker_id      integer        NOT NULL references tickers (ticker_id),
+    amount         numeric(30, 8) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS fee_credits_wallet_ticker_idx ON fee_credits (wallet_id, ticker_id);
+
+-- актуальный баланс с учётом ещё не перенесённых зачислений комиссий
+CREATE OR REPLACE VIEW current_balances AS
+SELECT wallet_id, ticker_id, SUM(amount) AS amount
+FROM (SELECT wallet_id, ticker_id, amount
+      FROM balances
+      UNION ALL
+      SELECT wallet_id, ticker_id, amount
+      FROM fee_credits) b
+GROUP BY wallet_id, ticker_id;
@@ -0,0 +1,13 @@
+-- из ключей, использованных в нескольких операциях, остаётся самый ранний
+DELETE
+FROM idempotency_keys k
+WHERE EXISTS (SELECT 1
+              FROM idempotency_keys d
+              WHERE d.key = k.key
+                AND (d.created_at, d.operation) < (k.created_at, k.operation));
+
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+
+ALTER TABLE idempotency_keys
+    ADD PRIMARY KEY (key);
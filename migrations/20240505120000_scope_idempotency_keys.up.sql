@@ -0,0 +1,7 @@
+-- ключ идемпотентности выбирает клиент, поэтому он уникален только в пределах операции:
+-- одинаковые ключи запросов разных операций больше не отклоняются, а обрабатываются как разные запросы
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+
+ALTER TABLE idempotency_keys
+    ADD PRIMARY KEY (key, operation);
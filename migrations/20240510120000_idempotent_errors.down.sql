@@ -0,0 +1,10 @@
+-- без сохранённых ошибок повторный запрос выполняется заново
+DELETE
+FROM idempotency_keys
+WHERE error_code IS NOT NULL;
+
+ALTER TABLE idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_outcome,
+    DROP COLUMN IF EXISTS error_code,
+    DROP COLUMN IF EXISTS reason,
+    ALTER COLUMN response SET NOT NULL;
@@ -0,0 +1,9 @@
+-- запросы, завершившиеся ошибкой бизнес-логики, тоже сохраняются, чтобы повтор получил ту же ошибку,
+-- а не выполнил операцию заново и не создал ещё одну запись о неудачной транзакции
+ALTER TABLE idempotency_keys
+    ALTER COLUMN response DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS error_code varchar(64),
+    ADD COLUMN IF NOT EXISTS reason     text;
+
+ALTER TABLE idempotency_keys
+    ADD CONSTRAINT idempotency_keys_outcome CHECK ((response IS NULL) <> (error_code IS NULL));